@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/x-cellent/go-dags/pkg/flow"
 	"log"
@@ -32,9 +31,8 @@ func main() {
 	log.Printf("--- reconcile run 1 ---")
 	err := w.Reconcile(ctx)
 	for i := 2; err != nil; i++ {
-		var fatalErr flow.FatalError
-		if errors.As(err, &fatalErr) {
-			log.Fatalf(fatalErr.Error())
+		if flow.IsFatal(err) {
+			log.Fatalln(err)
 		} else {
 			log.Println(err)
 		}
@@ -1,5 +1,5 @@
 module github.com/x-cellent/go-dags
 
-go 1.16
+go 1.21
 
 require gonum.org/v1/gonum v0.9.1
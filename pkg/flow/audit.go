@@ -0,0 +1,101 @@
+package flow
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// kinds of audit events, see AuditEvent
+const (
+	AuditRunStarted    = "run started"
+	AuditRunFinished   = "run finished"
+	AuditTaskStarted   = "task started"
+	AuditTaskSucceeded = "task succeeded"
+	AuditTaskFailed    = "task failed"
+	AuditTaskSkipped   = "task skipped"
+	AuditSLABreached   = "sla breached"
+)
+
+// AuditEvent is a line of the audit trail written as JSON, see WithAuditWriter
+type AuditEvent struct {
+	// Time is the time of the event on the workflow's clock
+	Time time.Time `json:"time"`
+	// Event is the kind of the event, e.g. AuditTaskFailed
+	Event string `json:"event"`
+	// Workflow is the name of the workflow
+	Workflow string `json:"workflow,omitempty"`
+	// RunID is the id of the reconcile, see Workflow.RunID
+	RunID uint64 `json:"runId"`
+	// TaskID is the id of the task of a task event
+	TaskID *int64 `json:"taskId,omitempty"`
+	// Description is the description of the task of a task event
+	Description string `json:"description,omitempty"`
+	// Attempt is the number of invocations of the task's reconcile function of a task event
+	Attempt int `json:"attempt,omitempty"`
+	// Reason explains why a task was skipped or which service level it breached, see SLA
+	Reason string `json:"reason,omitempty"`
+	// Error is the error of a failed task or a finished reconcile
+	Error string `json:"error,omitempty"`
+}
+
+// auditor writes the audit trail of a workflow
+type auditor struct {
+	mu  sync.Mutex
+	out io.Writer
+	// number of events that could not be written
+	errors int
+}
+
+// write writes the given event as a single line and flushes it, if the writer supports it
+func (a *auditor) write(event AuditEvent) {
+	line, err := json.Marshal(event)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err == nil {
+		_, err = a.out.Write(append(line, '\n'))
+	}
+	if f, ok := a.out.(interface{ Flush() error }); ok && err == nil {
+		err = f.Flush()
+	}
+	if err != nil {
+		a.errors++
+	}
+}
+
+// AuditErrors returns the number of audit events that could not be written, see WithAuditWriter
+func (w *Workflow) AuditErrors() int {
+	if w.auditor == nil {
+		return 0
+	}
+	w.auditor.mu.Lock()
+	defer w.auditor.mu.Unlock()
+	return w.auditor.errors
+}
+
+// audit writes an event of the given kind to the audit trail, if there is one. The task is nil for run events.
+func (w *Workflow) audit(kind string, task *Task, reason string, err error) {
+	if w.auditor == nil {
+		return
+	}
+	event := AuditEvent{
+		Time:     w.clock.Now(),
+		Event:    kind,
+		Workflow: w.name,
+		Reason:   reason,
+		Error:    errorMessage(err),
+	}
+	w.mu.RLock()
+	event.RunID = w.runID
+	if task != nil {
+		id := task.id
+		event.TaskID = &id
+		event.Description = task.desc
+		if state, ok := w.states[task.id]; ok {
+			event.Attempt = state.attempts
+		}
+	}
+	w.mu.RUnlock()
+	w.auditor.write(event)
+}
@@ -0,0 +1,114 @@
+package flow_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// flushingWriter counts the lines written and flushed and fails writing after the given number of lines
+type flushingWriter struct {
+	bytes.Buffer
+	failAfter int
+	lines     int
+	flushes   int
+}
+
+func (f *flushingWriter) Write(p []byte) (int, error) {
+	if f.lines >= f.failAfter {
+		return 0, errors.New("disk full")
+	}
+	f.lines++
+	return f.Buffer.Write(p)
+}
+
+func (f *flushingWriter) Flush() error {
+	f.flushes++
+	return nil
+}
+
+func TestAuditTrail(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	out := &flushingWriter{failAfter: 100}
+	w := flow.NewWorkflow(flow.WithName("machine-1"), flow.WithAuditWriter(out), flow.WithContinueOnError(),
+		flow.WithClock(flowtest.NewFakeClock(start)))
+	err := w.AddTasks([]*flow.Task{
+		flow.NewTask(1, "create V1", nop),
+		flow.NewTask(2, "create V2", func(context.Context, *flow.Task) error { return errors.New("no capacity") }),
+		flow.NewTask(3, "create V3", nop, flow.SkipIf(func(context.Context, *flow.Task) (bool, error) {
+			return true, nil
+		})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err == nil {
+		t.Fatal("expected task 2 to fail")
+	}
+
+	var events []string
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for _, line := range lines {
+		var event flow.AuditEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected a JSON line, got %q: %v", line, err)
+		}
+		if event.Workflow != "machine-1" || event.RunID != 1 || !event.Time.Equal(start) {
+			t.Fatalf("expected the workflow, run id and time in every event, got %s", line)
+		}
+		desc := event.Event
+		if event.TaskID != nil {
+			desc = fmt.Sprintf("%s %d", desc, *event.TaskID)
+		}
+		if event.Reason != "" || event.Error != "" {
+			desc = fmt.Sprintf("%s (%s%s)", desc, event.Reason, event.Error)
+		}
+		events = append(events, desc)
+	}
+	want := []string{
+		flow.AuditRunStarted,
+		flow.AuditTaskStarted + " 1",
+		flow.AuditTaskSucceeded + " 1",
+		flow.AuditTaskStarted + " 2",
+		flow.AuditTaskFailed + " 2 (no capacity)",
+		flow.AuditTaskStarted + " 3",
+		flow.AuditTaskSkipped + " 3 (skip predicate applies)",
+	}
+	if len(events) != len(want)+1 || !reflect.DeepEqual(events[:len(want)], want) ||
+		!strings.HasPrefix(events[len(want)], flow.AuditRunFinished+" (") {
+		t.Fatalf("expected the events %q followed by the finished run, got %q", want, events)
+	}
+	if out.flushes != len(lines) {
+		t.Fatalf("expected each of the %d lines to be flushed, got %d flushes", len(lines), out.flushes)
+	}
+	if w.AuditErrors() != 0 {
+		t.Fatalf("expected no audit errors, got %d", w.AuditErrors())
+	}
+}
+
+func TestAuditErrors(t *testing.T) {
+	out := &flushingWriter{failAfter: 2}
+	w := flow.NewWorkflow(flow.WithAuditWriter(out))
+	if err := w.AddTask(flow.NewTask(1, "create V1", nop)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatalf("expected the audit errors not to affect the workflow, got %v", err)
+	}
+	// run started and task started are written, task succeeded and run finished fail
+	if w.AuditErrors() != 2 {
+		t.Fatalf("expected 2 audit errors, got %d", w.AuditErrors())
+	}
+	if flow.NewWorkflow().AuditErrors() != 0 {
+		t.Fatal("expected no audit errors without audit trail")
+	}
+}
@@ -0,0 +1,72 @@
+package flow
+
+import (
+	"errors"
+	"time"
+)
+
+// CircuitState is the state of a task's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed indicates that the task is invoked normally
+	CircuitClosed CircuitState = iota
+	// CircuitOpen indicates that the task fails fast without invoking its reconcile function
+	CircuitOpen
+	// CircuitHalfOpen indicates that the next invocation of the task probes whether it recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breakerConfig configures the circuit breaker of a task
+type breakerConfig struct {
+	// number of consecutive failures that open the circuit
+	threshold int
+	// duration the circuit stays open before a probe is allowed
+	openDuration time.Duration
+}
+
+// breaker is the state of a task's circuit breaker
+type breaker struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// allow returns an error matching ErrCircuitOpen, if the circuit is open at the given time.
+// After the open duration has elapsed, the circuit becomes half-open and allows a probe.
+func (b *breaker) allow(config *breakerConfig, now time.Time) error {
+	if b.state != CircuitOpen {
+		return nil
+	}
+	if remaining := b.openedAt.Add(config.openDuration).Sub(now); remaining > 0 {
+		return RequeueAfter(remaining, ErrCircuitOpen)
+	}
+	b.state = CircuitHalfOpen
+	return nil
+}
+
+// record updates the circuit with the outcome of an invocation at the given time
+func (b *breaker) record(config *breakerConfig, now time.Time, err error) {
+	if err == nil || errors.Is(err, ErrSkipTask) || errors.Is(err, ErrAbort) || errors.As(err, &branchError{}) {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= config.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+	}
+}
@@ -0,0 +1,97 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// circuit returns the state of the circuit breaker of the task with the given id
+func circuit(t *testing.T, w *flow.Workflow, id int64) flow.CircuitState {
+	t.Helper()
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range report.Tasks {
+		if task.ID == id {
+			return task.Circuit
+		}
+	}
+	t.Fatalf("task %d not found in the report", id)
+	return flow.CircuitClosed
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	var (
+		invocations int
+		healthy     bool
+		probed      flow.CircuitState
+	)
+	if err := w.AddTask(flow.NewTask(1, "flaky", func(context.Context, *flow.Task) error {
+		invocations++
+		probed = circuit(t, w, 1)
+		if !healthy {
+			return errors.New("unavailable")
+		}
+		return nil
+	}, flow.CircuitBreaker(2, time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	reconcile := func(wantCircuit flow.CircuitState, wantInvocations int) error {
+		t.Helper()
+		err := w.Reconcile(context.Background())
+		if got := circuit(t, w, 1); got != wantCircuit {
+			t.Fatalf("expected the circuit to be %s, got %s", wantCircuit, got)
+		}
+		if invocations != wantInvocations {
+			t.Fatalf("expected %d invocations, got %d", wantInvocations, invocations)
+		}
+		return err
+	}
+
+	_ = reconcile(flow.CircuitClosed, 1)
+	_ = reconcile(flow.CircuitOpen, 2)
+
+	var requeue flow.RequeueError
+	if err := reconcile(flow.CircuitOpen, 2); !errors.Is(err, flow.ErrCircuitOpen) || !errors.As(err, &requeue) ||
+		requeue.After != time.Minute {
+		t.Fatalf("expected a requeue after 1m matching ErrCircuitOpen, got %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if err := reconcile(flow.CircuitOpen, 2); !errors.As(err, &requeue) || requeue.After != 30*time.Second {
+		t.Fatalf("expected a requeue after 30s, got %v", err)
+	}
+
+	// a failing probe opens the circuit again at once
+	clock.Advance(30 * time.Second)
+	_ = reconcile(flow.CircuitOpen, 3)
+	if probed != flow.CircuitHalfOpen {
+		t.Errorf("expected the probe to run half-open, got %s", probed)
+	}
+
+	// a successful probe closes the circuit
+	clock.Advance(time.Minute)
+	healthy = true
+	if err := reconcile(flow.CircuitClosed, 4); err != nil {
+		t.Fatal(err)
+	}
+	if probed != flow.CircuitHalfOpen {
+		t.Errorf("expected the probe to run half-open, got %s", probed)
+	}
+}
+
+func TestCircuitBreakerInvalid(t *testing.T) {
+	for _, opt := range []flow.TaskOption{flow.CircuitBreaker(0, time.Minute), flow.CircuitBreaker(3, 0)} {
+		w := flow.NewWorkflow()
+		if err := w.AddTask(flow.NewTask(1, "task", nop, opt)); !errors.Is(err, flow.ErrInvalidTask) {
+			t.Errorf("expected error matching ErrInvalidTask, got %v", err)
+		}
+	}
+}
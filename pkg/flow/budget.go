@@ -0,0 +1,66 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// spendRetry consumes one unit of the retry budget of the workflow for the given retryable error of the task.
+// If the budget is exhausted, it returns the error escalated to a FatalError, that explains which tasks spent
+// the budget. Errors of tasks that wait deliberately, i.e. RequeueErrors and ErrWaitingForApproval, are free.
+func (w *Workflow) spendRetry(task *Task, err error) error {
+	var requeueErr RequeueError
+	if w.retryBudget <= 0 || errors.As(err, &requeueErr) || errors.Is(err, ErrWaitingForApproval) {
+		return err
+	}
+	spent := 0
+	for _, n := range w.retriesSpent {
+		spent += n
+	}
+	if spent >= w.retryBudget {
+		return NewFatalError(fmt.Errorf("retry budget of %d attempts exhausted (%s): %w", w.retryBudget, w.describeRetriesSpent(), err))
+	}
+	w.retriesSpent[task.id]++
+	return err
+}
+
+// spendTaskRetry escalates the given retryable error of the task to a FatalError, if the task has no retries left,
+// see TaskRetries
+func (w *Workflow) spendTaskRetry(task *Task, state *taskState, err error) error {
+	var requeueErr RequeueError
+	if task.retries < 0 || errors.As(err, &requeueErr) || errors.Is(err, ErrWaitingForApproval) {
+		return err
+	}
+	if state.retries >= task.retries {
+		return NewFatalError(fmt.Errorf("%d retries of the task exhausted: %w", task.retries, err))
+	}
+	state.retries++
+	return err
+}
+
+// describeRetriesSpent lists the number of retries per task, ordered by task id
+func (w *Workflow) describeRetriesSpent() string {
+	ids := make([]int64, 0, len(w.retriesSpent))
+	for id := range w.retriesSpent {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("task %d: %d", id, w.retriesSpent[id]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RetriesSpent returns the number of retries each task spent from the retry budget, key is the task id
+func (w *Workflow) RetriesSpent() map[int64]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	spent := make(map[int64]int, len(w.retriesSpent))
+	for id, n := range w.retriesSpent {
+		spent[id] = n
+	}
+	return spent
+}
@@ -0,0 +1,47 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestRetryBudget(t *testing.T) {
+	w := flow.NewWorkflow(flow.WithRetryBudget(3), flow.WithContinueOnError())
+	failing := func(context.Context, *flow.Task) error { return errors.New("api unavailable") }
+	err := w.AddTasks([]*flow.Task{
+		flow.NewTask(1, "create V1", failing),
+		flow.NewTask(2, "create V2", failing),
+		flow.NewTask(3, "wait for V3", func(context.Context, *flow.Task) error {
+			return flow.RequeueAfter(time.Minute, errors.New("V3 not ready"))
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Reconcile(context.Background()); err == nil || flow.IsFatal(err) {
+		t.Fatalf("expected retryable errors within the budget, got %v", err)
+	}
+	if spent := w.RetriesSpent(); !reflect.DeepEqual(spent, map[int64]int{1: 1, 2: 1}) {
+		t.Fatalf("expected a retry spent by task 1 and 2 each, got %v", spent)
+	}
+
+	err = w.Reconcile(context.Background())
+	if !flow.IsFatal(err) || !strings.Contains(err.Error(), "retry budget of 3 attempts exhausted (task 1: 2, task 2: 1)") {
+		t.Fatalf("expected a fatal error explaining the spent budget, got %v", err)
+	}
+	if spent := w.RetriesSpent(); !reflect.DeepEqual(spent, map[int64]int{1: 2, 2: 1}) {
+		t.Fatalf("expected the escalated failure not to spend a retry, got %v", spent)
+	}
+
+	w.ResetStatus()
+	if spent := w.RetriesSpent(); len(spent) != 0 {
+		t.Fatalf("expected the budget to be reset, got %v", spent)
+	}
+}
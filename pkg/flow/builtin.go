@@ -0,0 +1,297 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// GateTask is a task that waits for a manual approval, e.g. before the destructive part of a workflow runs.
+// Until it is approved, its reconcile function returns a retryable error matching ErrWaitingForApproval.
+// After a rejection it returns a FatalError with the reason.
+type GateTask struct {
+	*Task
+
+	mu       sync.Mutex
+	approved bool
+	rejected bool
+	reason   string
+}
+
+// NewGateTask creates a new gate task specifying the id and description
+func NewGateTask(id int64, desc string, opts ...TaskOption) *GateTask {
+	gate := &GateTask{}
+	gate.Task = NewTask(id, desc, gate.reconcile, opts...)
+	return gate
+}
+
+// Approve approves the gate, so that its dependents can run
+func (g *GateTask) Approve() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.approved = true
+	g.rejected = false
+	g.reason = ""
+}
+
+// Reject rejects the gate with the given reason, so that the workflow fails fatally
+func (g *GateTask) Reject(reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.approved = false
+	g.rejected = true
+	g.reason = reason
+}
+
+func (g *GateTask) reconcile(_ context.Context, _ *Task) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case g.approved:
+		return nil
+	case g.rejected:
+		return NewFatalError(fmt.Errorf("rejected: %s", g.reason))
+	}
+	return ErrWaitingForApproval
+}
+
+// NewBarrierTask creates a new task that joins its dependencies and runs the given check only after all of them
+// succeeded or were skipped, e.g. to verify that a remote system acknowledged all uploaded artifacts. Unlike other
+// tasks, a barrier does not run after a failed soft dependency. The error of the check is classified like the error
+// of any other task. Visualize marks the task as barrier. A nil check is reported as ErrInvalidTask, when the task is
+// added.
+func NewBarrierTask(id int64, desc string, check Fn, opts ...TaskOption) *Task {
+	task := NewTask(id, desc, func(ctx context.Context, task *Task) error {
+		if err := dependenciesDone(ctx, task); err != nil {
+			return err
+		}
+		return check(ctx, task)
+	}, opts...)
+	task.barrier = true
+	if check == nil {
+		task.problems = append(task.problems, "barrier check is nil")
+	}
+	return task
+}
+
+// dependenciesDone returns an error, if a dependency of the task reconciled with the given context neither
+// succeeded nor was skipped, e.g. a barrier that always runs after a failure
+func dependenciesDone(ctx context.Context, task *Task) error {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return err
+	}
+	tc.w.mu.RLock()
+	defer tc.w.mu.RUnlock()
+	deps := tc.w.graph.To(task.id)
+	for deps.Next() {
+		id := deps.Node().ID()
+		if state, ok := tc.w.states[id]; !ok || state.status != Succeeded && state.status != Skipped {
+			return fmt.Errorf("barrier %s is waiting for dependency task id %d", task, id)
+		}
+	}
+	return nil
+}
+
+// NewWorkflowDependencyTask creates a new task that succeeds once all tasks of the other workflow succeeded or
+// were skipped, e.g. when the workflow depends on another one that is reconciled by a different component in the
+// same process. Until then it returns a retryable error matching ErrWaitingForWorkflow, that describes the progress
+// of the other workflow. If a task of the other workflow failed fatally, the task fails with a FatalError.
+// The other workflow is inspected by its Report.
+func NewWorkflowDependencyTask(id int64, desc string, other *Workflow, opts ...TaskOption) *Task {
+	return NewTask(id, desc, func(context.Context, *Task) error {
+		report, err := other.Report()
+		if err != nil {
+			return fmt.Errorf("error reporting workflow %s: %w", other.Name(), err)
+		}
+		phase, done := Succeeded, 0
+		for _, task := range report.Tasks {
+			switch task.Status {
+			case Succeeded, Skipped:
+				done++
+			case Failed:
+				if IsFatal(task.Err) {
+					return NewFatalError(fmt.Errorf("workflow %s failed fatally at task %d (%s): %w",
+						report.Workflow, task.ID, task.Description, task.Err))
+				}
+				phase = Failed
+			case Running:
+				if phase != Failed {
+					phase = Running
+				}
+			default:
+				if phase == Succeeded {
+					phase = Pending
+				}
+			}
+		}
+		if report.Aborted != nil {
+			return fmt.Errorf("%w %s (phase=aborted, %d/%d tasks done)", ErrWaitingForWorkflow, report.Workflow,
+				done, len(report.Tasks))
+		}
+		if phase == Succeeded {
+			return nil
+		}
+		return fmt.Errorf("%w %s (phase=%s, %d/%d tasks done)", ErrWaitingForWorkflow, report.Workflow, phase,
+			done, len(report.Tasks))
+	}, opts...)
+}
+
+// NewNotBeforeTask creates a new task that succeeds once the given time has passed, e.g. when a maintenance
+// window opens. Until then it returns a retryable error created by RequeueAfter with the remaining wait.
+func NewNotBeforeTask(id int64, desc string, notBefore time.Time, opts ...TaskOption) *Task {
+	return NewTask(id, desc, func(ctx context.Context, _ *Task) error {
+		return notBeforeError(clockFrom(ctx).Now(), notBefore)
+	}, opts...)
+}
+
+// NewNotBeforeDurationTask creates a new task like NewNotBeforeTask, but the time is given by the duration
+// relative to the first invocation of the task's reconcile function since the task last succeeded, so that
+// every reconcile after a success waits again.
+func NewNotBeforeDurationTask(id int64, desc string, d time.Duration, opts ...TaskOption) *Task {
+	var (
+		mu        sync.Mutex
+		notBefore time.Time
+	)
+	return NewTask(id, desc, func(ctx context.Context, _ *Task) error {
+		mu.Lock()
+		defer mu.Unlock()
+		now := clockFrom(ctx).Now()
+		if notBefore.IsZero() {
+			notBefore = now.Add(d)
+		}
+		err := notBeforeError(now, notBefore)
+		if err == nil {
+			notBefore = time.Time{}
+		}
+		return err
+	}, opts...)
+}
+
+// notBeforeError returns a RequeueAfter error with the remaining wait, if now is before notBefore
+func notBeforeError(now, notBefore time.Time) error {
+	if !now.Before(notBefore) {
+		return nil
+	}
+	remaining := notBefore.Sub(now)
+	return RequeueAfter(remaining, fmt.Errorf("not before %s, %s remaining", notBefore.Format(time.RFC3339), remaining))
+}
+
+// DefaultPollInterval is the default interval between two checks of a wait task
+const DefaultPollInterval = 5 * time.Second
+
+// WaitCheck checks whether the condition of a wait task is met
+type WaitCheck func(ctx context.Context) (done bool, err error)
+
+type waitConfig struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// waitConfigOf returns the configuration of the given wait task, which is created on first use
+func waitConfigOf(t *Task) *waitConfig {
+	if t.wait == nil {
+		t.wait = &waitConfig{interval: DefaultPollInterval}
+	}
+	return t.wait
+}
+
+// PollInterval sets the interval between two checks of a wait task, see NewWaitTask. Defaults to
+// DefaultPollInterval. A duration that is not positive is reported as ErrInvalidTask, when the task is added.
+func PollInterval(d time.Duration) TaskOption {
+	return func(t *Task) {
+		if d <= 0 {
+			t.problems = append(t.problems, fmt.Sprintf("poll interval %s is not positive", d))
+		}
+		waitConfigOf(t).interval = d
+	}
+}
+
+// WaitTimeout sets the overall timeout of a wait task, see NewWaitTask, measured from its first check since the
+// condition was last met. When it is exceeded, the task fails with a FatalError. Defaults to no timeout.
+func WaitTimeout(d time.Duration) TaskOption {
+	return func(t *Task) {
+		waitConfigOf(t).timeout = d
+	}
+}
+
+// NewWaitTask creates a new task that polls the given check until the condition is met, e.g. until a
+// loadbalancer is healthy. An error of the check is returned as the task's error. The errors of the task
+// include the number of checks performed so far. The checks are counted across reconciles until the condition is
+// met or the timeout is exceeded, then the next invocation starts over.
+func NewWaitTask(id int64, desc string, check WaitCheck, opts ...TaskOption) *Task {
+	var (
+		mu      sync.Mutex
+		started time.Time
+		polls   int
+	)
+	task := NewTask(id, desc, func(ctx context.Context, task *Task) error {
+		mu.Lock()
+		defer mu.Unlock()
+		config := *task.wait
+		clock := clockFrom(ctx)
+		if started.IsZero() {
+			started = clock.Now()
+		}
+		for {
+			polls++
+			done, err := check(ctx)
+			if err != nil {
+				return fmt.Errorf("check %d failed: %w", polls, err)
+			}
+			if done {
+				started, polls = time.Time{}, 0
+				return nil
+			}
+			if config.timeout > 0 && clock.Now().Sub(started) >= config.timeout {
+				err := NewFatalError(fmt.Errorf("condition not met after %d checks within %s", polls, config.timeout))
+				started, polls = time.Time{}, 0
+				return err
+			}
+			if err := sleep(ctx, clock, config.interval); err != nil {
+				return fmt.Errorf("waiting canceled after %d checks: %w", polls, err)
+			}
+		}
+	}, opts...)
+	waitConfigOf(task)
+	return task
+}
+
+// NewDelayTask creates a new task that waits for the given duration on the workflow's clock, e.g. to give
+// an external system time between two stages. The delay happens once per reconcile of the workflow, so that
+// subsequent invocations within the same reconcile, e.g. by a retry, succeed immediately, while every reconcile
+// waits again. If the context is done while waiting, its error is returned and the next invocation waits again.
+func NewDelayTask(id int64, desc string, d time.Duration, opts ...TaskOption) *Task {
+	return NewJitteredDelayTask(id, desc, d, 0, 0, opts...)
+}
+
+// NewJitteredDelayTask creates a new task like NewDelayTask, but adds a random duration up to jitter to the
+// delay, so that many workflows created from the same template do not continue simultaneously. The jitter is drawn
+// from a source seeded with the given seed, which should differ between the workflows, e.g. a hash of their names.
+func NewJitteredDelayTask(id int64, desc string, d, jitter time.Duration, seed int64, opts ...TaskOption) *Task {
+	var (
+		mu      sync.Mutex
+		done    bool
+		doneRun uint64 // the run in which the delay passed
+		rng     = rand.New(rand.NewSource(seed))
+	)
+	return NewTask(id, desc, func(ctx context.Context, _ *Task) error {
+		mu.Lock()
+		defer mu.Unlock()
+		runID, _ := RunID(ctx)
+		if done && doneRun == runID {
+			return nil
+		}
+		delay := d
+		if jitter > 0 {
+			delay += time.Duration(rng.Int63n(int64(jitter)))
+		}
+		if err := sleep(ctx, clockFrom(ctx), delay); err != nil {
+			return err
+		}
+		done, doneRun = true, runID
+		return nil
+	}, opts...)
+}
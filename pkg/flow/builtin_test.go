@@ -0,0 +1,288 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestWaitTaskTimeoutStartsOver(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	var healthy bool
+	task := flow.NewWaitTask(1, "wait for loadbalancer", func(context.Context) (bool, error) {
+		return healthy, nil
+	}, flow.PollInterval(time.Second), flow.WaitTimeout(time.Minute), flow.Labels(map[string]string{"kind": "wait"}))
+	if err := w.AddTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Labels()["kind"] != "wait" {
+		t.Fatalf("expected task options to apply, got labels %v", task.Labels())
+	}
+
+	healthy = true
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// long after the condition was met, the next reconcile waits for the full timeout again
+	clock.Advance(time.Hour)
+	healthy = false
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(context.Background()) }()
+	clock.BlockUntil(1)
+	healthy = true
+	clock.Advance(time.Second)
+	if err := <-errc; err != nil {
+		t.Fatalf("expected the wait task to succeed after one more check, got %v", err)
+	}
+}
+
+func TestWaitTaskTimeout(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	task := flow.NewWaitTask(1, "wait for dns", func(context.Context) (bool, error) {
+		return false, nil
+	}, flow.PollInterval(time.Minute), flow.WaitTimeout(time.Minute))
+	if err := w.AddTask(task); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(context.Background()) }()
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	if err := <-errc; !flow.IsFatal(err) {
+		t.Fatalf("expected a fatal error after the timeout, got %v", err)
+	}
+}
+
+func TestWaitTaskInvalidInterval(t *testing.T) {
+	w := flow.NewWorkflow()
+	task := flow.NewWaitTask(1, "wait", func(context.Context) (bool, error) { return true, nil }, flow.PollInterval(0))
+	if err := w.AddTask(task); !errors.Is(err, flow.ErrInvalidTask) {
+		t.Fatalf("expected error matching ErrInvalidTask, got %v", err)
+	}
+}
+
+func TestBarrierTaskNilCheck(t *testing.T) {
+	w := flow.NewWorkflow()
+	if err := w.AddTask(flow.NewBarrierTask(1, "barrier", nil)); !errors.Is(err, flow.ErrInvalidTask) {
+		t.Fatalf("expected error matching ErrInvalidTask, got %v", err)
+	}
+}
+
+func TestNotBeforeTaskRequeuesWithRemainingWait(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := flowtest.NewFakeClock(start)
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	if err := w.AddTask(flow.NewNotBeforeTask(1, "maintenance window", start.Add(90*time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct{ remaining, advance time.Duration }{
+		{remaining: 90 * time.Minute, advance: 30 * time.Minute},
+		{remaining: time.Hour, advance: time.Hour - time.Second},
+		{remaining: time.Second, advance: time.Second},
+	} {
+		var requeue flow.RequeueError
+		if err := w.Reconcile(context.Background()); !errors.As(err, &requeue) || requeue.After != step.remaining {
+			t.Fatalf("expected requeue after %s, got %v", step.remaining, err)
+		}
+		clock.Advance(step.advance)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotBeforeDurationTaskWaitsAgainAfterSuccess(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	if err := w.AddTask(flow.NewNotBeforeDurationTask(1, "settle", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	var requeue flow.RequeueError
+	if err := w.Reconcile(context.Background()); !errors.As(err, &requeue) || requeue.After != time.Minute {
+		t.Fatalf("expected requeue after 1m, got %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := w.Reconcile(context.Background()); !errors.As(err, &requeue) || requeue.After != time.Minute {
+		t.Fatalf("expected requeue after 1m again, got %v", err)
+	}
+}
+
+func TestGateTask(t *testing.T) {
+	w := flow.NewWorkflow()
+	gate := flow.NewGateTask(1, "approve deletion")
+	if err := w.AddTask(gate.Task); err != nil {
+		t.Fatal(err)
+	}
+	err := w.Reconcile(context.Background())
+	if !errors.Is(err, flow.ErrWaitingForApproval) || flow.IsFatal(err) {
+		t.Fatalf("expected a retryable error matching ErrWaitingForApproval, got %v", err)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.WaitingForApproval})
+
+	gate.Reject("wrong machine")
+	if err := w.Reconcile(context.Background()); !flow.IsFatal(err) || !strings.Contains(err.Error(), "wrong machine") {
+		t.Fatalf("expected a fatal error with the reason of the rejection, got %v", err)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Failed})
+
+	gate.Approve()
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Succeeded})
+}
+
+// reconcileAsync reconciles the workflow in a goroutine and returns a channel receiving the result
+func reconcileAsync(ctx context.Context, w *flow.Workflow) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- w.Reconcile(ctx) }()
+	return result
+}
+
+func TestDelayTaskWaitsOncePerReconcile(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	if err := w.AddTask(flow.NewDelayTask(1, "delay", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for run := 1; run <= 2; run++ {
+		result := reconcileAsync(context.Background(), w)
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		if err := <-result; err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+}
+
+func TestDelayTaskCanceled(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	if err := w.AddTask(flow.NewDelayTask(1, "delay", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	result := reconcileAsync(ctx, w)
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error matching context.Canceled, got %v", err)
+	}
+
+	result = reconcileAsync(context.Background(), w)
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJitteredDelayTaskIsDeterministicBySeed(t *testing.T) {
+	const seed = 42
+	jitter := time.Duration(rand.New(rand.NewSource(seed)).Int63n(int64(time.Minute)))
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	if err := w.AddTask(flow.NewJitteredDelayTask(1, "delay", time.Minute, time.Minute, seed)); err != nil {
+		t.Fatal(err)
+	}
+	result := reconcileAsync(context.Background(), w)
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute + jitter - time.Nanosecond)
+	select {
+	case err := <-result:
+		t.Fatalf("expected the delay to last %s, but it finished early with %v", time.Minute+jitter, err)
+	default:
+	}
+	clock.Advance(time.Nanosecond)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkflowDependencyTask(t *testing.T) {
+	tests := []struct {
+		name      string
+		fn        flow.Fn
+		reconcile bool
+		phase     string
+		fatal     bool
+	}{
+		{name: "pending", fn: nop, phase: "phase=pending, 0/1 tasks done"},
+		{name: "succeeded", fn: nop, reconcile: true},
+		{name: "failed", fn: func(context.Context, *flow.Task) error { return errors.New("boom") }, reconcile: true,
+			phase: "phase=failed, 0/1 tasks done"},
+		{name: "aborted", fn: func(context.Context, *flow.Task) error { return flow.ErrAbort }, reconcile: true,
+			phase: "phase=aborted"},
+		{name: "fatal", fn: func(context.Context, *flow.Task) error { return flow.NewFatalError(errors.New("boom")) },
+			reconcile: true, fatal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := flow.NewWorkflow(flow.WithName("other"))
+			if err := other.AddTask(flow.NewTask(1, "task", tt.fn)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.reconcile {
+				_ = other.Reconcile(context.Background())
+			}
+			w := flow.NewWorkflow()
+			if err := w.AddTask(flow.NewWorkflowDependencyTask(1, "wait for other", other)); err != nil {
+				t.Fatal(err)
+			}
+			err := w.Reconcile(context.Background())
+			switch {
+			case tt.fatal:
+				if !flow.IsFatal(err) {
+					t.Fatalf("expected a fatal error, got %v", err)
+				}
+			case tt.phase != "":
+				if !errors.Is(err, flow.ErrWaitingForWorkflow) || flow.IsFatal(err) || !strings.Contains(err.Error(), tt.phase) {
+					t.Fatalf("expected a retryable error matching ErrWaitingForWorkflow with %q, got %v", tt.phase, err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWorkflowDependencyTaskRunning(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	other := flow.NewWorkflow(flow.WithName("other"))
+	if err := other.AddTask(flow.NewTask(1, "task", func(context.Context, *flow.Task) error {
+		close(started)
+		<-release
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	w := flow.NewWorkflow()
+	if err := w.AddTask(flow.NewWorkflowDependencyTask(1, "wait for other", other)); err != nil {
+		t.Fatal(err)
+	}
+	result := reconcileAsync(context.Background(), other)
+	<-started
+	if err := w.Reconcile(context.Background()); !errors.Is(err, flow.ErrWaitingForWorkflow) ||
+		!strings.Contains(err.Error(), "phase=running") {
+		t.Fatalf("expected an error matching ErrWaitingForWorkflow with phase=running, got %v", err)
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckFn verifies whether the desired state of a task is currently present without changing anything.
+// It returns an empty string, if the state is in sync, or a description of the drift otherwise. An error
+// indicates that the state could not be checked.
+type CheckFn func(ctx context.Context, task *Task) (drift string, err error)
+
+// CheckState is the result of checking a single task, see Workflow.Check
+type CheckState int
+
+const (
+	// CheckUnknown indicates that the task has no check function or the check failed
+	CheckUnknown CheckState = iota
+	// CheckInSync indicates that the desired state of the task is present
+	CheckInSync
+	// CheckDrifted indicates that the desired state of the task is not present
+	CheckDrifted
+)
+
+func (s CheckState) String() string {
+	switch s {
+	case CheckUnknown:
+		return "unknown"
+	case CheckInSync:
+		return "in sync"
+	case CheckDrifted:
+		return "drifted"
+	}
+	return "invalid"
+}
+
+// CheckResult is the result of checking a single task
+type CheckResult struct {
+	// ID is the id of the task
+	ID int64
+	// Description is the description of the task
+	Description string
+	// State is the result of the check
+	State CheckState
+	// Drift describes the drift of a drifted task
+	Drift string
+	// Err is the error of the check, if it failed
+	Err error
+}
+
+// CheckReport is the result of checking all tasks of a workflow, see Workflow.Check
+type CheckReport struct {
+	// Tasks are the results of the tasks in execution order
+	Tasks []CheckResult
+}
+
+// InSync returns true, if all tasks are in sync
+func (r CheckReport) InSync() bool {
+	for _, task := range r.Tasks {
+		if task.State != CheckInSync {
+			return false
+		}
+	}
+	return true
+}
+
+// Count returns the number of tasks with the given state
+func (r CheckReport) Count(state CheckState) int {
+	n := 0
+	for _, task := range r.Tasks {
+		if task.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// Check calls the check functions of all tasks in execution order, e.g. to audit whether the desired state is
+// present, see WithCheck. Unlike Reconcile, it never invokes reconcile functions and does not change the recorded
+// state. Tasks without check function are reported as CheckUnknown. Check returns an error, if the tasks cannot
+// be ordered or ctx is done.
+func (w *Workflow) Check(ctx context.Context) (CheckReport, error) {
+	if err := w.Validate(); err != nil {
+		return CheckReport{}, err
+	}
+	w.mu.RLock()
+	tasks, err := w.orderedTasks()
+	w.mu.RUnlock()
+	if err != nil {
+		return CheckReport{}, err
+	}
+
+	report := CheckReport{Tasks: make([]CheckResult, 0, len(tasks))}
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return report, fmt.Errorf("error checking %s: %w", task, err)
+		}
+		result := CheckResult{ID: task.id, Description: task.desc}
+		if task.check != nil {
+			result.Drift, result.Err = task.check(ctx, task)
+			switch {
+			case result.Err != nil:
+				result.Drift = ""
+			case result.Drift == "":
+				result.State = CheckInSync
+			default:
+				result.State = CheckDrifted
+			}
+		}
+		report.Tasks = append(report.Tasks, result)
+	}
+	return report, nil
+}
+
+// inSync returns true, if the workflow checks tasks before invoking them and the given task is in sync,
+// see WithCheckFirst
+func (w *Workflow) inSync(ctx context.Context, task *Task) bool {
+	if !w.checkFirst || task.check == nil {
+		return false
+	}
+	drift, err := task.check(ctx, task)
+	return err == nil && drift == ""
+}
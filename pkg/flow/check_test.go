@@ -0,0 +1,96 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// checkedWorkflow creates a workflow, whose task 1 is in sync, task 2 drifted, the check of task 3 fails and task 4
+// has no check. It records the ids of the invoked reconcile functions.
+func checkedWorkflow(t *testing.T, opts ...flow.Option) (*flow.Workflow, *[]int64) {
+	t.Helper()
+	var invoked []int64
+	fn := func(_ context.Context, task *flow.Task) error {
+		invoked = append(invoked, task.ID())
+		return nil
+	}
+	checks := map[int64]flow.CheckFn{
+		1: func(context.Context, *flow.Task) (string, error) { return "", nil },
+		2: func(context.Context, *flow.Task) (string, error) { return "VLAN 120 missing", nil },
+		3: func(context.Context, *flow.Task) (string, error) { return "", errors.New("switch unreachable") },
+	}
+	w := flow.NewWorkflow(opts...)
+	for id := int64(1); id <= 4; id++ {
+		var taskOpts []flow.TaskOption
+		if check := checks[id]; check != nil {
+			taskOpts = append(taskOpts, flow.WithCheck(check))
+		}
+		if err := w.AddTask(flow.NewTask(id, "task", fn, taskOpts...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w, &invoked
+}
+
+func TestCheck(t *testing.T) {
+	w, invoked := checkedWorkflow(t)
+	report, err := w.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []flow.CheckState
+	for _, task := range report.Tasks {
+		states = append(states, task.State)
+	}
+	want := []flow.CheckState{flow.CheckInSync, flow.CheckDrifted, flow.CheckUnknown, flow.CheckUnknown}
+	if !reflect.DeepEqual(states, want) {
+		t.Fatalf("expected states %v, got %v", want, states)
+	}
+	if report.Tasks[1].Drift != "VLAN 120 missing" || report.Tasks[2].Err == nil || report.Tasks[2].Drift != "" {
+		t.Fatalf("expected the drift of task 2 and the error of task 3, got %+v", report.Tasks)
+	}
+	if report.InSync() || report.Count(flow.CheckUnknown) != 2 {
+		t.Fatalf("expected the report not to be in sync, got %+v", report)
+	}
+	if len(*invoked) != 0 {
+		t.Fatalf("expected Check not to invoke reconcile functions, got %v", *invoked)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Pending, 2: flow.Pending, 3: flow.Pending,
+		4: flow.Pending})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.Check(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error matching context.Canceled, got %v", err)
+	}
+}
+
+func TestCheckFirst(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []flow.Option
+		want []int64
+	}{
+		{name: "without check first", want: []int64{1, 2, 3, 4}},
+		// the check of task 1 passes, the check of task 2 drifted and the check of task 3 failed
+		{name: "with check first", opts: []flow.Option{flow.WithCheckFirst()}, want: []int64{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, invoked := checkedWorkflow(t, tt.opts...)
+			if err := w.Reconcile(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*invoked, tt.want) {
+				t.Fatalf("expected the reconcile functions of %v to be invoked, got %v", tt.want, *invoked)
+			}
+			flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Succeeded, 2: flow.Succeeded,
+				3: flow.Succeeded, 4: flow.Succeeded})
+		})
+	}
+}
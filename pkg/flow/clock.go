@@ -0,0 +1,95 @@
+package flow
+
+import (
+	"context"
+	"time"
+)
+
+// Clock provides the current time and timers, so that time-based behavior can be tested without waiting.
+// All time-based behavior of the workflow uses its clock, see WithClock. The flowtest package provides a fake clock.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel
+	After(d time.Duration) <-chan time.Time
+	// NewTimer creates a timer that sends the current time on its channel after the duration, which can be stopped,
+	// unlike After, e.g. to wait in a loop without leaving timers behind
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event of a Clock, see Clock.NewTimer
+type Timer interface {
+	// C returns the channel on which the current time is sent, once the timer fires
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false, if the timer already fired or was stopped.
+	Stop() bool
+	// Reset changes the timer to fire after the given duration. It returns false, if the timer already fired or
+	// was stopped.
+	Reset(d time.Duration) bool
+}
+
+// RealClock is the Clock based on the time package
+type RealClock struct{}
+
+// Now returns time.Now()
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d)
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// NewTimer returns a Timer based on time.NewTimer(d)
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer is the Timer of the RealClock
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Clock returns the clock of the workflow, see WithClock
+func (w *Workflow) Clock() Clock {
+	return w.clock
+}
+
+// clockFrom returns the clock of the workflow, whose task is reconciled with the given context,
+// or the RealClock otherwise
+func clockFrom(ctx context.Context) Clock {
+	if tc, err := fromContext(ctx); err == nil {
+		return tc.w.clock
+	}
+	return RealClock{}
+}
+
+// Now returns the current time of the clock of the workflow, whose task is reconciled with the given context,
+// or the current time of the RealClock otherwise
+func Now(ctx context.Context) time.Time {
+	return clockFrom(ctx).Now()
+}
+
+// Sleep waits for the given duration to elapse on the clock of the workflow, whose task is reconciled with the
+// given context, or returns the error of ctx, if it is done earlier. Tasks should use it instead of time.Sleep,
+// so that they can be tested with a fake clock.
+func Sleep(ctx context.Context, d time.Duration) error {
+	return sleep(ctx, clockFrom(ctx), d)
+}
+
+// sleep waits for the given duration to elapse on the clock or returns the error of ctx, if it is done earlier
+func sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
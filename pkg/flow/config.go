@@ -0,0 +1,103 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+// Config is the resolved configuration of a workflow after applying its options, e.g. for debugging.
+// It is a copy, changing it does not affect the workflow.
+type Config struct {
+	// Name is the name of the workflow, see WithName
+	Name string
+	// Meta is the metadata of the workflow, see WithMeta
+	Meta map[string]string
+	// ContinueOnError is set in continue-on-error mode, see WithContinueOnError
+	ContinueOnError bool
+	// FinalizerGracePeriod is the timeout for tasks that always run, see WithFinalizerGracePeriod
+	FinalizerGracePeriod time.Duration
+	// RetryBudget is the total number of retries, unlimited if not positive, see WithRetryBudget
+	RetryBudget int
+	// ErrorHistorySize is the number of recent errors kept per task, see WithErrorHistory
+	ErrorHistorySize int
+	// StallDetection is the detection of stalled tasks, if enabled, see WithStallDetection
+	StallDetection *StallDetection
+	// LazyTasks is set, if dependencies may reference tasks that were not added yet, see WithLazyTasks
+	LazyTasks bool
+	// StrictTasks is set, if tasks are validated strictly, see WithStrictTasks
+	StrictTasks bool
+	// IncrementalOrder is set, if the order is maintained incrementally, see WithIncrementalOrder
+	IncrementalOrder bool
+	// TieBreaker is set, if independent tasks are not ordered by id, see WithDescriptionOrder
+	TieBreaker bool
+	// Clock is the clock of the workflow, see WithClock
+	Clock Clock
+	// Logger is the logger of the workflow, if set, see WithLogger
+	Logger *slog.Logger
+	// Audit is set, if the workflow writes an audit trail, see WithAuditWriter
+	Audit bool
+	// Err is the error of invalid or incompatible options, which matches ErrInvalidConfig
+	Err error
+}
+
+// Config returns the resolved configuration of the workflow
+func (w *Workflow) Config() Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var stall *StallDetection
+	if w.stall != nil {
+		detection := *w.stall
+		stall = &detection
+	}
+	return Config{
+		Name:                 w.name,
+		Meta:                 maps.Clone(w.meta),
+		ContinueOnError:      w.continueOnError,
+		FinalizerGracePeriod: w.finalizerGracePeriod,
+		RetryBudget:          w.retryBudget,
+		ErrorHistorySize:     w.errorHistorySize,
+		StallDetection:       stall,
+		LazyTasks:            w.lazy,
+		StrictTasks:          w.strict,
+		IncrementalOrder:     w.incremental != nil,
+		TieBreaker:           w.tieBreaker != nil,
+		Clock:                w.clock,
+		Logger:               w.logger,
+		Audit:                w.auditor != nil,
+		Err:                  w.configErr,
+	}
+}
+
+// validateConfig returns an error matching ErrInvalidConfig for each invalid or incompatible option
+func (w *Workflow) validateConfig() error {
+	var problems []string
+	if w.clock == nil {
+		problems = append(problems, "clock must not be nil")
+	}
+	if w.classifier == nil {
+		problems = append(problems, "error classifier must not be nil")
+	}
+	if w.finalizerGracePeriod < 0 {
+		problems = append(problems, fmt.Sprintf("negative finalizer grace period %s", w.finalizerGracePeriod))
+	}
+	if w.stall != nil && w.stall.Threshold < 1 {
+		problems = append(problems, fmt.Sprintf("stall detection threshold %d must be positive", w.stall.Threshold))
+	}
+	if w.incremental != nil && w.tieBreaker != nil {
+		problems = append(problems, "an incremental order cannot be combined with a tie breaker")
+	}
+	if w.auditor != nil && w.auditor.out == nil {
+		problems = append(problems, "audit writer must not be nil")
+	}
+	if w.output.out == nil {
+		problems = append(problems, "task output must not be nil")
+	}
+	errs := make([]error, len(problems))
+	for i, problem := range problems {
+		errs[i] = fmt.Errorf("%w: %s", ErrInvalidConfig, problem)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,116 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestConfig(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	logger := slog.Default()
+	w := flow.NewWorkflow(flow.WithName("machine-1"), flow.WithMeta("env", "prod"), flow.WithContinueOnError(),
+		flow.WithRetryBudget(10), flow.WithErrorHistory(3), flow.WithClock(clock), flow.WithLogger(logger),
+		flow.WithStallDetection(flow.StallDetection{Threshold: 4}), flow.WithDescriptionOrder())
+	config := w.Config()
+	want := flow.Config{
+		Name:                 "machine-1",
+		Meta:                 map[string]string{"env": "prod"},
+		ContinueOnError:      true,
+		FinalizerGracePeriod: flow.NewWorkflow().Config().FinalizerGracePeriod,
+		RetryBudget:          10,
+		ErrorHistorySize:     3,
+		StallDetection:       &flow.StallDetection{Threshold: 4},
+		TieBreaker:           true,
+		Clock:                clock,
+		Logger:               logger,
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("expected config %+v, got %+v", want, config)
+	}
+
+	// the config is a copy
+	config.Meta["env"] = "dev"
+	config.StallDetection.Threshold = 1
+	if again := w.Config(); again.Meta["env"] != "prod" || again.StallDetection.Threshold != 4 {
+		t.Fatalf("expected changes of the config not to affect the workflow, got %+v", again)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []flow.Option
+		problem string
+	}{
+		{name: "nil clock", opts: []flow.Option{flow.WithClock(nil)}, problem: "clock must not be nil"},
+		{name: "nil classifier", opts: []flow.Option{flow.WithErrorClassifier(nil)},
+			problem: "error classifier must not be nil"},
+		{name: "negative grace period", opts: []flow.Option{flow.WithFinalizerGracePeriod(-time.Second)},
+			problem: "negative finalizer grace period -1s"},
+		{name: "stall threshold", opts: []flow.Option{flow.WithStallDetection(flow.StallDetection{})},
+			problem: "stall detection threshold 0 must be positive"},
+		{name: "incremental order and tie breaker",
+			opts:    []flow.Option{flow.WithIncrementalOrder(), flow.WithDescriptionOrder()},
+			problem: "an incremental order cannot be combined with a tie breaker"},
+		{name: "nil audit writer", opts: []flow.Option{flow.WithAuditWriter(nil)},
+			problem: "audit writer must not be nil"},
+		{name: "nil task output", opts: []flow.Option{flow.WithTaskOutput(nil)},
+			problem: "task output must not be nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := flow.NewWorkflow(tt.opts...)
+			err := w.Config().Err
+			if !errors.Is(err, flow.ErrInvalidConfig) || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("expected error matching ErrInvalidConfig with %q, got %v", tt.problem, err)
+			}
+			if err := w.Validate(); !errors.Is(err, flow.ErrInvalidConfig) {
+				t.Fatalf("expected Validate to return the error, got %v", err)
+			}
+			if err := w.Reconcile(context.Background()); !errors.Is(err, flow.ErrInvalidConfig) || !flow.IsFatal(err) {
+				t.Fatalf("expected Reconcile to fail fatally with the error, got %v", err)
+			}
+		})
+	}
+
+	err := flow.NewWorkflow(flow.WithClock(nil), flow.WithAuditWriter(nil)).Config().Err
+	if !strings.Contains(err.Error(), "clock must not be nil") || !strings.Contains(err.Error(), "audit writer") {
+		t.Fatalf("expected an error for each invalid option, got %v", err)
+	}
+}
+
+func TestNameAndMeta(t *testing.T) {
+	w := flow.NewWorkflow(flow.WithName("provision-node"), flow.WithMeta("env", "prod"),
+		flow.WithMeta("region", "fra"), flow.WithMeta("env", "staging"))
+	if w.Name() != "provision-node" {
+		t.Fatalf("expected name provision-node, got %q", w.Name())
+	}
+	meta := w.Meta()
+	if want := map[string]string{"env": "staging", "region": "fra"}; !reflect.DeepEqual(meta, want) {
+		t.Fatalf("expected metadata %v, got %v", want, meta)
+	}
+	meta["env"] = "dev"
+	if w.Meta()["env"] != "staging" {
+		t.Fatal("expected the metadata to be a copy")
+	}
+
+	if err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		return errors.New("no capacity")
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err == nil || !strings.HasPrefix(err.Error(), "workflow provision-node: ") {
+		t.Fatalf("expected the error to be prefixed with the name, got %v", err)
+	}
+	if unnamed := flow.NewWorkflow(); unnamed.Name() != "" || len(unnamed.Meta()) != 0 {
+		t.Fatalf("expected no name and metadata by default, got %q and %v", unnamed.Name(), unnamed.Meta())
+	}
+}
@@ -0,0 +1,112 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// taskContextKey is the context key of the taskContext
+type taskContextKey struct{}
+
+// taskContext is passed within the context to the reconcile function of a task
+type taskContext struct {
+	w    *Workflow
+	task *Task
+	p    *pass
+	// output of the task, see TaskWriter
+	writer *lineWriter
+}
+
+// fromContext returns the taskContext of the given context
+func fromContext(ctx context.Context) (*taskContext, error) {
+	tc, ok := ctx.Value(taskContextKey{}).(*taskContext)
+	if !ok {
+		return nil, ErrNotReconciling
+	}
+	return tc, nil
+}
+
+// RunID returns the id of the reconcile, in which the task with the given context is reconciled
+func RunID(ctx context.Context) (uint64, error) {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tc.w.mu.RLock()
+	defer tc.w.mu.RUnlock()
+	return tc.w.runID, nil
+}
+
+// Expander adds tasks and dependencies to a workflow from within the reconcile function of one of its tasks,
+// e.g. to fan out tasks whose number is only known at runtime.
+// The added tasks stay part of the workflow, so subsequent reconciles must expect them to exist already.
+type Expander struct {
+	tc *taskContext
+}
+
+// Expand returns the Expander for the workflow, whose task is reconciled with the given context.
+func Expand(ctx context.Context) (*Expander, error) {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Expander{tc: tc}, nil
+}
+
+// AddTask adds the given task to the reconciling workflow. It is executed in the current reconcile.
+func (e *Expander) AddTask(task *Task) error {
+	if err := e.tc.w.AddTask(task); err != nil {
+		return err
+	}
+	e.tc.p.mu.Lock()
+	e.tc.p.expanded = true
+	e.tc.p.mu.Unlock()
+	return nil
+}
+
+// AddDependency adds dependencies from the given task to a number of other tasks in the reconciling workflow.
+// The given task must not have been executed in the current reconcile yet. A dependency that would introduce
+// a cycle is rejected with a FatalError.
+func (e *Expander) AddDependency(task *Task, dependencies ...*Task) error {
+	e.tc.p.mu.Lock()
+	processed := e.tc.p.processed[task.id]
+	e.tc.p.mu.Unlock()
+	if processed {
+		return NewFatalError(fmt.Errorf("error adding task dependency for task id %d: task was already executed", task.id))
+	}
+	err := e.tc.w.AddDependency(task, dependencies...)
+	if errors.Is(err, ErrWouldCycle) {
+		return NewFatalError(err)
+	}
+	if err != nil {
+		return err
+	}
+	e.tc.p.mu.Lock()
+	e.tc.p.expanded = true
+	e.tc.p.mu.Unlock()
+	return nil
+}
+
+// DependencyOutcome returns the status recorded for the task with the given id and the error of its last failed
+// invocation, if any, e.g. to let a task produce a partial result when a soft dependency failed. It can be called
+// concurrently by the reconcile functions of parallel tasks.
+// The task reconciled with the given context must depend directly on that task, otherwise an error matching
+// ErrNotUpstream is returned.
+func DependencyOutcome(ctx context.Context, taskID int64) (Status, error) {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return Pending, err
+	}
+	w := tc.w
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state, ok := w.states[taskID]
+	if !ok {
+		return Pending, fmt.Errorf("error getting outcome of task id %d: %w", taskID, ErrTaskNotFound)
+	}
+	if !w.graph.HasEdgeFromTo(taskID, tc.task.id) {
+		return Pending, fmt.Errorf("error getting outcome of task id %d for %s: %w", taskID, tc.task, ErrNotUpstream)
+	}
+	return state.status, state.err
+}
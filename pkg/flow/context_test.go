@@ -0,0 +1,189 @@
+package flow_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestDependencyOutcomeInGroup(t *testing.T) {
+	unavailable := errors.New("api unavailable")
+	var (
+		status      flow.Status
+		outcomeErr  error
+		upstreamErr error
+		notFoundErr error
+	)
+	w := flow.NewWorkflow(flow.WithContinueOnError())
+	tasks := []*flow.Task{
+		flow.NewTask(1, "fetch optional data", func(context.Context, *flow.Task) error { return unavailable }),
+		flow.NewTask(2, "fetch data", nop),
+		flow.NewTask(3, "render", func(ctx context.Context, _ *flow.Task) error {
+			status, outcomeErr = flow.DependencyOutcome(ctx, 1)
+			_, upstreamErr = flow.DependencyOutcome(ctx, 4)
+			_, notFoundErr = flow.DependencyOutcome(ctx, 5)
+			return nil
+		}),
+		flow.NewTask(4, "independent", nop),
+	}
+	if err := w.AddTasks(tasks); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(tasks[2], tasks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddSoftDependency(tasks[2], tasks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := runWithGroup(t, newLimitGroup(4), w); !errors.Is(err, unavailable) {
+		t.Fatalf("expected the error of task 1, got %v", err)
+	}
+	if status != flow.Failed || !errors.Is(outcomeErr, unavailable) {
+		t.Fatalf("expected task 3 to see the failure of task 1, got %s and %v", status, outcomeErr)
+	}
+	if !errors.Is(upstreamErr, flow.ErrNotUpstream) {
+		t.Fatalf("expected error matching ErrNotUpstream for an independent task, got %v", upstreamErr)
+	}
+	if !errors.Is(notFoundErr, flow.ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound for an unknown task, got %v", notFoundErr)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Failed, 2: flow.Succeeded, 3: flow.Succeeded,
+		4: flow.Succeeded})
+}
+
+func TestDependencyOutcomeTransitive(t *testing.T) {
+	var err error
+	w := flow.NewWorkflow()
+	tasks := []*flow.Task{
+		flow.NewTask(1, "a", nop),
+		flow.NewTask(2, "b", nop),
+		flow.NewTask(3, "c", func(ctx context.Context, _ *flow.Task) error {
+			_, err = flow.DependencyOutcome(ctx, 1)
+			return nil
+		}),
+	}
+	if _, _, err := flow.Chain(w, tasks...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(err, flow.ErrNotUpstream) {
+		t.Fatalf("expected error matching ErrNotUpstream for a transitive dependency, got %v", err)
+	}
+}
+
+// expandingWorkflow creates a workflow, whose task 1 adds the tasks 10 and 11 and makes task 2 depend on them.
+// It records the ids of the executed tasks.
+func expandingWorkflow(t *testing.T) (*flow.Workflow, *[]int64) {
+	t.Helper()
+	var mu sync.Mutex
+	var executed []int64
+	record := func(_ context.Context, task *flow.Task) error {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, task.ID())
+		return nil
+	}
+	w := flow.NewWorkflow()
+	format := flow.NewTask(2, "format disks", record)
+	discover := flow.NewTask(1, "discover disks", func(ctx context.Context, task *flow.Task) error {
+		if err := record(ctx, task); err != nil {
+			return err
+		}
+		e, err := flow.Expand(ctx)
+		if err != nil {
+			return err
+		}
+		var disks []*flow.Task
+		for _, id := range []int64{10, 11} {
+			disk := flow.NewTask(id, "wipe disk", record)
+			if err := e.AddTask(disk); err != nil {
+				return err
+			}
+			disks = append(disks, disk)
+		}
+		if err := e.AddDependency(format, disks...); err != nil {
+			return err
+		}
+		// the discovering task was executed already
+		if err := e.AddDependency(task, disks[0]); !flow.IsFatal(err) {
+			return fmt.Errorf("expected a fatal error for an executed task, got %w", err)
+		}
+		return nil
+	})
+	if err := w.AddTasks([]*flow.Task{discover, format}); err != nil {
+		t.Fatal(err)
+	}
+	return w, &executed
+}
+
+func TestExpand(t *testing.T) {
+	w, executed := expandingWorkflow(t)
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*executed, []int64{1, 10, 11, 2}) {
+		t.Fatalf("expected the added tasks to run before the task depending on them, got %v", *executed)
+	}
+	if w.NumTasks() != 4 || w.NumDependencies() != 2 {
+		t.Fatalf("expected the added tasks to stay part of the workflow, got %d tasks", w.NumTasks())
+	}
+	if _, err := flow.Expand(context.Background()); !errors.Is(err, flow.ErrNotReconciling) {
+		t.Fatalf("expected error matching ErrNotReconciling, got %v", err)
+	}
+}
+
+func TestExpandInGroup(t *testing.T) {
+	w, _ := expandingWorkflow(t)
+	if err := runWithGroup(t, newLimitGroup(2), w); !flow.IsFatal(err) {
+		t.Fatalf("expected a fatal error for a task expanding the workflow in a group, got %v", err)
+	}
+}
+
+func TestRunIDPerReconcile(t *testing.T) {
+	var audit bytes.Buffer
+	w := flow.NewWorkflow(flow.WithAuditWriter(&audit))
+	var seen uint64
+	if err := w.AddTask(flow.NewTask(1, "task", func(ctx context.Context, _ *flow.Task) (err error) {
+		seen, err = flow.RunID(ctx)
+		return err
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if w.RunID() != 0 {
+		t.Fatalf("expected run id 0 before the first reconcile, got %d", w.RunID())
+	}
+	for run := uint64(1); run <= 3; run++ {
+		audit.Reset()
+		if err := w.Reconcile(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		report, err := w.Report()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != run || w.RunID() != run || report.RunID != run {
+			t.Fatalf("expected run id %d, got %d in the task, %d of the workflow and %d in the report", run, seen,
+				w.RunID(), report.RunID)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+			var event flow.AuditEvent
+			if err := json.Unmarshal([]byte(line), &event); err != nil || event.RunID != run {
+				t.Fatalf("expected run id %d in the audit event %s: %v", run, line, err)
+			}
+		}
+	}
+	if _, err := flow.RunID(context.Background()); !errors.Is(err, flow.ErrNotReconciling) {
+		t.Fatalf("expected error matching ErrNotReconciling, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package flow
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header written by WriteCSV
+var csvHeader = []string{"task", "depends_on"}
+
+// FromCSV creates a workflow configured by the given options from an edge list in CSV format with the columns task
+// and depends-on, e.g. exported from an asset system. A row with an empty depends-on column defines a task without
+// dependency. An optional header, surrounding whitespace and duplicate rows are ignored. The tasks are created by
+// taskFor in ascending order of their ids. Ids that are only referenced in the depends-on column are reported with
+// ErrTaskNotFound.
+func FromCSV(r io.Reader, taskFor func(id int64) (*Task, error), opts ...Option) (*Workflow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	deps := make(map[int64]map[int64]bool)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv: %w", err)
+		}
+		if len(record) == 0 || len(record) > 2 {
+			return nil, fmt.Errorf("error reading csv line %d: expected 2 columns, got %d", line, len(record))
+		}
+		taskField := strings.TrimSpace(record[0])
+		id, err := strconv.ParseInt(taskField, 10, 64)
+		if err != nil && line == 1 {
+			// header
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv line %d: invalid task id %q", line, taskField)
+		}
+		if deps[id] == nil {
+			deps[id] = make(map[int64]bool)
+		}
+		if len(record) < 2 || strings.TrimSpace(record[1]) == "" {
+			continue
+		}
+		depField := strings.TrimSpace(record[1])
+		depID, err := strconv.ParseInt(depField, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv line %d: invalid dependency id %q", line, depField)
+		}
+		deps[id][depID] = true
+	}
+
+	ids := make([]int64, 0, len(deps))
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var missing []int64
+	for _, id := range ids {
+		for depID := range deps[id] {
+			if _, ok := deps[depID]; !ok {
+				missing = append(missing, depID)
+				deps[depID] = nil
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+		return nil, fmt.Errorf("error reading csv: dependencies on ids without task row %v: %w", missing, ErrTaskNotFound)
+	}
+
+	w := NewWorkflow(opts...)
+	for _, id := range ids {
+		task, err := taskFor(id)
+		if err != nil {
+			return nil, fmt.Errorf("error creating task id %d: %w", id, err)
+		}
+		if err := w.AddTask(task); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range ids {
+		depIDs := make([]int64, 0, len(deps[id]))
+		for depID := range deps[id] {
+			depIDs = append(depIDs, depID)
+		}
+		sort.Slice(depIDs, func(i, j int) bool { return depIDs[i] < depIDs[j] })
+		if err := w.AddDependencyByID(id, depIDs...); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// WriteCSV writes the dependencies of the workflow as edge list in CSV format with a header, see FromCSV.
+// There is a row for each dependency and a row with an empty depends-on column for each task without dependency.
+// The rows are ordered by task id and then by the id of the dependency.
+func (w *Workflow) WriteCSV(out io.Writer) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	ids := make([]int64, 0, len(w.tasks))
+	for id := range w.tasks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	writer := csv.NewWriter(out)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		task := strconv.FormatInt(id, 10)
+		var depIDs []int64
+		nodes := w.graph.To(id)
+		for nodes.Next() {
+			depIDs = append(depIDs, nodes.Node().ID())
+		}
+		if len(depIDs) == 0 {
+			if err := writer.Write([]string{task, ""}); err != nil {
+				return err
+			}
+			continue
+		}
+		sort.Slice(depIDs, func(i, j int) bool { return depIDs[i] < depIDs[j] })
+		for _, depID := range depIDs {
+			if err := writer.Write([]string{task, strconv.FormatInt(depID, 10)}); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
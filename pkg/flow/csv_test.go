@@ -0,0 +1,43 @@
+package flow_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	// the tasks of RandomDAG are described by their ids
+	taskFor := func(id int64) (*flow.Task, error) {
+		return flow.NewTask(id, fmt.Sprintf("task %d", id), func(context.Context, *flow.Task) error { return nil }), nil
+	}
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 100; run++ {
+		nodes := r.Intn(20)
+		w := flowtest.RandomDAG(r, nodes, r.Intn(nodes*nodes/2+1))
+		var out bytes.Buffer
+		if err := w.WriteCSV(&out); err != nil {
+			t.Fatal(err)
+		}
+		written := out.String()
+		restored, err := flow.FromCSV(&out, taskFor)
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if restored.DefinitionHash() != w.DefinitionHash() || !flow.Equal(restored, w) {
+			t.Fatalf("run %d: expected the restored workflow to have the same structure, csv:\n%s", run, written)
+		}
+		out.Reset()
+		if err := restored.WriteCSV(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != written {
+			t.Fatalf("run %d: expected the restored workflow to write\n%s\ngot\n%s", run, written, out.String())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package flow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReportDiff describes what changed between two reports of the same workflow, e.g. between two attempts.
+// The task lists are in the execution order of the current report, except for Removed, which is in the order of
+// the previous report.
+type ReportDiff struct {
+	// NewlySucceeded are the tasks that succeeded, but did not before
+	NewlySucceeded []TaskReport
+	// NewlyFailing are the tasks that failed, but did not before
+	NewlyFailing []TaskReport
+	// StillFailing are the tasks that failed again with the same error message
+	StillFailing []TaskReport
+	// ChangedError are the tasks that failed again, but with a different error message
+	ChangedError []TaskReport
+	// Added are the tasks that are only part of the current report, e.g. because they were added at runtime.
+	// They are not included in the other lists.
+	Added []TaskReport
+	// Removed are the tasks that are only part of the previous report
+	Removed []TaskReport
+}
+
+// DiffReports returns the changes from the previous to the current report
+func DiffReports(prev, cur Report) ReportDiff {
+	var diff ReportDiff
+	previous := make(map[int64]TaskReport, len(prev.Tasks))
+	for _, task := range prev.Tasks {
+		previous[task.ID] = task
+	}
+	current := make(map[int64]bool, len(cur.Tasks))
+	for _, task := range cur.Tasks {
+		current[task.ID] = true
+		before, ok := previous[task.ID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, task)
+		case task.Status == Succeeded && before.Status != Succeeded:
+			diff.NewlySucceeded = append(diff.NewlySucceeded, task)
+		case task.Status == Failed && before.Status != Failed:
+			diff.NewlyFailing = append(diff.NewlyFailing, task)
+		case task.Status == Failed && errorMessage(task.Err) == errorMessage(before.Err):
+			diff.StillFailing = append(diff.StillFailing, task)
+		case task.Status == Failed:
+			diff.ChangedError = append(diff.ChangedError, task)
+		}
+	}
+	for _, task := range prev.Tasks {
+		if !current[task.ID] {
+			diff.Removed = append(diff.Removed, task)
+		}
+	}
+	return diff
+}
+
+// IsEmpty returns true, if the diff contains no changes and no failing tasks
+func (d ReportDiff) IsEmpty() bool {
+	return len(d.NewlySucceeded)+len(d.NewlyFailing)+len(d.StillFailing)+len(d.ChangedError)+
+		len(d.Added)+len(d.Removed) == 0
+}
+
+// String returns a line per kind of change, e.g. "newly succeeded: task 1 (a), task 3 (c)"
+func (d ReportDiff) String() string {
+	if d.IsEmpty() {
+		return "no changes"
+	}
+	var lines []string
+	for _, kind := range []struct {
+		name  string
+		tasks []TaskReport
+	}{
+		{name: "newly succeeded", tasks: d.NewlySucceeded},
+		{name: "newly failing", tasks: d.NewlyFailing},
+		{name: "still failing", tasks: d.StillFailing},
+		{name: "failing with a different error", tasks: d.ChangedError},
+		{name: "added", tasks: d.Added},
+		{name: "removed", tasks: d.Removed},
+	} {
+		if len(kind.tasks) == 0 {
+			continue
+		}
+		names := make([]string, len(kind.tasks))
+		for i, task := range kind.tasks {
+			names[i] = fmt.Sprintf("task %d (%s)", task.ID, task.Description)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", kind.name, strings.Join(names, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// errorMessage returns the message of the given error or an empty string for nil
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,67 @@
+package flow_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestDiffReports(t *testing.T) {
+	task := func(id int64, status flow.Status, err error) flow.TaskReport {
+		return flow.TaskReport{ID: id, Description: "task", Status: status, Err: err}
+	}
+	prev := flow.Report{Tasks: []flow.TaskReport{
+		task(1, flow.Failed, errors.New("boom")),
+		task(2, flow.Succeeded, nil),
+		task(3, flow.Failed, errors.New("boom")),
+		task(4, flow.Failed, errors.New("boom")),
+		task(5, flow.Succeeded, nil),
+		task(6, flow.Succeeded, nil),
+	}}
+	cur := flow.Report{Tasks: []flow.TaskReport{
+		task(1, flow.Succeeded, nil),
+		task(2, flow.Failed, errors.New("boom")),
+		task(3, flow.Failed, errors.New("boom")),
+		task(4, flow.Failed, errors.New("bang")),
+		task(5, flow.Succeeded, nil),
+		task(7, flow.Pending, nil),
+	}}
+
+	diff := flow.DiffReports(prev, cur)
+	for _, kind := range []struct {
+		name  string
+		tasks []flow.TaskReport
+		want  []int64
+	}{
+		{name: "newly succeeded", tasks: diff.NewlySucceeded, want: []int64{1}},
+		{name: "newly failing", tasks: diff.NewlyFailing, want: []int64{2}},
+		{name: "still failing", tasks: diff.StillFailing, want: []int64{3}},
+		{name: "changed error", tasks: diff.ChangedError, want: []int64{4}},
+		{name: "added", tasks: diff.Added, want: []int64{7}},
+		{name: "removed", tasks: diff.Removed, want: []int64{6}},
+	} {
+		var ids []int64
+		for _, task := range kind.tasks {
+			ids = append(ids, task.ID)
+		}
+		if !reflect.DeepEqual(ids, kind.want) {
+			t.Errorf("%s: expected tasks %v, got %v", kind.name, kind.want, ids)
+		}
+	}
+	if diff.IsEmpty() {
+		t.Error("expected the diff not to be empty")
+	}
+}
+
+func TestDiffReportsUnchanged(t *testing.T) {
+	report := flow.Report{Tasks: []flow.TaskReport{{ID: 1, Status: flow.Succeeded}, {ID: 2, Status: flow.Skipped}}}
+	diff := flow.DiffReports(report, report)
+	if !diff.IsEmpty() {
+		t.Fatalf("expected an empty diff, got %s", diff)
+	}
+	if diff.String() != "no changes" {
+		t.Errorf("expected %q, got %q", "no changes", diff.String())
+	}
+}
@@ -0,0 +1,98 @@
+package flow
+
+import (
+	"maps"
+)
+
+// EqualOption configures Equal
+type EqualOption func(c *equalConfig)
+
+type equalConfig struct {
+	labels bool
+	meta   bool
+}
+
+// CompareLabels includes the labels of the tasks in the comparison of Equal
+func CompareLabels() EqualOption {
+	return func(c *equalConfig) {
+		c.labels = true
+	}
+}
+
+// CompareMeta includes the metadata of the workflows in the comparison of Equal
+func CompareMeta() EqualOption {
+	return func(c *equalConfig) {
+		c.meta = true
+	}
+}
+
+// definition is the structure of a workflow compared by Equal
+type definition struct {
+	meta   map[string]string
+	tasks  map[int64]*Task
+	edges  map[edgeID]bool
+	labels map[int64]map[string]string
+}
+
+// Equal returns true, if the given workflows have the same tasks, i.e. the same ids and descriptions, and the same
+// dependencies including whether they are soft, regardless of the order in which they were added. The labels of the
+// tasks and the metadata of the workflows are only compared, if requested by the options. Reconcile functions and
+// other options of the tasks cannot be compared and are ignored, as is the recorded state.
+func Equal(a, b *Workflow, opts ...EqualOption) bool {
+	var config equalConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if a == b {
+		return true
+	}
+	// the workflows are read one after the other, so that their locks are never held at once
+	defA, defB := a.definition(config), b.definition(config)
+	if len(defA.tasks) != len(defB.tasks) || len(defA.edges) != len(defB.edges) {
+		return false
+	}
+	if config.meta && !maps.Equal(defA.meta, defB.meta) {
+		return false
+	}
+	for id, task := range defA.tasks {
+		other, ok := defB.tasks[id]
+		if !ok || task.desc != other.desc {
+			return false
+		}
+		if config.labels && !maps.Equal(defA.labels[id], defB.labels[id]) {
+			return false
+		}
+	}
+	for edge, soft := range defA.edges {
+		if otherSoft, ok := defB.edges[edge]; !ok || soft != otherSoft {
+			return false
+		}
+	}
+	return true
+}
+
+// definition returns a copy of the structure of the workflow to compare
+func (w *Workflow) definition(config equalConfig) definition {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	def := definition{
+		tasks: maps.Clone(w.tasks),
+		edges: make(map[edgeID]bool),
+	}
+	if config.meta {
+		def.meta = maps.Clone(w.meta)
+	}
+	if config.labels {
+		def.labels = make(map[int64]map[string]string, len(w.tasks))
+		for id, task := range w.tasks {
+			def.labels[id] = maps.Clone(task.labels)
+		}
+	}
+	edges := w.graph.Edges()
+	for edges.Next() {
+		e := edges.Edge()
+		id := edgeID{from: e.From().ID(), to: e.To().ID()}
+		def.edges[id] = w.softEdges[id]
+	}
+	return def
+}
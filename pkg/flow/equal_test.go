@@ -0,0 +1,70 @@
+package flow_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+// edge is a dependency of task on dep
+type edge struct {
+	task, dep int64
+	soft      bool
+}
+
+// buildWorkflow adds the tasks with the given ids and the given dependencies in the given order
+func buildWorkflow(t *testing.T, ids []int64, edges []edge) *flow.Workflow {
+	t.Helper()
+	w := flow.NewWorkflow()
+	tasks := make(map[int64]*flow.Task, len(ids))
+	for _, id := range ids {
+		tasks[id] = flow.NewTask(id, "task", func(context.Context, *flow.Task) error { return nil })
+		if err := w.AddTask(tasks[id]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range edges {
+		var err error
+		if e.soft {
+			err = w.AddSoftDependency(tasks[e.task], tasks[e.dep])
+		} else {
+			err = w.AddDependency(tasks[e.task], tasks[e.dep])
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+func TestEqualIndependentOfOrder(t *testing.T) {
+	a := buildWorkflow(t, []int64{1, 2, 3, 4}, []edge{{2, 1, false}, {3, 1, true}, {4, 2, false}, {4, 3, false}})
+	b := buildWorkflow(t, []int64{4, 3, 2, 1}, []edge{{4, 3, false}, {4, 2, false}, {3, 1, true}, {2, 1, false}})
+	if !flow.Equal(a, b) || !flow.Equal(b, a) {
+		t.Fatal("expected workflows built in a different order to be equal")
+	}
+}
+
+func TestEqualSingleEdgeDifference(t *testing.T) {
+	ids := []int64{1, 2, 3}
+	base := []edge{{2, 1, false}, {3, 2, false}}
+	tests := []struct {
+		name  string
+		edges []edge
+	}{
+		{name: "missing edge", edges: []edge{{2, 1, false}}},
+		{name: "additional edge", edges: []edge{{2, 1, false}, {3, 2, false}, {3, 1, false}}},
+		{name: "other dependency", edges: []edge{{2, 1, false}, {3, 1, false}}},
+		{name: "reversed edge", edges: []edge{{1, 2, false}, {3, 2, false}}},
+		{name: "soft edge", edges: []edge{{2, 1, false}, {3, 2, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := buildWorkflow(t, ids, base), buildWorkflow(t, ids, tt.edges)
+			if flow.Equal(a, b) || flow.Equal(b, a) {
+				t.Fatal("expected workflows with different edges not to be equal")
+			}
+		})
+	}
+}
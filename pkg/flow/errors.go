@@ -0,0 +1,285 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrAlreadyExists indicates that a task with the given id already exists
+	ErrAlreadyExists = errors.New("taskID already exists")
+	// ErrInvalidConfig indicates that a workflow was created with invalid or incompatible options, see NewWorkflow
+	ErrInvalidConfig = errors.New("invalid workflow configuration")
+	// ErrInvalidTask indicates that a task cannot be added, because it is misconfigured
+	ErrInvalidTask = errors.New("invalid task")
+	// ErrTaskNotFound indicates that a task is not part of the workflow
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrIDCollision indicates that task ids cannot be remapped, because several ids map to the same id,
+	// see IDCollisionError
+	ErrIDCollision = errors.New("task ids collide")
+	// ErrDuplicateDependency indicates that a dependency is listed twice, e.g. in a single call of AddDependency
+	ErrDuplicateDependency = errors.New("duplicate dependency")
+	// ErrDependencyNotFound indicates that a dependency between two tasks does not exist
+	ErrDependencyNotFound = errors.New("dependency not found")
+	// ErrUnfulfilledPlaceholders indicates that dependencies reference tasks that were not added, see WithLazyTasks
+	ErrUnfulfilledPlaceholders = errors.New("dependencies reference tasks that were not added")
+	// ErrWouldCycle indicates that adding a dependency would introduce a cycle, i.e. violate the DAG-property
+	ErrWouldCycle = errors.New("dependency would introduce a cycle")
+	// ErrCyclic indicates that the tasks cannot be ordered, because their dependencies form a cycle, see CyclicError
+	ErrCyclic = errors.New("dependencies form a cycle")
+	// ErrSkipTask can be returned by a reconcile function to indicate that the task was intentionally skipped.
+	// Reconcile treats it as success, but records the task as Skipped.
+	ErrSkipTask = errors.New("task skipped")
+	// ErrCanceled indicates that the context of a reconcile was canceled, e.g. by the operator. Unlike
+	// context.Canceled, it does not match cancellations within a task, that did not cancel the reconcile.
+	ErrCanceled = errors.New("reconcile canceled")
+	// ErrShutdown indicates that a reconcile stopped before executing all tasks, because the process is shutting
+	// down, see RunWithGracefulShutdown
+	ErrShutdown = errors.New("workflow shut down")
+	// ErrAbort can be returned by a reconcile function to stop the workflow cleanly.
+	// Reconcile does not execute further tasks except those that always run and returns an AbortedError.
+	ErrAbort = errors.New("workflow aborted")
+	// ErrWaitingForApproval indicates that a gate task was not approved yet
+	ErrWaitingForApproval = errors.New("waiting for approval")
+	// ErrWaitingForWorkflow indicates that another workflow, that a task depends on, did not succeed yet
+	ErrWaitingForWorkflow = errors.New("waiting for workflow")
+	// ErrCircuitOpen indicates that the circuit breaker of a task is open, so the task failed fast
+	ErrCircuitOpen = errors.New("circuit open")
+	// ErrNotReconciling indicates that a context was not passed to the reconcile function of a task
+	ErrNotReconciling = errors.New("context does not belong to a reconciling task")
+	// ErrNotUpstream indicates that a task accessed data of a task it does not depend on
+	ErrNotUpstream = errors.New("task is not upstream")
+	// ErrNoOutput indicates that a task did not record the requested output
+	ErrNoOutput = errors.New("output not found")
+	// ErrRunBudgetExhausted indicates that a reconcile stopped cleanly, because its budget was exhausted,
+	// see RunBudgetError
+	ErrRunBudgetExhausted = errors.New("run budget exhausted")
+	// ErrDefinitionChanged indicates that a snapshot cannot be restored, because the definition of the workflow
+	// changed since it was taken, see Restore
+	ErrDefinitionChanged = errors.New("workflow definition changed")
+	// ErrUnsupportedSnapshotVersion indicates that a snapshot was written by a newer version of this package,
+	// see SnapshotVersion
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+	// ErrStalled indicates that a task failed in a number of consecutive reconciles, see WithStallDetection
+	ErrStalled = errors.New("task stalled")
+)
+
+// CyclicError indicates that the tasks cannot be ordered, because their dependencies form cycles.
+// It matches ErrCyclic.
+type CyclicError struct {
+	// Tasks are the tasks that are part of a cycle, ordered by id
+	Tasks []*Task
+	// Err is the error of the graph library, that detected the cycles
+	Err error
+}
+
+func (e CyclicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrCyclic, e.Tasks)
+}
+
+// Is returns true for ErrCyclic
+func (e CyclicError) Is(target error) bool {
+	return target == ErrCyclic
+}
+
+// Unwrap returns the error of the graph library
+func (e CyclicError) Unwrap() error {
+	return e.Err
+}
+
+// AlreadyExists indicates that a task with the given id already exists
+//
+// Deprecated: use ErrAlreadyExists instead.
+var AlreadyExists = ErrAlreadyExists
+
+// TaskError wraps an error returned from the reconcile function of a task with the identity of the task.
+type TaskError struct {
+	// TaskID is the id of the failed task
+	TaskID int64
+	// Description is the description of the failed task
+	Description string
+	// Attempt is the number of the failed invocation of the task's reconcile function, starting at 1
+	Attempt int
+	// Err is the error returned by the task's reconcile function
+	Err error
+	// Timeout is set, if the task failed because a deadline was exceeded
+	Timeout bool
+	// Canceled is set, if the task failed because the reconcile was canceled. It is not set, if the task returned
+	// context.Canceled, but the reconcile was not canceled.
+	Canceled bool
+}
+
+func (e TaskError) Error() string {
+	switch {
+	case e.Timeout:
+		return fmt.Sprintf("task %d (%s) timed out: %v", e.TaskID, e.Description, e.Err)
+	case e.Canceled:
+		return fmt.Sprintf("task %d (%s) canceled: %v", e.TaskID, e.Description, e.Err)
+	}
+	return fmt.Sprintf("task %d (%s) failed: %v", e.TaskID, e.Description, e.Err)
+}
+
+// Is returns true for ErrCanceled, if the task failed because the reconcile was canceled
+func (e TaskError) Is(target error) bool {
+	return target == ErrCanceled && e.Canceled
+}
+
+// Unwrap returns the error returned by the task's reconcile function, so that e.g. a FatalError is still detected.
+func (e TaskError) Unwrap() error {
+	return e.Err
+}
+
+// canceledError returns the given error of a canceled reconcile context matching ErrCanceled
+func canceledError(err error) error {
+	if !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrCanceled, err)
+}
+
+// SkipTask returns an error matching ErrSkipTask that records the given reason for skipping the task.
+func SkipTask(reason string) error {
+	return reasonError{sentinel: ErrSkipTask, reason: reason}
+}
+
+// Abort returns an error matching ErrAbort that records the given reason for aborting the workflow.
+func Abort(reason string) error {
+	return reasonError{sentinel: ErrAbort, reason: reason}
+}
+
+// Branch returns a result for the reconcile function of a branch task, which indicates that the task succeeded and
+// selects the dependents with the given ids to run. All other dependents of the branch task are skipped, as well as
+// tasks that only depend on skipped dependents.
+func Branch(selected ...int64) error {
+	return branchError{selected: selected}
+}
+
+// branchError carries the dependents selected by a branch task
+type branchError struct {
+	selected []int64
+}
+
+func (e branchError) Error() string {
+	return fmt.Sprintf("branch selects task ids %v", e.selected)
+}
+
+// reasonError matches a sentinel error and carries a reason
+type reasonError struct {
+	sentinel error
+	reason   string
+}
+
+func (e reasonError) Error() string {
+	return fmt.Sprintf("%v: %s", e.sentinel, e.reason)
+}
+
+func (e reasonError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// reasonOf returns the reason of an error matching the given sentinel
+func reasonOf(err, sentinel error) string {
+	var reasonErr reasonError
+	switch {
+	case errors.As(err, &reasonErr) && reasonErr.sentinel == sentinel:
+		return reasonErr.reason
+	case err == sentinel:
+		return ""
+	}
+	return err.Error()
+}
+
+// AbortedError indicates that a task aborted the workflow by returning ErrAbort.
+// The workflow ended cleanly and should not be retried.
+type AbortedError struct {
+	// TaskID is the id of the aborting task
+	TaskID int64
+	// Description is the description of the aborting task
+	Description string
+	// Reason explains why the workflow was aborted
+	Reason string
+	// Err is the error returned by the aborting task's reconcile function
+	Err error
+}
+
+func (e AbortedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("workflow aborted by task %d (%s)", e.TaskID, e.Description)
+	}
+	return fmt.Sprintf("workflow aborted by task %d (%s): %s", e.TaskID, e.Description, e.Reason)
+}
+
+// Unwrap returns the error returned by the aborting task's reconcile function.
+func (e AbortedError) Unwrap() error {
+	return e.Err
+}
+
+// IsAborted returns true, if the given error is or wraps an AbortedError.
+func IsAborted(err error) bool {
+	var abortedErr AbortedError
+	return errors.As(err, &abortedErr)
+}
+
+// RequeueError is a retryable error that indicates when the task should be retried at the earliest.
+type RequeueError struct {
+	// After is the duration after which the task should be retried
+	After time.Duration
+	// Err is the cause of the retry
+	Err error
+}
+
+// RequeueAfter returns a RequeueError, that indicates that the task should be retried after the given duration.
+func RequeueAfter(d time.Duration, err error) error {
+	return RequeueError{
+		After: d,
+		Err:   err,
+	}
+}
+
+func (e RequeueError) Error() string {
+	return fmt.Sprintf("%v, requeue after %s", e.Err, e.After)
+}
+
+// Unwrap returns the cause of the retry.
+func (e RequeueError) Unwrap() error {
+	return e.Err
+}
+
+// Severity classifies a task error as retryable or fatal.
+type Severity int
+
+const (
+	// SeverityRetryable indicates that the task failed but can be retried later
+	SeverityRetryable Severity = iota
+	// SeverityFatal indicates that the task failed and cannot be retried
+	SeverityFatal
+)
+
+// ErrorClassifier decides about the severity of an error returned by the reconcile function of the given task.
+type ErrorClassifier func(task *Task, err error) Severity
+
+// DefaultErrorClassifier classifies FatalErrors as fatal and all other errors as retryable.
+func DefaultErrorClassifier(_ *Task, err error) Severity {
+	if IsFatal(err) {
+		return SeverityFatal
+	}
+	return SeverityRetryable
+}
+
+// classify upgrades the given error to a FatalError or downgrades a FatalError to its cause according to the severity
+func classify(severity Severity, err error) error {
+	var fatalErr FatalError
+	isFatal := errors.As(err, &fatalErr)
+	switch {
+	case severity == SeverityFatal && !isFatal:
+		return NewFatalError(err)
+	case severity == SeverityRetryable && isFatal:
+		if fatalErr.err == nil {
+			return errors.New("unknown error")
+		}
+		return fatalErr.err
+	}
+	return err
+}
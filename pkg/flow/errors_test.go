@@ -0,0 +1,216 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/graph/topo"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	newWorkflow := func(t *testing.T) (*Workflow, *Task, *Task) {
+		w := NewWorkflow()
+		t1, t2 := NewTask(1, "a", nop), NewTask(2, "b", nop)
+		if err := w.AddTasks([]*Task{t1, t2}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddDependency(t2, t1); err != nil {
+			t.Fatal(err)
+		}
+		return w, t1, t2
+	}
+	tests := []struct {
+		name string
+		do   func(w *Workflow, t1, t2 *Task) error
+		want error
+	}{
+		{
+			name: "task added twice",
+			do:   func(w *Workflow, t1, _ *Task) error { return w.AddTask(t1) },
+			want: ErrAlreadyExists,
+		},
+		{
+			name: "dependency on unknown task",
+			do:   func(w *Workflow, _, t2 *Task) error { return w.AddDependency(t2, NewTask(3, "c", nop)) },
+			want: ErrTaskNotFound,
+		},
+		{
+			name: "dependency of unknown task",
+			do:   func(w *Workflow, t1, _ *Task) error { return w.AddDependency(NewTask(3, "c", nop), t1) },
+			want: ErrTaskNotFound,
+		},
+		{
+			name: "unknown task removed",
+			do:   func(w *Workflow, _, _ *Task) error { return w.RemoveTaskByID(3) },
+			want: ErrTaskNotFound,
+		},
+		{
+			name: "dependency listed twice",
+			do: func(w *Workflow, t1, _ *Task) error {
+				t3 := NewTask(3, "c", nop)
+				if err := w.AddTask(t3); err != nil {
+					return err
+				}
+				return w.AddDependency(t3, t1, t1)
+			},
+			want: ErrDuplicateDependency,
+		},
+		{
+			name: "cycle",
+			do:   func(w *Workflow, t1, t2 *Task) error { return w.AddDependency(t1, t2) },
+			want: ErrWouldCycle,
+		},
+		{
+			name: "dependency on itself",
+			do:   func(w *Workflow, t1, _ *Task) error { return w.AddDependency(t1, t1) },
+			want: ErrWouldCycle,
+		},
+		{
+			name: "unknown dependency removed",
+			do:   func(w *Workflow, t1, t2 *Task) error { return w.RemoveDependency(t1, t2) },
+			want: ErrDependencyNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, t1, t2 := newWorkflow(t)
+			err := tt.do(w, t1, t2)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected error matching %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAlreadyExistsAlias(t *testing.T) {
+	w := NewWorkflow()
+	task := NewTask(1, "a", nop)
+	if err := w.AddTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddTask(task); !errors.Is(err, AlreadyExists) {
+		t.Fatalf("expected error matching AlreadyExists, got %v", err)
+	}
+}
+
+// causeError is an error type of a reconcile function
+type causeError struct {
+	code int
+}
+
+func (e causeError) Error() string {
+	return fmt.Sprintf("code %d", e.code)
+}
+
+func TestFatalErrorUnwrap(t *testing.T) {
+	err := fmt.Errorf("creating V1: %w", NewFatalError(fmt.Errorf("request: %w", context.DeadlineExceeded)))
+	if !IsFatal(err) {
+		t.Fatalf("expected fatal error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected error matching context.DeadlineExceeded, got %v", err)
+	}
+	var cause causeError
+	if !errors.As(NewFatalError(causeError{code: 7}), &cause) || cause.code != 7 {
+		t.Fatalf("expected causeError with code 7, got %v", cause)
+	}
+	if IsFatal(errors.New("retryable")) || IsFatal(nil) {
+		t.Fatal("expected no fatal error")
+	}
+}
+
+func TestFatalErrorNil(t *testing.T) {
+	err := NewFatalError(nil)
+	if err.Error() != "unknown fatal error" || err.Unwrap() != nil || !IsFatal(err) {
+		t.Fatalf("unexpected fatal error without cause: %v", err)
+	}
+}
+
+func TestFatalErrors(t *testing.T) {
+	errV1, errV2 := errors.New("V1 is corrupt"), causeError{code: 7}
+	err := fmt.Errorf("machine-1: %w", NewFatalErrors(errV1, NewFatalError(errV2), nil))
+	if !IsFatal(err) {
+		t.Fatalf("expected fatal error, got %v", err)
+	}
+	var cause causeError
+	if !errors.Is(err, errV1) || !errors.As(err, &cause) || cause.code != 7 {
+		t.Fatalf("expected error matching each cause, got %v", err)
+	}
+	if !strings.Contains(err.Error(), errV1.Error()) || !strings.Contains(err.Error(), errV2.Error()) {
+		t.Fatalf("expected the message to list each cause, got %v", err)
+	}
+	var fatalErr FatalError
+	if !errors.As(err, &fatalErr) || !reflect.DeepEqual(fatalErr.Causes(), []error{errV1, errV2}) {
+		t.Fatalf("expected the causes without the nested FatalError and nil, got %v", fatalErr.Causes())
+	}
+
+	if causes := NewFatalErrors(errV1).Causes(); !reflect.DeepEqual(causes, []error{errV1}) {
+		t.Fatalf("expected a single cause, got %v", causes)
+	}
+	if causes := NewFatalError(nil).Causes(); causes != nil {
+		t.Fatalf("expected no causes, got %v", causes)
+	}
+}
+
+func TestTaskErrorUnwrap(t *testing.T) {
+	w := NewWorkflow()
+	err := w.AddTask(NewTask(5, "create V5", func(context.Context, *Task) error {
+		return NewFatalError(causeError{code: 42})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Reconcile(context.Background())
+	var taskErr TaskError
+	if !errors.As(err, &taskErr) {
+		t.Fatalf("expected TaskError, got %v", err)
+	}
+	if taskErr.TaskID != 5 || taskErr.Description != "create V5" || taskErr.Attempt != 1 {
+		t.Fatalf("unexpected task error %+v", taskErr)
+	}
+	var cause causeError
+	if !IsFatal(err) || !errors.As(err, &cause) || cause.code != 42 {
+		t.Fatalf("expected fatal causeError with code 42 through the TaskError, got %v", err)
+	}
+	if errors.Is(err, ErrCanceled) {
+		t.Fatalf("expected no cancellation, got %v", err)
+	}
+}
+
+func TestCyclicError(t *testing.T) {
+	w := NewWorkflow()
+	if err := w.AddTasks([]*Task{NewTask(1, "a", nop), NewTask(2, "b", nop), NewTask(3, "c", nop)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyByID(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyByID(3, 2); err != nil {
+		t.Fatal(err)
+	}
+	// AddDependency rejects cycles, so the cycle between 2 and 3 is added to the graph directly
+	w.graph.SetEdge(w.graph.NewEdge(w.graph.Node(3), w.graph.Node(2)))
+	w.order = nil
+
+	_, orderErr := w.GetOrderedTasks()
+	_, visualizeErr := w.Visualize()
+	for name, err := range map[string]error{"Validate": w.Validate(), "GetOrderedTasks": orderErr,
+		"Visualize": visualizeErr} {
+		if !errors.Is(err, ErrCyclic) {
+			t.Fatalf("%s: expected error matching ErrCyclic, got %v", name, err)
+		}
+		var cyclicErr CyclicError
+		if !errors.As(err, &cyclicErr) || len(cyclicErr.Tasks) != 2 || cyclicErr.Tasks[0].id != 2 ||
+			cyclicErr.Tasks[1].id != 3 {
+			t.Fatalf("%s: expected the tasks 2 and 3 in the cycle, got %v", name, err)
+		}
+		var unorderable topo.Unorderable
+		if !errors.As(err, &unorderable) {
+			t.Fatalf("%s: expected the error of the graph library, got %v", name, err)
+		}
+	}
+}
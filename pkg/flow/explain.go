@@ -0,0 +1,85 @@
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Explain explains why the task after is ordered after the task before. If after depends on before directly or
+// transitively, it renders the shortest dependency chain, e.g. "task 2 (b) ← task 5 (e) ← task 3 (c)", where
+// each task waits for the task to its right. Otherwise, the explanation states that the tasks are independent and
+// their order is an arbitrary choice of the stable sort, or that the dependency is the other way round.
+// Labeled dependencies show their label on the arrow, e.g. "task 5 (e) ←[needs VLAN ID]─ task 3 (c)".
+func (w *Workflow) Explain(after, before *Task) (string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, task := range []*Task{after, before} {
+		if _, ok := w.tasks[task.id]; !ok {
+			return "", fmt.Errorf("error explaining order of task id %d: %w", task.id, ErrTaskNotFound)
+		}
+	}
+	if after.id == before.id {
+		return fmt.Sprintf("%s is the same task", after), nil
+	}
+	if chain := w.shortestPath(before.id, after.id); chain != nil {
+		return w.renderChain(chain), nil
+	}
+	if chain := w.shortestPath(after.id, before.id); chain != nil {
+		return fmt.Sprintf("%s is ordered before %s, because %s", after, before, w.renderChain(chain)), nil
+	}
+	return fmt.Sprintf("%s does not depend on %s, so their order is an arbitrary choice of the stable sort", after, before), nil
+}
+
+// shortestPath returns the ids of the tasks on the shortest dependency path from the dependency to the task,
+// which prefers lower ids among paths of the same length, or nil if there is none
+func (w *Workflow) shortestPath(from, to int64) []int64 {
+	prev := map[int64]int64{from: from}
+	queue := []int64{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			path := []int64{to}
+			for id != from {
+				id = prev[id]
+				path = append(path, id)
+			}
+			// reverse the path, so that it leads from the dependency to the task
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path
+		}
+		next := successors(w, id)
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+		for _, n := range next {
+			if _, seen := prev[n]; !seen {
+				prev[n] = id
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil
+}
+
+// renderChain renders the given path from a dependency to a task, starting with the task
+func (w *Workflow) renderChain(path []int64) string {
+	var b strings.Builder
+	for i := len(path) - 1; i >= 0; i-- {
+		id := path[i]
+		if i < len(path)-1 {
+			if label, ok := w.edgeLabels[edgeID{from: id, to: path[i+1]}]; ok {
+				fmt.Fprintf(&b, " ←[%s]─ ", label)
+			} else {
+				b.WriteString(" ← ")
+			}
+		}
+		if task, ok := w.tasks[id]; ok {
+			b.WriteString(task.String())
+		} else {
+			fmt.Fprintf(&b, "task %d (placeholder)", id)
+		}
+	}
+	return b.String()
+}
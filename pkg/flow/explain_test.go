@@ -0,0 +1,92 @@
+package flow_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestExplain(t *testing.T) {
+	w := flow.NewWorkflow()
+	tasks := make(map[int64]*flow.Task)
+	for id, desc := range map[int64]string{1: "create VLAN", 2: "create V2", 3: "configure switch",
+		4: "create V4", 5: "deploy", 6: "unrelated"} {
+		tasks[id] = flow.NewTask(id, desc, nop)
+		if err := w.AddTask(tasks[id]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, deps := range map[int64][]int64{2: {1}, 4: {2}, 5: {3, 4}} {
+		if err := w.AddDependencyByID(id, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.AddDependencyWithLabel(tasks[3], tasks[1], "needs the VLAN ID"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	for _, pair := range [][2]int64{{5, 1}, {3, 1}, {1, 5}, {6, 1}, {2, 2}} {
+		explanation, err := w.Explain(tasks[pair[0]], tasks[pair[1]])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&out, "%d after %d: %s\n", pair[0], pair[1], explanation)
+	}
+	assertGolden(t, "explain.golden", out.Bytes())
+
+	if _, err := w.Explain(tasks[1], flow.NewTask(7, "unknown", nop)); !errors.Is(err, flow.ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestDependencyLabel(t *testing.T) {
+	w := flow.NewWorkflow()
+	vlan, sw, deploy := flow.NewTask(1, "create VLAN", nop), flow.NewTask(2, "configure switch", nop),
+		flow.NewTask(3, "deploy", nop)
+	if err := w.AddTasks([]*flow.Task{vlan, sw, deploy}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyWithLabel(sw, vlan, "needs the VLAN ID"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(deploy, sw); err != nil {
+		t.Fatal(err)
+	}
+	if label := w.DependencyLabel(sw, vlan); label != "needs the VLAN ID" {
+		t.Fatalf("expected the label of the dependency, got %q", label)
+	}
+	if label := w.DependencyLabel(deploy, sw); label != "" {
+		t.Fatalf("expected no label of an unlabeled dependency, got %q", label)
+	}
+
+	// the label follows the tasks to their new ids
+	if err := w.RemapIDs(func(old int64) int64 { return old * 10 }); err != nil {
+		t.Fatal(err)
+	}
+	if label := w.DependencyLabel(sw, vlan); label != "needs the VLAN ID" {
+		t.Fatalf("expected the label to survive the remapping, got %q", label)
+	}
+	explanation, err := w.Explain(deploy, vlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "task 30 (deploy) ← task 20 (configure switch) ←[needs the VLAN ID]─ task 10 (create VLAN)"
+	if explanation != want {
+		t.Fatalf("expected explanation %q, got %q", want, explanation)
+	}
+
+	// removing the dependency removes its label
+	if err := w.RemoveDependency(sw, vlan); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(sw, vlan); err != nil {
+		t.Fatal(err)
+	}
+	if label := w.DependencyLabel(sw, vlan); label != "" {
+		t.Fatalf("expected the label to be removed with the dependency, got %q", label)
+	}
+}
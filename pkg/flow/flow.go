@@ -7,27 +7,148 @@ import (
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/simple"
 	"gonum.org/v1/gonum/graph/topo"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-// AlreadyExists indicates that a task with the given id already exists
-var AlreadyExists = errors.New("taskID already exists")
+// DefaultFinalizerGracePeriod is the default timeout for tasks that always run after the reconcile context is done
+const DefaultFinalizerGracePeriod = 10 * time.Second
+
+// DefaultErrorHistorySize is the default number of recent errors that are kept per task
+const DefaultErrorHistorySize = 5
 
 // Workflow consists of a DAG that models the dependencies and
 // associated Tasks for each node of the graph.
+// Read-only methods like TaskStatus, Report and Visualize can be called concurrently with a running Reconcile,
+// e.g. from a status handler. Reconcile and methods that change the workflow must not be called concurrently
+// with each other, except from within tasks, e.g. through an Expander.
 type Workflow struct {
+	// guards the graph and the recorded state, so that they can be read while a reconcile is running
+	mu sync.RWMutex
+	// guards the cached order, which is computed by readers
+	orderMu sync.Mutex
+	// optional name to identify the workflow, e.g. in errors
+	name string
+	// optional metadata
+	meta map[string]string
 	// DAG
 	graph *simple.DirectedGraph
+	// soft dependencies, i.e. edges that only constrain the execution order
+	softEdges map[edgeID]bool
+	// labels explaining dependencies, see AddDependencyWithLabel
+	edgeLabels map[edgeID]string
 	// associated Tasks, key is nodeID
 	tasks map[int64]*Task
+	// ids of the Tasks created with a key, see NewKeyedTask
+	keys map[string]int64
+	// cached executable order of the Tasks, nil if the graph changed since it was computed
+	order []*Task
+	// maintained topological order, if the order is computed incrementally
+	incremental *incrementalOrder
+	// orders independent Tasks, by id if nil
+	tieBreaker func(a, b *Task) bool
+	// recorded state of the Tasks, key is nodeID
+	states map[int64]*taskState
+	// values shared by the Tasks of the current or last reconcile
+	state *StateBag
+	// id of the reconcile that used the state bag, 0 if it was not used yet, e.g. after it was restored
+	stateRunID uint64
+	// include the state bag in snapshots
+	persistState bool
+	// decides whether a task error is retryable or fatal
+	classifier ErrorClassifier
+	// execute all tasks whose dependencies did not fail, instead of stopping at the first error
+	continueOnError bool
+	// timeout for tasks that always run, if the reconcile context is already done
+	finalizerGracePeriod time.Duration
+	// source of time for time-based tasks
+	clock Clock
+	// wraps the reconcile functions of all tasks
+	middleware []Middleware
+	// hooks called before and after the reconcile functions of all tasks
+	beforeTask func(ctx context.Context, task *Task) error
+	afterTask  func(ctx context.Context, task *Task, err error)
+	// hooks called at the start and end of every reconcile
+	onStart  func(ctx context.Context) error
+	onFinish func(ctx context.Context, report Report, err error)
+	// optional logger, e.g. for the logging middleware
+	logger *slog.Logger
+	// optional audit trail
+	auditor *auditor
+	// destination of the output of the tasks, see TaskWriter
+	output *taskOutput
+	// set if the last reconcile was aborted by a task
+	aborted *AbortedError
+	// id of the current or last reconcile, counting from 1
+	runID uint64
+	// total number of retries of all tasks, unlimited if not positive
+	retryBudget int
+	// retries spent from the budget, key is nodeID
+	retriesSpent map[int64]int
+	// number of recent errors kept per task
+	errorHistorySize int
+	// optional detection of tasks that fail repeatedly
+	stall *StallDetection
+	// groups of tasks with a deadline, key is the name of the stage
+	stages map[string]*stage
+	// create placeholders for unknown tasks referenced by dependencies
+	lazy bool
+	// nodes of referenced tasks that were not added yet, key is nodeID
+	placeholders map[int64]bool
+	// number of dependencies between tasks, without those of placeholders
+	numDependencies int
+	// reject tasks with empty descriptions or negative ids
+	strict bool
+	// error of incompatible options, see NewWorkflow
+	configErr error
+	// call the check functions of tasks before invoking them
+	checkFirst bool
+	// seed of the last reconcile, if it shuffled independent tasks
+	shuffleSeed *int64
+}
+
+// NewWorkflow creates a new workflow configured by the given options, which are applied in the given order.
+// Invalid or incompatible options are detected at construction and reported by Validate and Reconcile, see Config.
+func NewWorkflow(opts ...Option) *Workflow {
+	w := &Workflow{
+		meta:         make(map[string]string),
+		graph:        simple.NewDirectedGraph(),
+		softEdges:    make(map[edgeID]bool),
+		tasks:        make(map[int64]*Task),
+		states:       make(map[int64]*taskState),
+		state:        &StateBag{},
+		retriesSpent: make(map[int64]int),
+		classifier:   DefaultErrorClassifier,
+
+		finalizerGracePeriod: DefaultFinalizerGracePeriod,
+		clock:                RealClock{},
+		output:               &taskOutput{out: os.Stdout},
+		errorHistorySize:     DefaultErrorHistorySize,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.configErr = w.validateConfig()
+	return w
 }
 
-// NewWorkflow creates a new workflow
-func NewWorkflow() *Workflow {
-	return &Workflow{
-		graph: simple.NewDirectedGraph(),
-		tasks: make(map[int64]*Task),
+// Name returns the name of the workflow, which is empty if no name was set
+func (w *Workflow) Name() string {
+	return w.name
+}
+
+// Meta returns a copy of the metadata of the workflow
+func (w *Workflow) Meta() map[string]string {
+	meta := make(map[string]string, len(w.meta))
+	for k, v := range w.meta {
+		meta[k] = v
 	}
+	return meta
 }
 
 // AddTasks adds the given tasks to this workflow
@@ -40,109 +161,1298 @@ func (w *Workflow) AddTasks(tasks []*Task) error {
 	return nil
 }
 
-// AddTask adds the given task to this workflow
+// AddTask adds the given task to this workflow.
+// It returns an error matching ErrInvalidTask, if the task is nil or has no reconcile function, see also
+// WithStrictTasks.
 func (w *Workflow) AddTask(task *Task) error {
-	_, ok := w.tasks[task.id]
-	if ok {
-		return AlreadyExists
+	if err := w.validateTask(task); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.addTaskLocked(task)
+}
+
+// addTaskLocked adds the given valid task like AddTask, while the caller holds the lock
+func (w *Workflow) addTaskLocked(task *Task) error {
+	if existing, ok := w.tasks[task.id]; ok {
+		if existing.key != task.key {
+			return fmt.Errorf("error adding task %d: %w", task.id,
+				KeyCollisionError{ID: task.id, Key: task.key, ExistingKey: existing.key})
+		}
+		return fmt.Errorf("error adding task %d: %w", task.id, ErrAlreadyExists)
+	}
+	if id, ok := w.keys[task.key]; ok && task.key != "" {
+		return fmt.Errorf("error adding task %d: key %q is used by task %d: %w", task.id, task.key, id,
+			ErrAlreadyExists)
 	}
 
 	w.tasks[task.id] = task
+	if task.key != "" {
+		if w.keys == nil {
+			w.keys = make(map[string]int64)
+		}
+		w.keys[task.key] = task.id
+	}
+	w.states[task.id] = &taskState{}
+	if w.placeholders[task.id] {
+		// the node and its dependencies already exist
+		delete(w.placeholders, task.id)
+		w.numDependencies += w.taskDependencies(task.id)
+		w.order = nil
+		return nil
+	}
 
 	taskNode := simple.Node(task.id)
 	w.graph.AddNode(taskNode)
+	w.order = nil
+	if w.incremental != nil {
+		w.incremental.addNode(task.id)
+	}
 
 	return nil
 }
 
-// AddDependency adds one ore more dependencies from the given task to a number of other tasks
+// validateTask returns an error matching ErrInvalidTask, if the given task cannot be added
+func (w *Workflow) validateTask(task *Task) error {
+	if task == nil {
+		return fmt.Errorf("error adding task: %w: task is nil", ErrInvalidTask)
+	}
+	var problem string
+	switch {
+	case task.reconcileFn == nil:
+		problem = "reconcile function is nil"
+	case len(task.problems) > 0:
+		problem = strings.Join(task.problems, ", ")
+	case w.strict && task.desc == "":
+		problem = "description is empty"
+	case w.strict && task.id < 0:
+		problem = "id is negative"
+	default:
+		return nil
+	}
+	return fmt.Errorf("error adding task %d: %w: %s", task.id, ErrInvalidTask, problem)
+}
+
+// AddDependency adds one ore more dependencies from the given task to a number of other tasks.
+// Adding an existing dependency again is a no-op, except that it becomes an ordinary dependency, if it was soft.
+// Listing a dependency twice in the same call is rejected with ErrDuplicateDependency.
 func (w *Workflow) AddDependency(task *Task, dependencies ...*Task) error {
+	return w.AddDependencyByID(task.id, taskIDs(dependencies)...)
+}
+
+// AddDependencyByID adds one or more dependencies from the task with the given id to a number of other tasks
+// identified by their ids, like AddDependency
+func (w *Workflow) AddDependencyByID(taskID int64, dependencyIDs ...int64) error {
+	return w.addDependency(taskID, false, "", dependencyIDs...)
+}
+
+// AddSoftDependency adds one or more soft dependencies from the given task to a number of other tasks.
+// Soft dependencies constrain the execution order like ordinary dependencies, but in continue-on-error mode
+// the task is executed even if a soft dependency failed. An existing dependency added again becomes soft.
+func (w *Workflow) AddSoftDependency(task *Task, dependencies ...*Task) error {
+	return w.addDependency(task.id, true, "", taskIDs(dependencies)...)
+}
+
+// AddDependencyWithLabel adds a dependency from the given task to the other task like AddDependency, together with
+// a label that explains it, e.g. "needs the VLAN ID". The label is returned by DependencyLabel and shown by Explain.
+// If the dependency exists already, its label is replaced.
+func (w *Workflow) AddDependencyWithLabel(task, dependency *Task, label string) error {
+	return w.addDependency(task.id, false, label, dependency.id)
+}
+
+func (w *Workflow) addDependency(taskID int64, soft bool, label string, dependencyIDs ...int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.addDependencyLocked(taskID, soft, label, dependencyIDs...)
+}
 
-	taskNode := w.graph.Node(task.id)
+// addDependencyLocked adds dependencies like addDependency, while the caller holds the lock
+func (w *Workflow) addDependencyLocked(taskID int64, soft bool, label string, dependencyIDs ...int64) error {
+	if w.lazy {
+		w.addPlaceholders(append([]int64{taskID}, dependencyIDs...))
+	}
+	taskNode := w.graph.Node(taskID)
 	if taskNode == nil {
-		return fmt.Errorf("error adding task dependency for task id %d: node with id %d does not exist", task.id, task.id)
+		return fmt.Errorf("error adding task dependency for task id %d: %w", taskID, ErrTaskNotFound)
 	}
 	// pre-check depNodes so that we produce a consistent result or fail otherwise
 	var depNodes []graph.Node
-	for _, depTask := range dependencies {
-		depNode := w.graph.Node(depTask.id)
+	seen := make(map[int64]bool)
+	for _, depID := range dependencyIDs {
+		depNode := w.graph.Node(depID)
 		if depNode == nil {
-			return fmt.Errorf("error adding task dependency from id %d to id %d: node with id %d does not exist", task.id, depTask.id, depTask.id)
+			return fmt.Errorf("error adding task dependency from id %d to id %d: %w", taskID, depID, ErrTaskNotFound)
+		}
+		if seen[depID] {
+			return fmt.Errorf("error adding task dependency from id %d to id %d: %w", taskID, depID, ErrDuplicateDependency)
 		}
+		if w.wouldCycle(taskNode, depNode) {
+			return fmt.Errorf("error adding task dependency from id %d to id %d: %w", taskID, depID, ErrWouldCycle)
+		}
+		seen[depID] = true
 		depNodes = append(depNodes, depNode)
 	}
 	for _, depNode := range depNodes {
-		// reverse direction of edge at insert, so that the topological sort returns the execution order
-		edge := w.graph.NewEdge(depNode, taskNode)
-		w.graph.SetEdge(edge)
+		id := edgeID{from: depNode.ID(), to: taskNode.ID()}
+		// an existing edge only takes over the kind and label of the dependency
+		if !w.graph.HasEdgeFromTo(id.from, id.to) {
+			// reverse direction of edge at insert, so that the topological sort returns the execution order
+			edge := w.graph.NewEdge(depNode, taskNode)
+			w.graph.SetEdge(edge)
+			if w.tasks[taskID] != nil && w.tasks[depNode.ID()] != nil {
+				w.numDependencies++
+			}
+			w.order = nil
+			if w.incremental != nil {
+				w.incremental.addEdge(w, id.from, id.to)
+			}
+		}
+		if soft {
+			w.softEdges[id] = true
+		} else {
+			delete(w.softEdges, id)
+		}
+		if label != "" {
+			if w.edgeLabels == nil {
+				w.edgeLabels = make(map[edgeID]string)
+			}
+			w.edgeLabels[id] = label
+		}
 	}
 	return nil
 }
 
-// GetOrderedTasks returns the Tasks in executable order according to their dependencies
-func (w *Workflow) GetOrderedTasks() ([]*Task, error) {
-	// order topographically and lexically by id
-	sortedIDs, err := topo.SortStabilized(w.graph, nil)
+// addPlaceholders adds a placeholder node for each of the given ids, that is not part of the graph yet
+func (w *Workflow) addPlaceholders(ids []int64) {
+	for _, id := range ids {
+		if w.graph.Node(id) != nil {
+			continue
+		}
+		if w.placeholders == nil {
+			w.placeholders = make(map[int64]bool)
+		}
+		w.placeholders[id] = true
+		w.graph.AddNode(simple.Node(id))
+		w.order = nil
+		if w.incremental != nil {
+			w.incremental.addNode(id)
+		}
+	}
+}
+
+// Validate returns an error matching ErrInvalidConfig, if the workflow was created with invalid options,
+// an error matching ErrUnfulfilledPlaceholders, if dependencies reference tasks that were not added yet, see
+// WithLazyTasks, or a CyclicError, if the tasks cannot be ordered
+func (w *Workflow) Validate() error {
+	if w.configErr != nil {
+		return w.configErr
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, err := w.orderedTasks()
+	return err
+}
+
+// cyclicError converts an error of the topological sort to a CyclicError
+func (w *Workflow) cyclicError(err error) error {
+	var unorderable topo.Unorderable
+	if !errors.As(err, &unorderable) {
+		return err
+	}
+	var tasks []*Task
+	for _, cycle := range unorderable {
+		for _, node := range cycle {
+			tasks = append(tasks, w.tasks[node.ID()])
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].id < tasks[j].id })
+	return CyclicError{Tasks: tasks, Err: err}
+}
+
+func (w *Workflow) validate() error {
+	if len(w.placeholders) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(w.placeholders))
+	for id := range w.placeholders {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return fmt.Errorf("%w: task ids %v", ErrUnfulfilledPlaceholders, ids)
+}
+
+// RemoveTask removes the given task and its dependencies from this workflow, like RemoveTaskByID
+func (w *Workflow) RemoveTask(task *Task) error {
+	return w.RemoveTaskByID(task.id)
+}
+
+// RemoveTaskByID removes the task with the given id from this workflow together with its recorded state, its own
+// dependencies and the dependencies of other tasks on it
+func (w *Workflow) RemoveTaskByID(taskID int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.tasks[taskID]; !ok {
+		return fmt.Errorf("error removing task id %d: %w", taskID, ErrTaskNotFound)
+	}
+	w.removeTaskLocked(taskID)
+	return nil
+}
+
+// removeTaskLocked removes the existing task with the given id like RemoveTaskByID, while the caller holds the lock
+func (w *Workflow) removeTaskLocked(taskID int64) {
+	for edge := range w.softEdges {
+		if edge.from == taskID || edge.to == taskID {
+			delete(w.softEdges, edge)
+		}
+	}
+	for edge := range w.edgeLabels {
+		if edge.from == taskID || edge.to == taskID {
+			delete(w.edgeLabels, edge)
+		}
+	}
+	for _, s := range w.stages {
+		delete(s.tasks, taskID)
+	}
+	w.numDependencies -= w.taskDependencies(taskID)
+	w.graph.RemoveNode(taskID)
+	if task := w.tasks[taskID]; task != nil && task.key != "" {
+		delete(w.keys, task.key)
+	}
+	delete(w.tasks, taskID)
+	delete(w.states, taskID)
+	delete(w.retriesSpent, taskID)
+	w.order = nil
+	if w.incremental != nil {
+		w.incremental.removeNode(taskID)
+	}
+}
+
+// RemoveDependency removes dependencies from the given task to a number of other tasks, like RemoveDependencyByID
+func (w *Workflow) RemoveDependency(task *Task, dependencies ...*Task) error {
+	return w.RemoveDependencyByID(task.id, taskIDs(dependencies)...)
+}
+
+// RemoveDependencyByID removes dependencies from the task with the given id to a number of other tasks identified
+// by their ids. If a task or a dependency does not exist, no dependency is removed.
+func (w *Workflow) RemoveDependencyByID(taskID int64, dependencyIDs ...int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.tasks[taskID]; !ok {
+		return fmt.Errorf("error removing task dependency for task id %d: %w", taskID, ErrTaskNotFound)
+	}
+	for _, depID := range dependencyIDs {
+		if _, ok := w.tasks[depID]; !ok {
+			return fmt.Errorf("error removing task dependency from id %d to id %d: %w", taskID, depID, ErrTaskNotFound)
+		}
+		if !w.graph.HasEdgeFromTo(depID, taskID) {
+			return fmt.Errorf("error removing task dependency from id %d to id %d: %w", taskID, depID, ErrDependencyNotFound)
+		}
+	}
+	for _, depID := range dependencyIDs {
+		if !w.graph.HasEdgeFromTo(depID, taskID) {
+			// listed twice
+			continue
+		}
+		// removing an edge keeps a maintained order valid
+		w.graph.RemoveEdge(depID, taskID)
+		w.numDependencies--
+		delete(w.softEdges, edgeID{from: depID, to: taskID})
+		delete(w.edgeLabels, edgeID{from: depID, to: taskID})
+		w.order = nil
+	}
+	return nil
+}
+
+// taskIDs returns the ids of the given tasks
+func taskIDs(tasks []*Task) []int64 {
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.id
+	}
+	return ids
+}
+
+// wouldCycle returns true, if a dependency from the given task to the given dependency would close a cycle,
+// i.e. if the dependency already (transitively) depends on the task
+func (w *Workflow) wouldCycle(taskNode, depNode graph.Node) bool {
+	if taskNode.ID() == depNode.ID() {
+		return true
+	}
+	// cheap checks first, that avoid traversing the graph while building large workflows
+	if w.incremental != nil && w.incremental.index[depNode.ID()] < w.incremental.index[taskNode.ID()] {
+		return false
+	}
+	if w.graph.From(taskNode.ID()).Len() == 0 || w.graph.To(depNode.ID()).Len() == 0 {
+		return false
+	}
+	return topo.PathExistsIn(w.graph, taskNode, depNode)
+}
+
+// edgeID identifies an edge of the graph, i.e. a dependency of the task "to" on the task "from"
+type edgeID struct {
+	from, to int64
+}
+
+// IsSoftDependency returns true, if the given task has a soft dependency on the other task
+func (w *Workflow) IsSoftDependency(task, dependency *Task) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.softEdges[edgeID{from: dependency.id, to: task.id}]
+}
+
+// DependencyLabel returns the label of the dependency of the given task on the other task, or an empty string if
+// the dependency has no label or does not exist, see AddDependencyWithLabel
+func (w *Workflow) DependencyLabel(task, dependency *Task) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.edgeLabels[edgeID{from: dependency.id, to: task.id}]
+}
+
+// DependsOn returns true, if the given task depends on the other task directly or transitively
+func (w *Workflow) DependsOn(task, dependency *Task) (bool, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.dependsOn(task, dependency)
+}
+
+// AreIndependent returns true, if neither of the given tasks depends on the other directly or transitively, so
+// that they could be executed in parallel. A task is not independent of itself.
+func (w *Workflow) AreIndependent(a, b *Task) (bool, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if a.id == b.id {
+		if _, ok := w.tasks[a.id]; !ok {
+			return false, fmt.Errorf("error checking independence of task id %d: %w", a.id, ErrTaskNotFound)
+		}
+		return false, nil
+	}
+	aDependsOnB, err := w.dependsOn(a, b)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	bDependsOnA, err := w.dependsOn(b, a)
+	if err != nil {
+		return false, err
+	}
+	return !aDependsOnB && !bDependsOnA, nil
+}
 
+// dependsOn returns true, if there is a path from the dependency to the task in the graph
+func (w *Workflow) dependsOn(task, dependency *Task) (bool, error) {
+	for _, t := range []*Task{task, dependency} {
+		if _, ok := w.tasks[t.id]; !ok {
+			return false, fmt.Errorf("error checking dependency of task id %d: %w", t.id, ErrTaskNotFound)
+		}
+	}
+	if task.id == dependency.id {
+		return false, nil
+	}
+	return topo.PathExistsIn(w.graph, w.graph.Node(dependency.id), w.graph.Node(task.id)), nil
+}
+
+// TaskStatus returns the recorded status of the task with the given id, e.g. for a task to query the outcome
+// of its soft dependencies
+func (w *Workflow) TaskStatus(taskID int64) (Status, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state, ok := w.states[taskID]
+	if !ok {
+		return Pending, fmt.Errorf("error getting status of task id %d: %w", taskID, ErrTaskNotFound)
+	}
+	return state.status, nil
+}
+
+// LastError returns the most recent error of the task with the given id and the time at which it occurred.
+// It returns false, if the task did not fail since it last succeeded or is not part of the workflow.
+func (w *Workflow) LastError(taskID int64) (error, time.Time, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state, ok := w.states[taskID]
+	if !ok || state.lastErr == nil {
+		return nil, time.Time{}, false
+	}
+	return state.lastErr.Err, state.lastErr.Time, true
+}
+
+// ErrorHistory returns the most recent errors of the task with the given id, oldest first.
+// Unlike LastError, the history is kept when the task succeeds, so that alternating failures can be told apart.
+// See WithErrorHistory.
+func (w *Workflow) ErrorHistory(taskID int64) ([]ErrorRecord, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state, ok := w.states[taskID]
+	if !ok {
+		return nil, fmt.Errorf("error getting error history of task id %d: %w", taskID, ErrTaskNotFound)
+	}
+	return append([]ErrorRecord(nil), state.history...), nil
+}
+
+// TasksByLabel returns the tasks of this workflow that have the given label, ordered by id
+func (w *Workflow) TasksByLabel(key, value string) []*Task {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
 	var result []*Task
-	for _, node := range sortedIDs {
-		result = append(result, w.tasks[node.ID()])
+	for _, t := range w.tasks {
+		if v, ok := t.labels[key]; ok && v == value {
+			result = append(result, t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].id < result[j].id
+	})
+	return result
+}
+
+// NumTasks returns the number of tasks in this workflow
+func (w *Workflow) NumTasks() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.tasks)
+}
+
+// NumDependencies returns the number of dependencies between the tasks of this workflow. Dependencies on
+// placeholders are not counted until their tasks are added, see WithLazyTasks.
+func (w *Workflow) NumDependencies() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.numDependencies
+}
+
+// taskDependencies returns the number of dependencies of the task with the given id and of other tasks on it,
+// without those of placeholders
+func (w *Workflow) taskDependencies(taskID int64) int {
+	n := 0
+	for _, nodes := range []graph.Nodes{w.graph.To(taskID), w.graph.From(taskID)} {
+		for nodes.Next() {
+			if _, ok := w.tasks[nodes.Node().ID()]; ok {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// IsEmpty returns true, if this workflow has no tasks
+func (w *Workflow) IsEmpty() bool {
+	return w.NumTasks() == 0
+}
+
+// GetOrderedTasks returns the Tasks in executable order according to their dependencies.
+// The order is cached until tasks or dependencies are added or removed. If the tasks cannot be ordered because of a
+// cycle, a CyclicError is returned. Of the given options, only those that affect the order apply, see
+// ShuffleIndependent.
+func (w *Workflow) GetOrderedTasks(opts ...RunOption) ([]*Task, error) {
+	var config runConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	order, err := w.runOrder(config)
+	if err != nil {
+		return nil, err
 	}
+	// callers may modify the result, so it must not share the cache
+	result := make([]*Task, len(order))
+	copy(result, order)
 	return result, nil
 }
 
+// OrderedTaskIDs returns the ids of the Tasks in executable order like GetOrderedTasks, e.g. to log the plan
+func (w *Workflow) OrderedTaskIDs() ([]int64, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	order, err := w.orderedTasks()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(order))
+	for i, task := range order {
+		ids[i] = task.id
+	}
+	return ids, nil
+}
+
+// Runnable returns the tasks whose dependencies are all in the given set of completed task ids and which are not
+// completed themselves, in executable order. It does not use the recorded state of the workflow, so it suits callers
+// that execute the tasks themselves, e.g. on remote agents.
+func (w *Workflow) Runnable(completed map[int64]bool) ([]*Task, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for id := range completed {
+		if _, ok := w.tasks[id]; !ok {
+			return nil, fmt.Errorf("error getting runnable tasks for completed task id %d: %w", id, ErrTaskNotFound)
+		}
+	}
+	tasks, err := w.orderedTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	var runnable []*Task
+	for _, task := range tasks {
+		if completed[task.id] {
+			continue
+		}
+		if w.dependenciesIn(task, completed) {
+			runnable = append(runnable, task)
+		}
+	}
+	return runnable, nil
+}
+
+// dependenciesIn returns true, if all dependencies of the given task are in the given set of task ids
+func (w *Workflow) dependenciesIn(task *Task, ids map[int64]bool) bool {
+	deps := w.graph.To(task.id)
+	for deps.Next() {
+		if !ids[deps.Node().ID()] {
+			return false
+		}
+	}
+	return true
+}
+
+// SetTieBreaker sets the order of tasks that do not depend on each other, e.g. by priority or description.
+// The less function reports whether task a must be executed before task b. If it is nil, independent tasks are
+// ordered by id, which is the default. The tie breaker does not apply to incrementally maintained orders,
+// see WithIncrementalOrder.
+func (w *Workflow) SetTieBreaker(less func(a, b *Task) bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tieBreaker = less
+	w.order = nil
+}
+
+// ByDescription is a tie breaker that orders tasks by description and then by id, see SetTieBreaker
+func ByDescription(a, b *Task) bool {
+	if a.desc != b.desc {
+		return a.desc < b.desc
+	}
+	return a.id < b.id
+}
+
+// orderedTasks returns the cached executable order of the Tasks, which must not be modified
+func (w *Workflow) orderedTasks() ([]*Task, error) {
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+	w.orderMu.Lock()
+	defer w.orderMu.Unlock()
+	if w.order != nil {
+		return w.order, nil
+	}
+	if w.incremental != nil {
+		order := make([]*Task, 0, len(w.incremental.seq))
+		for _, id := range w.incremental.seq {
+			order = append(order, w.tasks[id])
+		}
+		w.order = order
+		return order, nil
+	}
+	// order topographically and lexically by id, unless a tie breaker is set
+	var stabilize func([]graph.Node)
+	if w.tieBreaker != nil {
+		stabilize = func(nodes []graph.Node) {
+			sort.SliceStable(nodes, func(i, j int) bool {
+				return w.tieBreaker(w.tasks[nodes[i].ID()], w.tasks[nodes[j].ID()])
+			})
+		}
+	}
+	sortedIDs, err := topo.SortStabilized(w.graph, stabilize)
+	if err != nil {
+		return nil, w.cyclicError(err)
+	}
+	order := make([]*Task, 0, len(sortedIDs))
+	for _, node := range sortedIDs {
+		order = append(order, w.tasks[node.ID()])
+	}
+	w.order = order
+	return order, nil
+}
+
 // Reconcile executes the workflow tasks in order and returns nil, if all tasks completed successfully.
 // If a FatalError is returned, the workflow failed and cannot be retried.
-func (w *Workflow) Reconcile(ctx context.Context) error {
-	tasks, err := w.GetOrderedTasks()
+// If the workflow is aborted by an error or the cancellation of ctx, the remaining tasks that are marked
+// to always run are still executed and their errors are joined with the returned error.
+// If a task returns ErrAbort, the remaining tasks are recorded as Skipped and an AbortedError is returned.
+// If a task returns a Branch, its dependents that were not selected are recorded as Skipped,
+// as well as tasks whose dependencies were all skipped this way.
+// In continue-on-error mode, all tasks whose dependencies did not fail are executed and all errors are joined.
+// Errors of named workflows are prefixed with the workflow name.
+// The given options apply to this reconcile only, e.g. MaxTasksPerRun.
+func (w *Workflow) Reconcile(ctx context.Context, opts ...RunOption) error {
+	var config runConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	for k, v := range config.values {
+		ctx = context.WithValue(ctx, k, v)
+	}
+	return w.run(ctx, func(ctx context.Context) error {
+		return w.reconcile(ctx, config)
+	})
+}
+
+// run executes the given reconcile implementation between the OnStart and OnFinish hooks.
+// A workflow with invalid options fails before the run starts, since its clock or audit trail may be unusable.
+func (w *Workflow) run(ctx context.Context, reconcile func(ctx context.Context) error) (err error) {
+	if w.configErr != nil {
+		return w.wrapError(NewFatalError(w.configErr))
+	}
+	var (
+		onStart  func(ctx context.Context) error
+		onFinish func(ctx context.Context, report Report, err error)
+	)
+	w.update(func() {
+		onStart, onFinish = w.onStart, w.onFinish
+		w.runID++
+		// the state bag is scoped to a single reconcile, unless it was seeded or restored for this one
+		if w.stateRunID != 0 {
+			w.state = &StateBag{}
+		}
+		w.stateRunID = w.runID
+	})
+	w.audit(AuditRunStarted, nil, "", nil)
+	defer func() { w.audit(AuditRunFinished, nil, "", err) }()
+	if onFinish != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				w.finish(ctx, onFinish, w.wrapError(fmt.Errorf("panic: %v", r)))
+				panic(r)
+			}
+			w.finish(ctx, onFinish, err)
+		}()
+	}
+	if onStart != nil {
+		if err := onStart(ctx); err != nil {
+			return w.wrapError(fmt.Errorf("error on start: %w", err))
+		}
+	}
+	return w.wrapError(reconcile(ctx))
+}
+
+// RunID returns the id of the current or last reconcile of the workflow, which is 0 before the first reconcile.
+// Each Reconcile gets the next id, so it correlates reports and log records of the same reconcile.
+func (w *Workflow) RunID() uint64 {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.runID
+}
+
+// OnStart sets a hook that is called at the start of every Reconcile, e.g. to emit an audit record.
+// If it returns an error, Reconcile returns it without executing any task.
+func (w *Workflow) OnStart(hook func(ctx context.Context) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onStart = hook
+}
+
+// OnFinish sets a hook that is called exactly once at the end of every Reconcile with the report and the
+// error returned by Reconcile, including failures, cancellations and panics.
+func (w *Workflow) OnFinish(hook func(ctx context.Context, report Report, err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onFinish = hook
+}
+
+// finish calls the given OnFinish hook with the current report and the given error
+func (w *Workflow) finish(ctx context.Context, hook func(ctx context.Context, report Report, err error), err error) {
+	report, reportErr := w.Report()
+	if reportErr != nil {
+		report = Report{Workflow: w.name, RunID: w.RunID()}
+	}
+	hook(ctx, report, err)
+}
+
+func (w *Workflow) reconcile(ctx context.Context, config runConfig) error {
+	if err := w.Validate(); err != nil {
+		return NewFatalError(err)
+	}
+	if w.IsEmpty() {
+		return nil
+	}
+
+	w.mu.RLock()
+	tasks, err := w.runOrder(config)
+	w.mu.RUnlock()
 	if err != nil {
 		return NewFatalError(err)
 	}
 
+	w.update(func() {
+		w.aborted = nil
+		w.shuffleSeed = nil
+		if config.shuffle {
+			w.shuffleSeed = &config.seed
+		}
+	})
+	var errs []error
+	p := newPass()
+	p.config = config
+	p.started = w.clock.Now()
+	for len(tasks) > 0 {
+		task := tasks[0]
+		tasks = tasks[1:]
+		// the workflow runs unless it is canceled or some task returns an error
+		if err := ctx.Err(); err != nil {
+			return w.abort(ctx, joinErrors(append(errs, canceledError(err))), append([]*Task{task}, tasks...), p)
+		}
+		if config.stopped() {
+			return w.abort(ctx, joinErrors(append(errs, ErrShutdown)), append([]*Task{task}, tasks...), p)
+		}
+		p.processed[task.id] = true
+		if reason, ok := w.unselected(task, p); ok {
+			p.unselected[task.id] = true
+			w.update(func() { w.states[task.id].skipped(reason) })
+			w.audit(AuditTaskSkipped, task, reason, nil)
+			continue
+		}
+		if w.continueOnError && !task.alwaysRun && w.dependencyFailed(task, p) {
+			p.failed[task.id] = true
+			continue
+		}
+		if w.overdue(task, p) {
+			w.missDeadline(task, p)
+			if !w.continueOnError {
+				return w.abort(ctx, joinErrors(append(errs, w.stageErrors(p, tasks)...)), tasks, p)
+			}
+			continue
+		}
+		if w.budgetExhausted(task, p) {
+			remaining := append([]*Task{task}, tasks...)
+			return w.abort(ctx, joinErrors(append(errs, w.runBudgetError(remaining, p))), remaining, p)
+		}
+		if w.counts(task) {
+			p.counted++
+		}
+		err := w.runTask(ctx, task, p)
+		p.completed++
+		late := w.overdue(task, p)
+		if p.expanded {
+			// the task added tasks or dependencies, so the remaining tasks must be ordered again
+			p.expanded = false
+			var orderErr error
+			if tasks, orderErr = w.remainingTasks(p); orderErr != nil {
+				return w.abort(ctx, joinErrors(append(errs, NewFatalError(orderErr))), nil, p)
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			if !w.continueOnError || IsAborted(err) {
+				return w.abort(ctx, joinErrors(append(errs, w.stageErrors(p, tasks)...)), tasks, p)
+			}
+			p.failed[task.id] = true
+		}
+		if late && !w.continueOnError {
+			return w.abort(ctx, joinErrors(append(errs, w.stageErrors(p, tasks)...)), tasks, p)
+		}
+	}
+	return joinErrors(append(errs, w.stageErrors(p, nil)...))
+}
+
+// remainingTasks returns the tasks that were not processed in the given reconcile in executable order
+func (w *Workflow) remainingTasks(p *pass) ([]*Task, error) {
+	w.mu.RLock()
+	tasks, err := w.runOrder(p.config)
+	w.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	remaining := make([]*Task, 0, len(tasks)-len(p.processed))
 	for _, task := range tasks {
-		if cancelErr := ctx.Err(); cancelErr == nil {
-			err := task.reconcileFn(ctx, task)
-			// the workflow runs unless some task returns an error
-			if err != nil {
-				return err
+		if !p.processed[task.id] {
+			remaining = append(remaining, task)
+		}
+	}
+	return remaining, nil
+}
+
+// pass is the bookkeeping of a single reconcile
+type pass struct {
+	// guards the bookkeeping, if tasks run concurrently, see RunWithGroup
+	mu sync.Mutex
+	// tasks that were already processed, i.e. executed or skipped
+	processed map[int64]bool
+	// set if a task added tasks or dependencies
+	expanded bool
+	// tasks that failed or were not executed because a dependency failed
+	failed map[int64]bool
+	// tasks that were not selected by a branch task, value is the branch task
+	branches map[int64]*Task
+	// tasks that were skipped, because they were not selected by a branch task
+	unselected map[int64]bool
+	// start of the first task of each stage, key is the name of the stage
+	stageStarts map[string]time.Time
+	// tasks that did not finish within the deadline of their stage, key is the name of the stage
+	laggards map[string]map[int64]bool
+	// options of the reconcile
+	config runConfig
+	// start of the reconcile
+	started time.Time
+	// number of executed tasks and of those that count against the task limit of the reconcile
+	completed, counted int
+}
+
+func newPass() *pass {
+	return &pass{
+		processed:   make(map[int64]bool),
+		failed:      make(map[int64]bool),
+		branches:    make(map[int64]*Task),
+		unselected:  make(map[int64]bool),
+		stageStarts: make(map[string]time.Time),
+		laggards:    make(map[string]map[int64]bool),
+	}
+}
+
+// unselected returns a skip reason and true, if the task is not selected by a branch task
+// or all of its dependencies were skipped because they were not selected
+func (w *Workflow) unselected(task *Task, p *pass) (string, bool) {
+	if branch, ok := p.branches[task.id]; ok {
+		return fmt.Sprintf("not selected by %s", branch), true
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	deps := w.graph.To(task.id)
+	if deps.Len() == 0 {
+		return "", false
+	}
+	for deps.Next() {
+		if !p.unselected[deps.Node().ID()] {
+			return "", false
+		}
+	}
+	return "all dependencies not selected by branch", true
+}
+
+// dependencyFailed returns true, if one of the task's dependencies failed, ignoring soft dependencies
+func (w *Workflow) dependencyFailed(task *Task, p *pass) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	deps := w.graph.To(task.id)
+	for deps.Next() {
+		depID := deps.Node().ID()
+		if p.failed[depID] && (task.barrier || !w.softEdges[edgeID{from: depID, to: task.id}]) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors returns nil for no errors, the error itself for a single error or the joined errors otherwise
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// abort runs the remaining tasks that are marked to always run and returns the given error
+// together with their errors. If ctx is already done, they run with a grace context instead.
+// If the workflow was aborted by a task, the other remaining tasks are recorded as Skipped.
+func (w *Workflow) abort(ctx context.Context, err error, remaining []*Task, p *pass) error {
+	var abortedErr AbortedError
+	aborted := errors.As(err, &abortedErr)
+	if aborted {
+		w.update(func() { w.aborted = &abortedErr })
+	}
+
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), w.finalizerGracePeriod)
+		defer cancel()
+	}
+
+	errs := []error{err}
+	for _, task := range remaining {
+		if !task.alwaysRun {
+			if aborted {
+				w.update(func() { w.states[task.id].skipped("aborted") })
+				w.audit(AuditTaskSkipped, task, "aborted", nil)
 			}
+			continue
+		}
+		if err := w.runTask(ctx, task, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// runTask executes the reconcile function of the given task and records the outcome
+func (w *Workflow) runTask(ctx context.Context, task *Task, p *pass) error {
+	var state *taskState
+	var previous Status
+	var attempt int
+	w.update(func() {
+		state = w.states[task.id]
+		previous = state.status
+		state.attempts++
+		attempt = state.attempts
+		state.status = Running
+		state.slaBreached = false
+	})
+	w.audit(AuditTaskStarted, task, "", nil)
+
+	writer := w.newLineWriter(task)
+	ctx = context.WithValue(ctx, taskContextKey{}, &taskContext{w: w, task: task, p: p, writer: writer})
+	stopSLA := w.watchSLA(task, state)
+	err := w.invoke(ctx, task)
+	stopSLA()
+	// the output of the task is best effort and does not affect its result
+	_ = writer.flush()
+	if err == nil {
+		w.update(func() {
+			state.succeeded()
+			w.progress(state, previous)
+		})
+		w.audit(AuditTaskSucceeded, task, "", nil)
+		return nil
+	}
+	if errors.Is(err, ErrSkipTask) {
+		reason := reasonOf(err, ErrSkipTask)
+		w.update(func() { state.skipped(reason) })
+		w.audit(AuditTaskSkipped, task, reason, nil)
+		return nil
+	}
+	var branch branchError
+	if errors.As(err, &branch) {
+		if err := w.selectBranch(task, branch.selected, p); err != nil {
+			w.update(func() { state.failed(err, w.clock.Now(), w.errorHistorySize) })
+			w.audit(AuditTaskFailed, task, "", err)
+			return TaskError{
+				TaskID:      task.id,
+				Description: task.desc,
+				Attempt:     attempt,
+				Err:         err,
+			}
+		}
+		w.update(func() {
+			state.succeeded()
+			w.progress(state, previous)
+		})
+		w.audit(AuditTaskSucceeded, task, "", nil)
+		return nil
+	}
+	if errors.Is(err, ErrAbort) {
+		abortedErr := AbortedError{
+			TaskID:      task.id,
+			Description: task.desc,
+			Reason:      reasonOf(err, ErrAbort),
+			Err:         err,
+		}
+		reason := strings.TrimSuffix("aborted: "+abortedErr.Reason, ": ")
+		w.update(func() { state.skipped(reason) })
+		w.audit(AuditTaskSkipped, task, reason, nil)
+		return abortedErr
+	}
+
+	timeout := errors.Is(err, context.DeadlineExceeded)
+	// the cancellation of the reconcile is always retryable and neither spends retries nor counts as a stall,
+	// so the classifier only decides about cancellations within the task
+	canceled := errors.Is(err, context.Canceled) && errors.Is(ctx.Err(), context.Canceled)
+	severity := SeverityRetryable
+	if !canceled {
+		severity = w.classifier(task, err)
+	}
+	if timeout && task.fatalOnTimeout {
+		severity = SeverityFatal
+	}
+	err = classify(severity, err)
+	var stalled bool
+	w.update(func() {
+		if !IsFatal(err) && !canceled {
+			err = w.spendTaskRetry(task, state, err)
+		}
+		if !IsFatal(err) && !canceled {
+			err = w.spendRetry(task, err)
+		}
+		if !IsFatal(err) && !canceled {
+			err, stalled = w.detectStall(task, state, err)
+		}
+		state.failed(err, w.clock.Now(), w.errorHistorySize)
+	})
+	w.audit(AuditTaskFailed, task, "", err)
+	if stalled && w.stall.OnStall != nil {
+		w.stall.OnStall(task, w.stall.Threshold, err)
+	}
+	return TaskError{
+		TaskID:      task.id,
+		Description: task.desc,
+		Attempt:     attempt,
+		Err:         err,
+		Timeout:     timeout,
+		Canceled:    canceled,
+	}
+}
+
+// update applies changes to the recorded state while holding the lock
+func (w *Workflow) update(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fn()
+}
+
+// selectBranch records all dependents of the given branch task except the selected ones as unselected.
+// It returns a FatalError, if a selected task is not a dependent of the branch task.
+func (w *Workflow) selectBranch(task *Task, selected []int64, p *pass) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	isSelected := make(map[int64]bool)
+	for _, id := range selected {
+		if !w.graph.HasEdgeFromTo(task.id, id) {
+			return NewFatalError(fmt.Errorf("branch selects task id %d, which is no dependent of %s", id, task))
+		}
+		isSelected[id] = true
+	}
+	dependents := w.graph.From(task.id)
+	for dependents.Next() {
+		if id := dependents.Node().ID(); !isSelected[id] {
+			p.branches[id] = task
 		}
 	}
 	return nil
 }
 
-// Visualize returns a string visualizing the sequence of tasks to be executed
-func (w *Workflow) Visualize() (string, error) {
+// invoke executes the reconcile function of the given task, unless its circuit breaker is open
+// or its skip predicate applies
+func (w *Workflow) invoke(ctx context.Context, task *Task) error {
+	if task.reconcileFn == nil {
+		return NewFatalError(fmt.Errorf("%w: reconcile function is nil", ErrInvalidTask))
+	}
+	if task.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, task.timeout)
+		defer cancel()
+	}
+	if w.inSync(ctx, task) {
+		return nil
+	}
+	if task.breaker != nil {
+		w.mu.Lock()
+		state := w.states[task.id]
+		err := state.breaker.allow(task.breaker, w.clock.Now())
+		w.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		err = w.invokeFn(ctx, task)
+		w.update(func() { state.breaker.record(task.breaker, w.clock.Now(), err) })
+		return err
+	}
+	return w.invokeFn(ctx, task)
+}
+
+// invokeFn executes the reconcile function of the given task wrapped by middleware, unless its skip predicate applies
+// or its fingerprint is unchanged
+func (w *Workflow) invokeFn(ctx context.Context, task *Task) error {
+	if task.skipIf != nil {
+		skip, err := task.skipIf(ctx, task)
+		if err != nil {
+			return fmt.Errorf("error evaluating skip predicate: %w", err)
+		}
+		if skip {
+			return SkipTask("skip predicate applies")
+		}
+	}
+	if task.fingerprintFn == nil {
+		return w.invokeHooked(ctx, task)
+	}
+
+	// an error computing the fingerprint is no reason to not run the task
+	fingerprint, fingerprintErr := task.fingerprintFn(ctx, task)
+	w.mu.RLock()
+	state := w.states[task.id]
+	unchanged := fingerprintErr == nil && state.fingerprint != "" && fingerprint == state.fingerprint
+	w.mu.RUnlock()
+	if unchanged {
+		return SkipTask("unchanged")
+	}
+	err := w.invokeHooked(ctx, task)
+	if err == nil && fingerprintErr == nil {
+		w.update(func() { state.fingerprint = fingerprint })
+	}
+	return err
+}
+
+// invokeHooked executes the reconcile function of the given task wrapped by middleware between the
+// BeforeTask and AfterTask hooks
+func (w *Workflow) invokeHooked(ctx context.Context, task *Task) (err error) {
+	w.mu.RLock()
+	beforeTask, afterTask, middleware := w.beforeTask, w.afterTask, w.middleware
+	w.mu.RUnlock()
+	if beforeTask != nil {
+		if err := beforeTask(ctx, task); err != nil {
+			return fmt.Errorf("error before task: %w", err)
+		}
+	}
+	if afterTask != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				afterTask(ctx, task, fmt.Errorf("panic: %v", r))
+				panic(r)
+			}
+			afterTask(ctx, task, err)
+		}()
+	}
+	return chain(task.reconcileFn, middleware, task.middleware)(ctx, task)
+}
+
+// SetBeforeTask sets a hook that is called before the reconcile function of every task and its middleware,
+// e.g. to acquire a lock. An error of the hook is treated as the task's error and the task is not executed.
+func (w *Workflow) SetBeforeTask(hook func(ctx context.Context, task *Task) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.beforeTask = hook
+}
+
+// SetAfterTask sets a hook that is called after the reconcile function of every task and its middleware with
+// its error, e.g. to release a lock. It is called even if the task failed or panicked, but not if the
+// BeforeTask hook failed.
+func (w *Workflow) SetAfterTask(hook func(ctx context.Context, task *Task, err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.afterTask = hook
+}
+
+// Teardown runs the compensation functions of all tasks recorded as Succeeded in reverse execution order,
+// e.g. to clean up partially created resources after the workflow failed. Tasks without compensation are skipped.
+// Teardown does not stop at the first error, but returns all errors joined.
+// Successfully compensated tasks are recorded as Pending again.
+func (w *Workflow) Teardown(ctx context.Context) error {
 	tasks, err := w.GetOrderedTasks()
+	if err != nil {
+		return w.wrapError(NewFatalError(err))
+	}
+
+	var errs []error
+	for i := len(tasks) - 1; i >= 0; i-- {
+		task := tasks[i]
+		w.mu.RLock()
+		state := w.states[task.id]
+		succeeded := state.status == Succeeded
+		w.mu.RUnlock()
+		if task.compensateFn == nil || !succeeded {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := task.compensateFn(ctx, task); err != nil {
+			errs = append(errs, fmt.Errorf("compensation of %s failed: %w", task, err))
+			continue
+		}
+		w.update(state.reset)
+	}
+	return w.wrapError(errors.Join(errs...))
+}
+
+// ResetStatus discards the recorded state of all tasks and the spent retry budget, so that the workflow starts
+// over as if it was never reconciled.
+func (w *Workflow) ResetStatus() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id := range w.states {
+		w.states[id] = &taskState{}
+	}
+	w.retriesSpent = make(map[int64]int)
+	w.aborted = nil
+	w.shuffleSeed = nil
+}
+
+// wrapError prefixes the given error with the workflow name, if the workflow has a name
+func (w *Workflow) wrapError(err error) error {
+	if err == nil || w.name == "" {
+		return err
+	}
+	return fmt.Errorf("workflow %s: %w", w.name, err)
+}
+
+// Visualize returns a string visualizing the sequence of tasks to be executed, e.g. "task 1 (a) >> task 2 (b)".
+// Without options, the tasks are rendered by Task.String followed by their markers and separated by
+// DefaultVisualizeSeparator.
+func (w *Workflow) Visualize(opts ...VisualizeOption) (string, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	tasks, err := w.orderedTasks()
 	if err != nil {
 		return "", err
 	}
 
+	// pre-size for the descriptions, ids, separators and usual decorations
+	size := 0
+	for _, t := range tasks {
+		size += len(t.desc) + 32
+	}
 	var result strings.Builder
-	for i, t := range tasks {
-		if i > 0 {
-			result.WriteString(" >> ")
-		}
-		result.WriteString(t.String())
+	result.Grow(size)
+	if err := w.visualize(&result, tasks, 0, newVisualizeConfig(opts)); err != nil {
+		return "", err
 	}
 	return result.String(), nil
 }
 
+// VisualizeTo writes the visualization of the sequence of tasks to be executed to out, see Visualize.
+// If limit is positive, only the first limit tasks are written, followed by the number of omitted tasks.
+func (w *Workflow) VisualizeTo(out io.Writer, limit int, opts ...VisualizeOption) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	tasks, err := w.orderedTasks()
+	if err != nil {
+		return err
+	}
+	return w.visualize(out, tasks, limit, newVisualizeConfig(opts))
+}
+
 // Fn is the reconcile function that executes the task's logic to achieve the desired outcome.
 // If the task is successful, it returns nil.
 // If the task returns a FatalError, it indicates that it failed and cannot be retried.
 // If the task returns any other error, it failed but can be retried later.
 type Fn func(ctx context.Context, task *Task) error
 
+// Middleware wraps a reconcile function, e.g. to add cross-cutting concerns like logging.
+// It receives the same context and task as the reconcile function and can return an error without calling it.
+type Middleware func(next Fn) Fn
+
+// Use adds middleware that wraps the reconcile function of every task at execution time.
+// Workflow middleware wraps task middleware, see WithMiddleware, and both are applied in the given order,
+// i.e. the first middleware is the outermost. Middleware added during a reconcile applies to the tasks executed
+// afterwards.
+func (w *Workflow) Use(mw ...Middleware) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.middleware = append(w.middleware, mw...)
+}
+
+// chain wraps fn with the given middleware lists, the first middleware of the first list being the outermost
+func chain(fn Fn, mws ...[]Middleware) Fn {
+	for i := len(mws) - 1; i >= 0; i-- {
+		for j := len(mws[i]) - 1; j >= 0; j-- {
+			fn = mws[i][j](fn)
+		}
+	}
+	return fn
+}
+
+// SkipPredicate decides at reconcile time, whether the task is skipped instead of executing its reconcile function.
+type SkipPredicate func(ctx context.Context, task *Task) (bool, error)
+
+// FingerprintFn computes a fingerprint of the inputs of a task, e.g. a hash of the files it renders.
+type FingerprintFn func(ctx context.Context, task *Task) (string, error)
+
 // FatalError indicates that the execution of the task encountered an error that is fatal and final, i.e. the task cannot be retried.
 type FatalError struct {
 	err error
 }
 
 // NewFatalError creates a new FatalError with the given error.
+// A nil error results in an "unknown fatal error".
 func NewFatalError(err error) FatalError {
 	return FatalError{
 		err: err,
@@ -156,25 +1466,124 @@ func (e FatalError) Error() string {
 	return fmt.Sprintf("fatal error: %v", e.err.Error())
 }
 
+// NewFatalErrors creates a new FatalError with multiple causes, e.g. the errors of several tasks that failed
+// fatally in continue-on-error mode. The causes are joined like with errors.Join, so that errors.Is and errors.As
+// find each of them, and the message lists all of them. Causes that are FatalErrors themselves are unwrapped.
+func NewFatalErrors(errs ...error) FatalError {
+	causes := make([]error, 0, len(errs))
+	for _, err := range errs {
+		if fatalErr, ok := err.(FatalError); ok {
+			err = fatalErr.err
+		}
+		if err != nil {
+			causes = append(causes, err)
+		}
+	}
+	return NewFatalError(joinErrors(causes))
+}
+
+// Unwrap returns the cause of the FatalError, so that it can be inspected with errors.Is and errors.As.
+func (e FatalError) Unwrap() error {
+	return e.err
+}
+
+// Causes returns the causes of the FatalError, which are more than one, if it was created by NewFatalErrors
+func (e FatalError) Causes() []error {
+	if multi, ok := e.err.(interface{ Unwrap() []error }); ok {
+		return multi.Unwrap()
+	}
+	if e.err == nil {
+		return nil
+	}
+	return []error{e.err}
+}
+
+// IsFatal returns true, if the given error is or wraps a FatalError.
+func IsFatal(err error) bool {
+	var fatalErr FatalError
+	return errors.As(err, &fatalErr)
+}
+
 // Task models a unit of work with dependencies to other tasks
 type Task struct {
-	id          int64
-	desc        string
-	deps        []int64
-	reconcileFn Fn
+	id             int64
+	desc           string
+	deps           []int64
+	labels         map[string]string
+	values         map[string]any
+	reconcileFn    Fn
+	compensateFn   Fn
+	alwaysRun      bool
+	skipIf         SkipPredicate
+	fingerprintFn  FingerprintFn
+	fatalOnTimeout bool
+	middleware     []Middleware
+	breaker        *breakerConfig
+	sla            *slaConfig
+	wait           *waitConfig
+	// set for tasks that are created by helpers like Group instead of the user
+	synthetic bool
+	// set for barrier tasks, see NewBarrierTask
+	barrier bool
+	// timeout of each invocation of the reconcile function, none if not positive
+	timeout time.Duration
+	// number of retryable failures before a failure is fatal, unlimited if negative
+	retries int
+	// invalid options, that are reported when the task is added
+	problems []string
+	// key the id is derived from, see NewKeyedTask
+	key string
+	// optional verification of the desired state, see WithCheck
+	check CheckFn
 }
 
-// NewTask creates a new task specifying the id, description and reconcile function
-func NewTask(id int64, desc string, fn Fn) *Task {
+// NewTask creates a new task specifying the id, description, reconcile function and optional task options
+func NewTask(id int64, desc string, fn Fn, opts ...TaskOption) *Task {
 	task := &Task{
 		id:          id,
 		desc:        desc,
 		reconcileFn: fn,
+		retries:     -1,
 		deps:        []int64{},
+		labels:      make(map[string]string),
+		values:      make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(task)
 	}
 	return task
 }
 
+// ID returns the id of the task
+func (j *Task) ID() int64 {
+	return j.id
+}
+
+// Description returns the description of the task
+func (j *Task) Description() string {
+	return j.desc
+}
+
+// Labels returns a copy of the labels of the task, which are fixed at construction, see the Labels option
+func (j *Task) Labels() map[string]string {
+	labels := make(map[string]string, len(j.labels))
+	for k, v := range j.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Value returns the metadata value of the task for the given key or nil, if there is none
+func (j *Task) Value(key string) any {
+	return j.values[key]
+}
+
+// TaskValue returns the metadata value of the given task for the given key, if it is present and of type T
+func TaskValue[T any](task *Task, key string) (T, bool) {
+	v, ok := task.values[key].(T)
+	return v, ok
+}
+
 func (j *Task) String() string {
 	return fmt.Sprintf("task %d (%s)", j.id, j.desc)
 }
@@ -0,0 +1,790 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nop(context.Context, *Task) error { return nil }
+
+func TestNumDependencies(t *testing.T) {
+	w := NewWorkflow(WithLazyTasks())
+	t1, t2, t3 := NewTask(1, "a", nop), NewTask(2, "b", nop), NewTask(3, "c", nop)
+	if err := w.AddTasks([]*Task{t1, t2}); err != nil {
+		t.Fatal(err)
+	}
+	if w.IsEmpty() || w.NumTasks() != 2 || w.NumDependencies() != 0 {
+		t.Fatalf("got %d tasks and %d dependencies", w.NumTasks(), w.NumDependencies())
+	}
+	if err := w.AddDependencyByID(2, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	// the dependency on the placeholder of task 3 is counted once the task is added
+	if n := w.NumDependencies(); n != 1 {
+		t.Fatalf("expected 1 dependency, got %d", n)
+	}
+	if err := w.AddTask(t3); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(t3, t1); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.NumDependencies(); n != 3 {
+		t.Fatalf("expected 3 dependencies, got %d", n)
+	}
+	if err := w.RemoveDependency(t2, t1, t1); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.NumDependencies(); n != 2 {
+		t.Fatalf("expected 2 dependencies, got %d", n)
+	}
+	if err := w.RemoveTask(t3); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.NumDependencies(); n != 0 || w.NumTasks() != 2 {
+		t.Fatalf("expected no dependencies, got %d", n)
+	}
+}
+
+func TestAreIndependent(t *testing.T) {
+	// diamond: b and c depend on a, d depends on b and c
+	w := NewWorkflow()
+	a, b, c, d := NewTask(1, "a", nop), NewTask(2, "b", nop), NewTask(3, "c", nop), NewTask(4, "d", nop)
+	if err := w.AddTasks([]*Task{a, b, c, d}); err != nil {
+		t.Fatal(err)
+	}
+	for _, deps := range [][]*Task{{b, a}, {c, a}, {d, b, c}} {
+		if err := w.AddDependency(deps[0], deps[1:]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		a, b *Task
+		want bool
+	}{
+		{a: b, b: c, want: true},
+		{a: c, b: b, want: true},
+		{a: a, b: d, want: false},
+		{a: d, b: a, want: false},
+		{a: a, b: b, want: false},
+		{a: b, b: d, want: false},
+		{a: b, b: b, want: false},
+	}
+	for _, tt := range tests {
+		got, err := w.AreIndependent(tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expected AreIndependent(%s, %s) to be %t", tt.a, tt.b, tt.want)
+		}
+	}
+	if _, err := w.AreIndependent(a, NewTask(5, "e", nop)); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestReadWhileTaskBlocked(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	w := NewWorkflow()
+	err := w.AddTasks([]*Task{
+		NewTask(1, "a", func(context.Context, *Task) error {
+			close(started)
+			<-release
+			return nil
+		}),
+		NewTask(2, "b", nop),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyByID(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(context.Background()) }()
+	<-started
+
+	read := make(chan Status, 1)
+	go func() {
+		// read-only methods must not wait for the blocked task
+		if _, err := w.Visualize(); err != nil {
+			t.Error(err)
+		}
+		if _, err := w.GetOrderedTasks(); err != nil {
+			t.Error(err)
+		}
+		if _, err := w.Report(); err != nil {
+			t.Error(err)
+		}
+		status, err := w.TaskStatus(1)
+		if err != nil {
+			t.Error(err)
+		}
+		read <- status
+	}()
+	select {
+	case status := <-read:
+		if status != Running {
+			t.Errorf("expected status %s, got %s", Running, status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the status read to complete while the task is blocked")
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordingMiddleware returns middleware that appends its name to calls before and after the next function
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Fn) Fn {
+		return func(ctx context.Context, task *Task) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, task)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	w := NewWorkflow()
+	w.Use(recordingMiddleware("w1", &calls), recordingMiddleware("w2", &calls))
+	err := w.AddTask(NewTask(1, "a", func(context.Context, *Task) error {
+		calls = append(calls, "fn")
+		return nil
+	}, WithMiddleware(recordingMiddleware("t1", &calls), recordingMiddleware("t2", &calls))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"w1 before", "w2 before", "t1 before", "t2 before", "fn", "t2 after", "t1 after", "w2 after", "w1 after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	denied := errors.New("token expired")
+	called := false
+	w := NewWorkflow()
+	w.Use(func(Fn) Fn {
+		return func(context.Context, *Task) error { return denied }
+	})
+	err := w.AddTask(NewTask(1, "a", func(context.Context, *Task) error {
+		called = true
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); !errors.Is(err, denied) || called {
+		t.Fatalf("expected the middleware to fail the task without calling it, got %v", err)
+	}
+}
+
+func TestMiddlewareFatalErrorPassthrough(t *testing.T) {
+	cause := errors.New("disk not found")
+	fatal := NewFatalError(cause)
+	var seen error
+	w := NewWorkflow()
+	w.Use(func(next Fn) Fn {
+		return func(ctx context.Context, task *Task) error {
+			seen = next(ctx, task)
+			return seen
+		}
+	})
+	if err := w.AddTask(NewTask(1, "a", func(context.Context, *Task) error { return fatal })); err != nil {
+		t.Fatal(err)
+	}
+	err := w.Reconcile(context.Background())
+	if seen != error(fatal) {
+		t.Fatalf("expected the middleware to receive the FatalError untouched, got %v", seen)
+	}
+	var fatalErr FatalError
+	if !errors.As(err, &fatalErr) || fatalErr != fatal || !errors.Is(err, cause) {
+		t.Fatalf("expected the FatalError to pass through, got %v", err)
+	}
+}
+
+func BenchmarkGetOrderedTasks(b *testing.B) {
+	w := layeredWorkflow(b, 5000, 50)
+	// the order is cached by the first call
+	if _, err := w.GetOrderedTasks(); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.GetOrderedTasks(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVisualize(b *testing.B) {
+	w := layeredWorkflow(b, 5000, 50)
+	// the order is cached by the first call
+	if _, err := w.GetOrderedTasks(); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Visualize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestVisualizeDefaultUnchanged(t *testing.T) {
+	w := NewWorkflow()
+	err := w.AddTasks([]*Task{
+		NewTask(1, "create V1", nop),
+		NewTask(2, "render config", func(context.Context, *Task) error { return SkipTask("unchanged") }),
+		NewBarrierTask(3, "await uploads", nop),
+		NewTask(4, "a rather long description of the final task", nop),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, deps := range map[int64][]int64{2: {1}, 3: {1, 2}, 4: {3}} {
+		if err := w.AddDependencyByID(id, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the output without options must stay byte-identical to the one before Visualize accepted options
+	expected := "task 1 (create V1) >> task 2 (render config) [skipped: unchanged] >> task 3 (await uploads) [barrier] >> " +
+		"task 4 (a rather long description of the final task)"
+	s, err := w.Visualize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != expected {
+		t.Fatalf("expected %q, got %q", expected, s)
+	}
+	var out strings.Builder
+	if err := w.VisualizeTo(&out, 0); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != expected {
+		t.Fatalf("expected %q from VisualizeTo, got %q", expected, out.String())
+	}
+}
+
+func TestBranch(t *testing.T) {
+	var executed []int64
+	record := func(_ context.Context, task *Task) error {
+		executed = append(executed, task.id)
+		return nil
+	}
+	w := NewWorkflow()
+	err := w.AddTasks([]*Task{
+		NewTask(1, "choose", func(context.Context, *Task) error { return Branch(2) }),
+		NewTask(2, "selected", record),
+		NewTask(3, "not selected", record),
+		NewTask(4, "after not selected", record),
+		NewTask(5, "after both", record),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, deps := range map[int64][]int64{2: {1}, 3: {1}, 4: {3}, 5: {2, 3}} {
+		if err := w.AddDependencyByID(id, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(executed, []int64{2, 5}) {
+		t.Fatalf("expected the selected task and the task depending on it to run, got %v", executed)
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]struct {
+		status Status
+		reason string
+	}{
+		1: {Succeeded, ""},
+		2: {Succeeded, ""},
+		3: {Skipped, "not selected by task 1 (choose)"},
+		4: {Skipped, "all dependencies not selected by branch"},
+		5: {Succeeded, ""},
+	}
+	for _, task := range report.Tasks {
+		if task.Status != want[task.ID].status || task.Reason != want[task.ID].reason {
+			t.Errorf("expected task %d to be %s (%s), got %s (%s)", task.ID, want[task.ID].status,
+				want[task.ID].reason, task.Status, task.Reason)
+		}
+	}
+}
+
+func TestBranchSelectsNoDependent(t *testing.T) {
+	w := NewWorkflow()
+	err := w.AddTasks([]*Task{
+		NewTask(1, "choose", func(context.Context, *Task) error { return Branch(3) }),
+		NewTask(2, "dependent", nop),
+		NewTask(3, "independent", nop),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyByID(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); !IsFatal(err) {
+		t.Fatalf("expected a fatal error for a task that is no dependent, got %v", err)
+	}
+}
+
+func TestTeardown(t *testing.T) {
+	errV1, errV3 := errors.New("V1 is in use"), errors.New("V3 is in use")
+	var compensated []int64
+	compensate := func(err error) Fn {
+		return func(_ context.Context, task *Task) error {
+			compensated = append(compensated, task.id)
+			return err
+		}
+	}
+	w := NewWorkflow(WithName("machine-1"))
+	_, _, err := Chain(w,
+		NewTask(1, "create V1", nop, WithCompensation(compensate(errV1))),
+		NewTask(2, "create V2", nop, WithCompensation(compensate(nil))),
+		NewTask(3, "create V3", nop, WithCompensation(compensate(errV3))),
+		NewTask(4, "create V4", func(context.Context, *Task) error { return errors.New("quota exceeded") },
+			WithCompensation(compensate(nil))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err == nil {
+		t.Fatal("expected task 4 to fail")
+	}
+
+	err = w.Teardown(context.Background())
+	if !reflect.DeepEqual(compensated, []int64{3, 2, 1}) {
+		t.Fatalf("expected the succeeded tasks to be compensated in reverse order, got %v", compensated)
+	}
+	if !errors.Is(err, errV1) || !errors.Is(err, errV3) || !strings.HasPrefix(err.Error(), "workflow machine-1: ") {
+		t.Fatalf("expected the joined errors of the compensations prefixed with the workflow, got %v", err)
+	}
+	want := map[int64]Status{1: Succeeded, 2: Pending, 3: Succeeded, 4: Failed}
+	for id, status := range want {
+		if got, err := w.TaskStatus(id); err != nil || got != status {
+			t.Errorf("expected task %d to be %s, got %s", id, status, got)
+		}
+	}
+}
+
+func TestRunnable(t *testing.T) {
+	// 1 -> {2, 3} -> 4
+	w := NewWorkflow()
+	for id := int64(1); id <= 4; id++ {
+		if err := w.AddTask(NewTask(id, fmt.Sprintf("task %d", id), nop)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, deps := range map[int64][]int64{2: {1}, 3: {1}, 4: {2, 3}} {
+		if err := w.AddDependencyByID(id, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		completed []int64
+		want      []int64
+	}{
+		{completed: nil, want: []int64{1}},
+		{completed: []int64{1}, want: []int64{2, 3}},
+		{completed: []int64{1, 3}, want: []int64{2}},
+		{completed: []int64{1, 2, 3}, want: []int64{4}},
+		{completed: []int64{1, 2, 3, 4}, want: nil},
+	}
+	for _, tt := range tests {
+		completed := make(map[int64]bool)
+		for _, id := range tt.completed {
+			completed[id] = true
+		}
+		runnable, err := w.Runnable(completed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, task := range runnable {
+			ids = append(ids, task.id)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("completed %v: expected runnable tasks %v, got %v", tt.completed, tt.want, ids)
+		}
+	}
+
+	if _, err := w.Runnable(map[int64]bool{5: true}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestSoftDependency(t *testing.T) {
+	benchmarkErr := errors.New("benchmark timed out")
+	var executed []int64
+	record := func(err error) Fn {
+		return func(_ context.Context, task *Task) error {
+			executed = append(executed, task.id)
+			return err
+		}
+	}
+	w := NewWorkflow(WithContinueOnError())
+	benchmark := NewTask(1, "run benchmark", record(benchmarkErr))
+	upload := NewTask(2, "upload metrics", record(nil))
+	publish := NewTask(3, "publish results", record(nil))
+	if err := w.AddTasks([]*Task{benchmark, upload, publish}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddSoftDependency(upload, benchmark); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(publish, benchmark); err != nil {
+		t.Fatal(err)
+	}
+	if !w.IsSoftDependency(upload, benchmark) || w.IsSoftDependency(publish, benchmark) {
+		t.Fatal("expected only the dependency of task 2 to be soft")
+	}
+
+	if err := w.Reconcile(context.Background()); !errors.Is(err, benchmarkErr) {
+		t.Fatalf("expected the error of task 1, got %v", err)
+	}
+	if !reflect.DeepEqual(executed, []int64{1, 2}) {
+		t.Fatalf("expected the soft dependent to run after the failed task and the hard one not to, got %v", executed)
+	}
+	for id, want := range map[int64]Status{1: Failed, 2: Succeeded, 3: Pending} {
+		if got, err := w.TaskStatus(id); err != nil || got != want {
+			t.Errorf("expected task %d to be %s, got %s", id, want, got)
+		}
+	}
+}
+
+func TestSkipIf(t *testing.T) {
+	predicateErr := errors.New("inventory unavailable")
+	tests := []struct {
+		name     string
+		skip     bool
+		err      error
+		want     map[int64]Status
+		executed []int64
+	}{
+		{name: "applies", skip: true, want: map[int64]Status{1: Skipped, 2: Succeeded}, executed: []int64{2}},
+		{name: "does not apply", want: map[int64]Status{1: Succeeded, 2: Succeeded}, executed: []int64{1, 2}},
+		{name: "error", err: predicateErr, want: map[int64]Status{1: Failed, 2: Pending}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var executed []int64
+			record := func(_ context.Context, task *Task) error {
+				executed = append(executed, task.id)
+				return nil
+			}
+			w := NewWorkflow()
+			gpu := NewTask(1, "configure GPU", record, SkipIf(func(context.Context, *Task) (bool, error) {
+				return tt.skip, tt.err
+			}))
+			boot := NewTask(2, "boot", record)
+			if err := w.AddTasks([]*Task{gpu, boot}); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.AddDependency(boot, gpu); err != nil {
+				t.Fatal(err)
+			}
+
+			err := w.Reconcile(context.Background())
+			if tt.err == nil && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != nil && (!errors.Is(err, tt.err) || IsFatal(err)) {
+				t.Fatalf("expected a retryable error matching the error of the predicate, got %v", err)
+			}
+			if !reflect.DeepEqual(executed, tt.executed) {
+				t.Fatalf("expected the tasks %v to be executed, got %v", tt.executed, executed)
+			}
+			for id, want := range tt.want {
+				if got, err := w.TaskStatus(id); err != nil || got != want {
+					t.Errorf("expected task %d to be %s, got %s", id, want, got)
+				}
+			}
+			report, err := w.Report()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason := report.Tasks[0].Reason; tt.skip && reason != "skip predicate applies" {
+				t.Errorf("expected the reason of the skipped task to be reported, got %q", reason)
+			}
+		})
+	}
+}
+
+func TestAddDependencyAgain(t *testing.T) {
+	w := NewWorkflow()
+	t1, t2 := NewTask(1, "a", nop), NewTask(2, "b", nop)
+	if err := w.AddTasks([]*Task{t1, t2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddSoftDependency(t2, t1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyWithLabel(t2, t1, "needs a"); err != nil {
+		t.Fatalf("expected adding an existing dependency again to succeed, got %v", err)
+	}
+	if w.NumDependencies() != 1 {
+		t.Fatalf("expected 1 dependency, got %d", w.NumDependencies())
+	}
+	if w.IsSoftDependency(t2, t1) || w.DependencyLabel(t2, t1) != "needs a" {
+		t.Fatal("expected the dependency to take over the kind and label of the last call")
+	}
+	if err := w.AddDependency(t2, t1); err != nil {
+		t.Fatal(err)
+	}
+	if w.DependencyLabel(t2, t1) != "needs a" {
+		t.Fatal("expected the label to be kept by an unlabeled call")
+	}
+	if err := w.RemoveDependency(t2, t1); err != nil {
+		t.Fatal(err)
+	}
+	if w.NumDependencies() != 0 {
+		t.Fatalf("expected no dependency after removing it once, got %d", w.NumDependencies())
+	}
+}
+
+func TestUseDuringReconcile(t *testing.T) {
+	var calls []string
+	w := NewWorkflow()
+	t1 := NewTask(1, "a", func(context.Context, *Task) error {
+		w.Use(recordingMiddleware("added", &calls))
+		return nil
+	})
+	t2 := NewTask(2, "b", func(context.Context, *Task) error {
+		calls = append(calls, "fn")
+		return nil
+	})
+	if err := w.AddTasks([]*Task{t1, t2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(t2, t1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"added before", "fn", "added after"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected the added middleware to wrap the later task, got %v", calls)
+	}
+}
+
+func TestTaskHooks(t *testing.T) {
+	failure := errors.New("quota exceeded")
+	var calls []string
+	w := NewWorkflow()
+	w.SetBeforeTask(func(_ context.Context, task *Task) error {
+		calls = append(calls, fmt.Sprintf("before %d", task.id))
+		return nil
+	})
+	w.SetAfterTask(func(_ context.Context, task *Task, err error) {
+		calls = append(calls, fmt.Sprintf("after %d: %v", task.id, err))
+	})
+	w.Use(recordingMiddleware("mw", &calls))
+	t1 := NewTask(1, "a", func(context.Context, *Task) error {
+		calls = append(calls, "fn 1")
+		return nil
+	})
+	t2 := NewTask(2, "b", func(context.Context, *Task) error {
+		calls = append(calls, "fn 2")
+		return failure
+	})
+	if err := w.AddTasks([]*Task{t1, t2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(t2, t1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); !errors.Is(err, failure) {
+		t.Fatalf("expected the error of task 2, got %v", err)
+	}
+	want := []string{
+		"before 1", "mw before", "fn 1", "mw after", "after 1: <nil>",
+		"before 2", "mw before", "fn 2", "mw after", "after 2: quota exceeded",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("expected calls %q, got %q", want, calls)
+	}
+}
+
+func TestBeforeTaskError(t *testing.T) {
+	locked := NewFatalError(errors.New("lock held by another controller"))
+	executed, after := false, false
+	w := NewWorkflow()
+	w.SetBeforeTask(func(context.Context, *Task) error { return locked })
+	w.SetAfterTask(func(context.Context, *Task, error) { after = true })
+	if err := w.AddTask(NewTask(1, "a", func(context.Context, *Task) error {
+		executed = true
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); !errors.Is(err, locked) || !IsFatal(err) {
+		t.Fatalf("expected the error of the hook as the task's error, got %v", err)
+	}
+	if executed || after {
+		t.Fatalf("expected neither the task nor the AfterTask hook to run, got %t and %t", executed, after)
+	}
+	if status, err := w.TaskStatus(1); err != nil || status != Failed {
+		t.Fatalf("expected task 1 to be failed, got %s", status)
+	}
+}
+
+func TestAfterTaskOnPanic(t *testing.T) {
+	var afterErr error
+	w := NewWorkflow()
+	w.SetAfterTask(func(_ context.Context, _ *Task, err error) { afterErr = err })
+	if err := w.AddTask(NewTask(1, "a", func(context.Context, *Task) error { panic("boom") })); err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("expected the panic to be propagated, got %v", r)
+			}
+		}()
+		_ = w.Reconcile(context.Background())
+	}()
+	if afterErr == nil || afterErr.Error() != "panic: boom" {
+		t.Fatalf("expected the AfterTask hook to receive the panic, got %v", afterErr)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	startErr := errors.New("audit log unavailable")
+	tests := []struct {
+		name     string
+		fn       Fn
+		onStart  error
+		canceled bool
+		panics   bool
+		executed bool
+	}{
+		{name: "success", fn: nop, executed: true},
+		{name: "fatal error", fn: func(context.Context, *Task) error { return NewFatalError(errors.New("boom")) },
+			executed: true},
+		{name: "canceled", fn: nop, canceled: true},
+		{name: "panic", fn: func(context.Context, *Task) error { panic("boom") }, panics: true, executed: true},
+		{name: "start error", fn: nop, onStart: startErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				executed bool
+				finished []error
+				report   Report
+			)
+			w := NewWorkflow()
+			w.OnStart(func(context.Context) error { return tt.onStart })
+			w.OnFinish(func(_ context.Context, r Report, err error) {
+				finished = append(finished, err)
+				report = r
+			})
+			if err := w.AddTask(NewTask(1, "a", func(ctx context.Context, task *Task) error {
+				executed = true
+				return tt.fn(ctx, task)
+			})); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+
+			var err error
+			func() {
+				defer func() {
+					if r := recover(); (r != nil) != tt.panics {
+						t.Fatalf("unexpected panic %v", r)
+					}
+				}()
+				err = w.Reconcile(ctx)
+			}()
+			if len(finished) != 1 {
+				t.Fatalf("expected the OnFinish hook to be called once, got %d calls", len(finished))
+			}
+			switch {
+			case tt.panics:
+				if finished[0] == nil || !strings.Contains(finished[0].Error(), "panic: boom") {
+					t.Fatalf("expected the OnFinish hook to receive the panic, got %v", finished[0])
+				}
+			case finished[0] != err:
+				t.Fatalf("expected the OnFinish hook to receive the error of Reconcile %v, got %v", err, finished[0])
+			}
+			if tt.onStart != nil && !errors.Is(err, tt.onStart) {
+				t.Fatalf("expected the error of the OnStart hook, got %v", err)
+			}
+			if executed != tt.executed {
+				t.Fatalf("expected the task to be executed: %t, got %t", tt.executed, executed)
+			}
+			if report.RunID != 1 || len(report.Tasks) != 1 {
+				t.Fatalf("expected the report of the first run, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	executed := 0
+	fingerprint, fingerprintErr := "v1", error(nil)
+	w := NewWorkflow()
+	if err := w.AddTask(NewTask(1, "render config", func(context.Context, *Task) error {
+		executed++
+		return nil
+	}, WithFingerprint(func(context.Context, *Task) (string, error) { return fingerprint, fingerprintErr }))); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		fingerprint string
+		err         error
+		executed    int
+		status      Status
+	}{
+		{fingerprint: "v1", executed: 1, status: Succeeded},
+		{fingerprint: "v1", executed: 1, status: Skipped},
+		{fingerprint: "v2", executed: 2, status: Succeeded},
+		{fingerprint: "v2", err: errors.New("template not readable"), executed: 3, status: Succeeded},
+		{fingerprint: "v2", executed: 3, status: Skipped},
+	} {
+		fingerprint, fingerprintErr = step.fingerprint, step.err
+		if err := w.Reconcile(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if executed != step.executed {
+			t.Fatalf("fingerprint %s (error %v): expected %d executions, got %d", step.fingerprint, step.err,
+				step.executed, executed)
+		}
+		report, err := w.Report()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task := report.Tasks[0]; task.Status != step.status || step.status == Skipped && task.Reason != "unchanged" {
+			t.Fatalf("fingerprint %s: expected the task to be %s, got %s (%s)", step.fingerprint, step.status,
+				task.Status, task.Reason)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package flowtest
+
+import (
+	"context"
+	"errors"
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"testing"
+)
+
+// AssertOrder fails the test, unless every valid execution order of the workflow executes the task with id before
+// ahead of the task with id after, i.e. unless after (transitively) depends on before.
+func AssertOrder(t testing.TB, w *flow.Workflow, before, after int64) {
+	t.Helper()
+	// complete every task that can run without the before task, the after task must not be among them
+	completed := make(map[int64]bool)
+	for {
+		runnable, err := w.Runnable(completed)
+		if err != nil {
+			t.Fatalf("error asserting order of task %d before task %d: %v", before, after, err)
+		}
+		progress := false
+		for _, task := range runnable {
+			if id := task.ID(); id != before {
+				completed[id] = true
+				progress = true
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+	if completed[after] {
+		t.Errorf("task %d can be executed before task %d", after, before)
+	}
+}
+
+// RunToCompletion reconciles the workflow until it succeeds and fails the test, if it fails with a FatalError or
+// does not succeed within maxAttempts reconciles. If the workflow uses a FakeClock, the clock is advanced between
+// the reconciles by the duration requested by a RequeueError, so that time-based tasks proceed without waiting.
+// It returns the number of reconciles.
+func RunToCompletion(t testing.TB, ctx context.Context, w *flow.Workflow, maxAttempts int) int {
+	t.Helper()
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = w.Reconcile(ctx)
+		if err == nil {
+			return attempt
+		}
+		if flow.IsFatal(err) {
+			t.Fatalf("workflow failed in reconcile %d: %v", attempt, err)
+			return attempt
+		}
+		var requeueErr flow.RequeueError
+		if clock, ok := w.Clock().(*FakeClock); ok && errors.As(err, &requeueErr) {
+			clock.Advance(requeueErr.After)
+		}
+	}
+	t.Fatalf("workflow did not complete within %d reconciles: %v", maxAttempts, err)
+	return maxAttempts
+}
+
+// AssertStatuses fails the test, unless the recorded statuses of the given tasks match the expected ones
+func AssertStatuses(t testing.TB, w *flow.Workflow, expected map[int64]flow.Status) {
+	t.Helper()
+	for id, want := range expected {
+		got, err := w.TaskStatus(id)
+		if err != nil {
+			t.Errorf("error asserting status of task %d: %v", id, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("task %d is %s, expected %s", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package flowtest provides helpers for testing workflows of the flow package.
+package flowtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+var _ flow.Clock = (*FakeClock)(nil)
+
+// FakeClock is a flow.Clock whose time only advances, when Advance or Set is called, so that time-based behavior
+// like backoffs and delays can be tested deterministically and without waiting. It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+// NewFakeClock creates a fake clock starting at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current time of the fake clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the current time, once the clock was advanced by the given duration.
+// If the duration is not positive, the channel receives immediately.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer returns a timer that fires like the channel returned by After, unless it is stopped. Stop and Reset
+// discard a time that was sent, but not received yet.
+func (c *FakeClock) NewTimer(d time.Duration) flow.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, ch: make(chan time.Time, 1)}
+	c.schedule(t, d)
+	return t
+}
+
+// schedule lets the given timer fire after the given duration
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	if d <= 0 {
+		t.ch <- c.now
+		return
+	}
+	t.until = c.now.Add(d)
+	c.waiters = append(c.waiters, t)
+	c.cond.Broadcast()
+}
+
+// unschedule removes the given timer from the pending ones and returns true, if it was pending
+func (c *FakeClock) unschedule(t *fakeTimer) bool {
+	for i, w := range c.waiters {
+		if w == t {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by the given duration and fires all channels returned by After that are due
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set moves the clock to the given time and fires all channels returned by After that are due
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(now)
+}
+
+func (c *FakeClock) set(now time.Time) {
+	c.now = now
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if now.Before(w.until) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- now
+	}
+	c.waiters = pending
+}
+
+// fakeTimer is the flow.Timer of a FakeClock
+type fakeTimer struct {
+	c     *FakeClock
+	until time.Time
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	t.drain()
+	return t.c.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	t.drain()
+	pending := t.c.unschedule(t)
+	t.c.schedule(t, d)
+	return pending
+}
+
+// drain discards a time that was sent, but not received yet
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.ch:
+	default:
+	}
+}
+
+// Waiters returns the number of channels returned by After and timers that did not fire yet
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least the given number of channels returned by After or timers are pending, e.g. to advance the
+// clock only after a workflow reconciled in another goroutine started waiting.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
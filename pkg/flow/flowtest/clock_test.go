@@ -0,0 +1,40 @@
+package flowtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	timer := c.NewTimer(time.Minute)
+	if n := c.Waiters(); n != 1 {
+		t.Fatalf("expected 1 waiter, got %d", n)
+	}
+	if !timer.Stop() || c.Waiters() != 0 {
+		t.Fatal("expected Stop to remove the pending timer")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("expected a stopped timer not to fire")
+	default:
+	}
+
+	if timer.Reset(time.Minute) {
+		t.Fatal("expected Reset of a stopped timer to return false")
+	}
+	c.Advance(30 * time.Second)
+	if !timer.Reset(30*time.Second) || c.Waiters() != 1 {
+		t.Fatal("expected Reset to reschedule the pending timer")
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(c.Now()) {
+			t.Fatalf("expected the timer to fire at %v, got %v", c.Now(), now)
+		}
+	default:
+		t.Fatal("expected the reset timer to fire")
+	}
+}
@@ -0,0 +1,72 @@
+package flowtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+// RandomDAG creates a workflow with the given number of tasks with ids 1 to nodes and the given number of random
+// dependencies, e.g. for property-based tests. The workflow is acyclic, because tasks only depend on tasks that
+// precede them in a random permutation. The number of dependencies is limited to nodes*(nodes-1)/2. The reconcile
+// functions of the tasks do nothing.
+func RandomDAG(r *rand.Rand, nodes, edges int) *flow.Workflow {
+	w := flow.NewWorkflow()
+	if nodes <= 0 {
+		return w
+	}
+	tasks := make([]*flow.Task, nodes)
+	for i, p := range r.Perm(nodes) {
+		tasks[i] = flow.NewTask(int64(p+1), fmt.Sprintf("task %d", p+1), nop)
+	}
+	mustSucceed(w.AddTasks(tasks))
+
+	if limit := nodes * (nodes - 1) / 2; edges > limit {
+		edges = limit
+	}
+	type pair struct{ from, to int }
+	seen := make(map[pair]bool, edges)
+	var pairs []pair
+	if edges*2 > nodes*(nodes-1)/2 {
+		// dense graphs: choose from all forward pairs
+		for i := 0; i < nodes; i++ {
+			for j := i + 1; j < nodes; j++ {
+				pairs = append(pairs, pair{from: i, to: j})
+			}
+		}
+		r.Shuffle(len(pairs), func(i, j int) { pairs[i], pairs[j] = pairs[j], pairs[i] })
+		pairs = pairs[:edges]
+	} else {
+		// sparse graphs: draw random forward pairs until there are enough distinct ones
+		for len(pairs) < edges {
+			i, j := r.Intn(nodes), r.Intn(nodes)
+			if i == j {
+				continue
+			}
+			if i > j {
+				i, j = j, i
+			}
+			if p := (pair{from: i, to: j}); !seen[p] {
+				seen[p] = true
+				pairs = append(pairs, p)
+			}
+		}
+	}
+	for _, p := range pairs {
+		mustSucceed(w.AddDependency(tasks[p.to], tasks[p.from]))
+	}
+	return w
+}
+
+func nop(context.Context, *flow.Task) error {
+	return nil
+}
+
+// mustSucceed panics on errors that indicate a bug in the generator
+func mustSucceed(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
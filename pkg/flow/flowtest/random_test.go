@@ -0,0 +1,31 @@
+package flowtest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestRandomDAGOrderIsValid(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 2000; run++ {
+		nodes := r.Intn(25)
+		edges := r.Intn(nodes*nodes/2 + 1)
+		w := RandomDAG(r, nodes, edges)
+		if n := w.NumTasks(); n != nodes {
+			t.Fatalf("run %d: expected %d tasks, got %d", run, nodes, n)
+		}
+		tasks, err := w.GetOrderedTasks()
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		ids := make([]int64, len(tasks))
+		for i, task := range tasks {
+			ids[i] = task.ID()
+		}
+		if err := flow.VerifyOrder(w, ids); err != nil {
+			t.Fatalf("run %d with %d tasks and %d dependencies: %v", run, nodes, edges, err)
+		}
+	}
+}
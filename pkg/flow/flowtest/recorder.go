@@ -0,0 +1,113 @@
+package flowtest
+
+import (
+	"context"
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"sync"
+	"time"
+)
+
+// Invocation is a recorded invocation of a task's reconcile function
+type Invocation struct {
+	// TaskID is the id of the invoked task
+	TaskID int64
+	// Attempt is the number of the invocation of the task, starting at 1
+	Attempt int
+	// Start and End are the times of the workflow's clock before and after the invocation
+	Start, End time.Time
+	// Err is the error returned by the invocation
+	Err error
+}
+
+// Recorder records the invocations of the reconcile functions of tasks, so that tests can assert the order,
+// the number of attempts and skipped tasks without instrumenting every reconcile function. It can inject failures
+// into specific attempts of specific tasks. Add its Middleware to the workflow with Workflow.Use.
+type Recorder struct {
+	mu          sync.Mutex
+	invocations []Invocation
+	attempts    map[int64]int
+	failures    map[failure]error
+}
+
+// failure identifies an attempt of a task that fails
+type failure struct {
+	taskID  int64
+	attempt int
+}
+
+// NewRecorder creates a new recorder without failures
+func NewRecorder() *Recorder {
+	return &Recorder{
+		attempts: make(map[int64]int),
+		failures: make(map[failure]error),
+	}
+}
+
+// FailOn lets the given attempt of the task with the given id fail with err instead of invoking its reconcile
+// function. Attempts count from 1 across reconciles.
+func (r *Recorder) FailOn(taskID int64, attempt int, err error) *Recorder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[failure{taskID: taskID, attempt: attempt}] = err
+	return r
+}
+
+// Middleware returns the middleware that records the invocations
+func (r *Recorder) Middleware() flow.Middleware {
+	return func(next flow.Fn) flow.Fn {
+		return func(ctx context.Context, task *flow.Task) error {
+			r.mu.Lock()
+			r.attempts[task.ID()]++
+			attempt := r.attempts[task.ID()]
+			injected, fail := r.failures[failure{taskID: task.ID(), attempt: attempt}]
+			r.mu.Unlock()
+
+			start := flow.Now(ctx)
+			var err error
+			if fail {
+				err = injected
+			} else {
+				err = next(ctx, task)
+			}
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.invocations = append(r.invocations, Invocation{
+				TaskID:  task.ID(),
+				Attempt: attempt,
+				Start:   start,
+				End:     flow.Now(ctx),
+				Err:     err,
+			})
+			return err
+		}
+	}
+}
+
+// Invocations returns the recorded invocations in the order they finished
+func (r *Recorder) Invocations() []Invocation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invocations := make([]Invocation, len(r.invocations))
+	copy(invocations, r.invocations)
+	return invocations
+}
+
+// Sequence returns the ids of the invoked tasks in the order the invocations finished
+func (r *Recorder) Sequence() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int64, 0, len(r.invocations))
+	for _, inv := range r.invocations {
+		ids = append(ids, inv.TaskID)
+	}
+	return ids
+}
+
+// Attempts returns the number of invocations of the task with the given id, which is 0 if it was never invoked,
+// e.g. because it was skipped
+func (r *Recorder) Attempts(taskID int64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts[taskID]
+}
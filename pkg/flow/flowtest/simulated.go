@@ -0,0 +1,86 @@
+package flowtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"sync"
+	"time"
+)
+
+// Simulation is a task with simulated behavior for examples and tests, that counts the invocations of its
+// reconcile function. Once it succeeded, it keeps succeeding like an idempotent reconcile function.
+type Simulation struct {
+	*flow.Task
+
+	mu          sync.Mutex
+	invocations int
+	succeeded   bool
+	outcome     func(ctx context.Context, invocation int) error
+}
+
+func newSimulation(id int64, desc string, outcome func(ctx context.Context, invocation int) error, opts ...flow.TaskOption) *Simulation {
+	s := &Simulation{outcome: outcome}
+	s.Task = flow.NewTask(id, desc, s.reconcile, opts...)
+	return s
+}
+
+// SimulatedTask creates a task that fails with a retryable error until its invocation number succeedAfter,
+// e.g. 1 succeeds immediately and 3 succeeds on the third invocation.
+func SimulatedTask(id int64, desc string, succeedAfter int, opts ...flow.TaskOption) *Simulation {
+	return newSimulation(id, desc, func(_ context.Context, invocation int) error {
+		if invocation < succeedAfter {
+			return fmt.Errorf("simulated failure %d of %d", invocation, succeedAfter-1)
+		}
+		return nil
+	}, opts...)
+}
+
+// FatalTask creates a task that fails with a retryable error until its invocation number fatalOn,
+// on which it fails with a FatalError.
+func FatalTask(id int64, desc string, fatalOn int, opts ...flow.TaskOption) *Simulation {
+	return newSimulation(id, desc, func(_ context.Context, invocation int) error {
+		if invocation < fatalOn {
+			return fmt.Errorf("simulated failure %d of %d", invocation, fatalOn-1)
+		}
+		return flow.NewFatalError(errors.New("simulated fatal failure"))
+	}, opts...)
+}
+
+// TimedTask creates a task that succeeds once the given duration elapsed on the workflow's clock since its first
+// invocation, e.g. a FakeClock. Until then, it requests to be requeued after the remaining duration.
+func TimedTask(id int64, desc string, d time.Duration, opts ...flow.TaskOption) *Simulation {
+	var start time.Time
+	return newSimulation(id, desc, func(ctx context.Context, invocation int) error {
+		now := flow.Now(ctx)
+		if invocation == 1 {
+			start = now
+		}
+		if remaining := start.Add(d).Sub(now); remaining > 0 {
+			return flow.RequeueAfter(remaining, errors.New("simulated duration not elapsed"))
+		}
+		return nil
+	}, opts...)
+}
+
+// Invocations returns the number of invocations of the task's reconcile function
+func (s *Simulation) Invocations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.invocations
+}
+
+func (s *Simulation) reconcile(ctx context.Context, _ *flow.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invocations++
+	if s.succeeded {
+		return nil
+	}
+	if err := s.outcome(ctx, s.invocations); err != nil {
+		return err
+	}
+	s.succeeded = true
+	return nil
+}
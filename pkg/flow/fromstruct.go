@@ -0,0 +1,150 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Step is implemented by the fields of a struct that define tasks instead of a Fn, see FromStruct
+type Step interface {
+	Reconcile(ctx context.Context, task *Task) error
+}
+
+var (
+	fnType   = reflect.TypeOf(Fn(nil))
+	stepType = reflect.TypeOf((*Step)(nil)).Elem()
+)
+
+// fieldTask is a task defined by a field of a struct, see FromStruct
+type fieldTask struct {
+	field string
+	id    int64
+	desc  string
+	after []int64
+	fn    Fn
+}
+
+// FromStruct creates a workflow configured by the given options with a task for each field of the given struct or
+// pointer to struct, that has a tag like `flow:"id=3,desc=create V3,after=1;2"`. The field must be a Fn or
+// implement Step. The tag sets the id and description of the task and the ids of the tasks it depends on, separated
+// by semicolons, so the description cannot contain commas. Fields without tag are ignored.
+// All fields are validated before the workflow is created and the errors name the fields, e.g. for duplicate ids,
+// unknown dependencies or missing functions.
+func FromStruct(v any, opts ...Option) (*Workflow, error) {
+	value := reflect.ValueOf(v)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("error creating workflow from %T: %w: not a struct", v, ErrInvalidTask)
+	}
+
+	var (
+		tasks []fieldTask
+		errs  []error
+	)
+	fields := make(map[int64]string)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup("flow")
+		if !ok {
+			continue
+		}
+		ft, err := parseFieldTask(field, tag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field %s: %w", field.Name, err))
+			continue
+		}
+		if other, ok := fields[ft.id]; ok {
+			errs = append(errs, fmt.Errorf("field %s: id %d is also used by field %s: %w", field.Name, ft.id, other,
+				ErrAlreadyExists))
+			continue
+		}
+		fields[ft.id] = field.Name
+		// the task can be referenced by others, even if its function is missing
+		if ft.fn, err = fieldFn(value.Field(i)); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: %w", field.Name, err))
+		}
+		tasks = append(tasks, ft)
+	}
+	for _, ft := range tasks {
+		for _, id := range ft.after {
+			if _, ok := fields[id]; !ok {
+				errs = append(errs, fmt.Errorf("field %s: dependency on id %d: %w", ft.field, id, ErrTaskNotFound))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("error creating workflow from struct: %w", errors.Join(errs...))
+	}
+
+	w := NewWorkflow(opts...)
+	for _, ft := range tasks {
+		if err := w.AddTask(NewTask(ft.id, ft.desc, ft.fn)); err != nil {
+			return nil, fmt.Errorf("field %s: %w", ft.field, err)
+		}
+	}
+	for _, ft := range tasks {
+		if err := w.AddDependencyByID(ft.id, ft.after...); err != nil {
+			return nil, fmt.Errorf("field %s: %w", ft.field, err)
+		}
+	}
+	return w, nil
+}
+
+// parseFieldTask returns the task defined by the tag of the given field without its function
+func parseFieldTask(field reflect.StructField, tag string) (fieldTask, error) {
+	ft := fieldTask{field: field.Name}
+	if !field.IsExported() {
+		return ft, fmt.Errorf("%w: field is not exported", ErrInvalidTask)
+	}
+	hasID := false
+	for _, part := range strings.Split(tag, ",") {
+		key, val, _ := strings.Cut(part, "=")
+		switch strings.TrimSpace(key) {
+		case "id":
+			parsed, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+			if err != nil {
+				return ft, fmt.Errorf("%w: invalid id %q", ErrInvalidTask, val)
+			}
+			ft.id, hasID = parsed, true
+		case "desc":
+			ft.desc = val
+		case "after":
+			for _, dep := range strings.Split(val, ";") {
+				parsed, err := strconv.ParseInt(strings.TrimSpace(dep), 10, 64)
+				if err != nil {
+					return ft, fmt.Errorf("%w: invalid dependency %q", ErrInvalidTask, dep)
+				}
+				ft.after = append(ft.after, parsed)
+			}
+		default:
+			return ft, fmt.Errorf("%w: unknown key %q in tag", ErrInvalidTask, key)
+		}
+	}
+	if !hasID {
+		return ft, fmt.Errorf("%w: missing id in tag", ErrInvalidTask)
+	}
+	return ft, nil
+}
+
+// fieldFn returns the reconcile function of the given field, which is a Fn or implements Step
+func fieldFn(value reflect.Value) (Fn, error) {
+	switch {
+	case value.Type().ConvertibleTo(fnType):
+		if value.IsNil() {
+			return nil, fmt.Errorf("%w: missing function", ErrInvalidTask)
+		}
+		return value.Convert(fnType).Interface().(Fn), nil
+	case value.Type().Implements(stepType):
+		if (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+			return nil, fmt.Errorf("%w: missing step", ErrInvalidTask)
+		}
+		return value.Interface().(Step).Reconcile, nil
+	}
+	return nil, fmt.Errorf("%w: type %s is neither a Fn nor implements Step", ErrInvalidTask, value.Type())
+}
@@ -0,0 +1,125 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+// countingStep counts its invocations
+type countingStep struct {
+	calls int
+}
+
+func (s *countingStep) Reconcile(context.Context, *flow.Task) error {
+	s.calls++
+	return nil
+}
+
+func TestFromStruct(t *testing.T) {
+	var fnCalls int
+	def := struct {
+		Create  flow.Fn       `flow:"id=1,desc=create V1"`
+		Render  *countingStep `flow:"id=2,desc=render config,after=1"`
+		Deploy  flow.Fn       `flow:"id=3, desc=deploy, after=1; 2"`
+		Ignored flow.Fn
+	}{
+		Create: func(context.Context, *flow.Task) error { fnCalls++; return nil },
+		Render: &countingStep{},
+		Deploy: func(context.Context, *flow.Task) error { fnCalls++; return nil },
+	}
+	w, err := flow.FromStruct(&def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := w.GetOrderedTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var descs []string
+	for _, task := range tasks {
+		descs = append(descs, task.String())
+	}
+	want := []string{"task 1 (create V1)", "task 2 (render config)", "task 3 (deploy)"}
+	if !reflect.DeepEqual(descs, want) {
+		t.Fatalf("expected tasks %v, got %v", want, descs)
+	}
+	if w.NumDependencies() != 3 {
+		t.Fatalf("expected 3 dependencies, got %d", w.NumDependencies())
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fnCalls != 2 || def.Render.calls != 1 {
+		t.Fatalf("expected the functions and the step to be called once, got %d and %d", fnCalls, def.Render.calls)
+	}
+}
+
+func TestFromStructErrors(t *testing.T) {
+	fn := flow.Fn(func(context.Context, *flow.Task) error { return nil })
+	tests := []struct {
+		name  string
+		v     any
+		field string
+		want  error
+	}{
+		{name: "not a struct", v: 42, want: flow.ErrInvalidTask},
+		{name: "invalid id", v: struct {
+			A flow.Fn `flow:"id=a"`
+		}{A: fn}, field: "A", want: flow.ErrInvalidTask},
+		{name: "missing id", v: struct {
+			A flow.Fn `flow:"desc=a"`
+		}{A: fn}, field: "A", want: flow.ErrInvalidTask},
+		{name: "unknown key", v: struct {
+			A flow.Fn `flow:"id=1,name=a"`
+		}{A: fn}, field: "A", want: flow.ErrInvalidTask},
+		{name: "invalid dependency", v: struct {
+			A flow.Fn `flow:"id=1,after=x"`
+		}{A: fn}, field: "A", want: flow.ErrInvalidTask},
+		{name: "unknown dependency", v: struct {
+			A flow.Fn `flow:"id=1"`
+			B flow.Fn `flow:"id=2,after=1;3"`
+		}{A: fn, B: fn}, field: "B", want: flow.ErrTaskNotFound},
+		{name: "duplicate id", v: struct {
+			A flow.Fn `flow:"id=1"`
+			B flow.Fn `flow:"id=1"`
+		}{A: fn, B: fn}, field: "B", want: flow.ErrAlreadyExists},
+		{name: "wrong type", v: struct {
+			A string `flow:"id=1"`
+		}{A: "a"}, field: "A", want: flow.ErrInvalidTask},
+		{name: "missing function", v: struct {
+			A flow.Fn `flow:"id=1"`
+		}{}, field: "A", want: flow.ErrInvalidTask},
+		{name: "missing step", v: struct {
+			A *countingStep `flow:"id=1"`
+		}{}, field: "A", want: flow.ErrInvalidTask},
+		{name: "unexported field", v: struct {
+			a flow.Fn `flow:"id=1"`
+		}{a: fn}, field: "a", want: flow.ErrInvalidTask},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := flow.FromStruct(tt.v)
+			if w != nil || !errors.Is(err, tt.want) {
+				t.Fatalf("expected error matching %v, got %v", tt.want, err)
+			}
+			if tt.field != "" && !strings.Contains(err.Error(), "field "+tt.field+":") {
+				t.Fatalf("expected the error to name field %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestFromStructJoinsFieldErrors(t *testing.T) {
+	_, err := flow.FromStruct(struct {
+		A flow.Fn `flow:"id=a"`
+		B string  `flow:"id=2,after=3"`
+	}{B: "b"})
+	if !errors.Is(err, flow.ErrInvalidTask) || !errors.Is(err, flow.ErrTaskNotFound) {
+		t.Fatalf("expected the errors of all fields, got %v", err)
+	}
+}
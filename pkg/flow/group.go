@@ -0,0 +1,294 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// GoroutineGroup runs functions in goroutines and waits for them, e.g. an errgroup.Group of
+// golang.org/x/sync/errgroup.
+type GoroutineGroup interface {
+	// Go calls the given function in a new goroutine
+	Go(f func() error)
+	// Wait blocks until all functions returned and returns the first error
+	Wait() error
+}
+
+// RunWithGroup reconciles the workflow like Reconcile, but executes each task in a goroutine of the given group as
+// soon as its dependencies completed, so that independent tasks run in parallel. The group controls how many tasks
+// run at once, e.g. with errgroup.Group.SetLimit, and how errors propagate: ctx should be the context of the group,
+// e.g. of errgroup.WithContext, so that the first error cancels the tasks that are still running.
+// By default, no further tasks are started after a task failed and the first error is returned. In continue-on-error
+// mode, all tasks whose dependencies did not fail are executed and all errors are joined. Tasks that always run are
+// executed after their dependencies completed in any case. The given options apply like those of Reconcile.
+// Tasks cannot add tasks or dependencies while running in a group.
+func RunWithGroup(ctx context.Context, g GoroutineGroup, w *Workflow, opts ...RunOption) error {
+	var config runConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	for k, v := range config.values {
+		ctx = context.WithValue(ctx, k, v)
+	}
+	return w.run(ctx, func(ctx context.Context) error {
+		return w.reconcileWithGroup(ctx, g, config)
+	})
+}
+
+// groupRun is the bookkeeping of the dependencies and errors of a reconcile in a group
+type groupRun struct {
+	mu sync.Mutex
+	// number of dependencies of each task that did not complete yet
+	pending map[int64]int
+	// dependents of each task in executable order
+	dependents map[int64][]*Task
+	// tasks whose dependencies completed, in the order in which they are started
+	ready chan *Task
+	// set once no further tasks start except those that always run
+	halted bool
+	// set, if the reconcile was halted because it exhausted its budget
+	exhausted bool
+	// tasks that were not started, because the reconcile was halted
+	unstarted map[int64]bool
+	errs      []error
+}
+
+// newGroupRun returns the bookkeeping of a reconcile of the given tasks in executable order, whose tasks without
+// dependencies are ready, while the caller holds the lock
+func (w *Workflow) newGroupRun(tasks []*Task) *groupRun {
+	r := &groupRun{
+		pending:    make(map[int64]int, len(tasks)),
+		dependents: make(map[int64][]*Task, len(tasks)),
+		ready:      make(chan *Task, len(tasks)),
+		unstarted:  make(map[int64]bool),
+	}
+	for _, task := range tasks {
+		deps := w.graph.To(task.id)
+		r.pending[task.id] = deps.Len()
+		if deps.Len() == 0 {
+			r.ready <- task
+		}
+		for deps.Next() {
+			id := deps.Node().ID()
+			r.dependents[id] = append(r.dependents[id], task)
+		}
+	}
+	return r
+}
+
+// complete records that the given task completed and marks its dependents ready, once all their dependencies
+// completed
+func (r *groupRun) complete(task *Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, dependent := range r.dependents[task.id] {
+		r.pending[dependent.id]--
+		if r.pending[dependent.id] == 0 {
+			r.ready <- dependent
+		}
+	}
+}
+
+// halt stops starting further tasks except those that always run. The given error is recorded, unless it is nil
+// or the reconcile was halted already, e.g. so that a cancellation is only reported once.
+func (r *groupRun) halt(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil && !r.halted {
+		r.errs = append(r.errs, err)
+	}
+	r.halted = true
+}
+
+// fail records the error of a task and halts the reconcile, if halt is set
+func (r *groupRun) fail(err error, halt bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+	r.halted = r.halted || halt
+}
+
+func (r *groupRun) isHalted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.halted
+}
+
+func (w *Workflow) reconcileWithGroup(ctx context.Context, g GoroutineGroup, config runConfig) error {
+	if err := w.Validate(); err != nil {
+		return NewFatalError(err)
+	}
+	if w.IsEmpty() {
+		return nil
+	}
+	w.mu.RLock()
+	tasks, err := w.runOrder(config)
+	var r *groupRun
+	if err == nil {
+		r = w.newGroupRun(tasks)
+	}
+	w.mu.RUnlock()
+	if err != nil {
+		return NewFatalError(err)
+	}
+	w.update(func() {
+		w.aborted = nil
+		w.shuffleSeed = nil
+		if config.shuffle {
+			w.shuffleSeed = &config.seed
+		}
+	})
+
+	p := newPass()
+	p.config = config
+	p.started = w.clock.Now()
+	// the tasks are started here instead of by the task that completes their dependencies, because Go blocks
+	// while the group runs as many functions as its limit allows
+	for range tasks {
+		task := <-r.ready
+		g.Go(func() error {
+			defer r.complete(task)
+			return w.runInGroup(ctx, task, p, r)
+		})
+	}
+	// the error of the group is the first error that halted the reconcile, which is recorded already
+	_ = g.Wait()
+
+	var unstarted []*Task
+	for _, task := range tasks {
+		if r.unstarted[task.id] {
+			unstarted = append(unstarted, task)
+		}
+	}
+	errs := r.errs
+	if r.exhausted {
+		errs = append(errs, w.runBudgetError(unstarted, p))
+	}
+	return joinErrors(append(errs, w.stageErrors(p, unstarted)...))
+}
+
+// runInGroup executes the given task after its dependencies completed, unless the pass prevents it or the reconcile
+// was halted. It returns the error that halts the reconcile, so that the group can cancel its context.
+func (w *Workflow) runInGroup(ctx context.Context, task *Task, p *pass, r *groupRun) error {
+	if r.isHalted() {
+		w.runHalted(ctx, task, p, r)
+		return nil
+	}
+	p.mu.Lock()
+	p.processed[task.id] = true
+	reason, unselected := w.unselected(task, p)
+	if unselected {
+		p.unselected[task.id] = true
+	}
+	blocked := !unselected && w.continueOnError && !task.alwaysRun && w.dependencyFailed(task, p)
+	if blocked {
+		p.failed[task.id] = true
+	}
+	p.mu.Unlock()
+	if unselected {
+		w.update(func() { w.states[task.id].skipped(reason) })
+		w.audit(AuditTaskSkipped, task, reason, nil)
+		return nil
+	}
+	if blocked {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		r.halt(canceledError(err))
+		w.runHalted(ctx, task, p, r)
+		return nil
+	}
+	if p.config.stopped() {
+		r.halt(ErrShutdown)
+		w.runHalted(ctx, task, p, r)
+		return nil
+	}
+	if w.overdue(task, p) {
+		w.missDeadline(task, p)
+		if !w.continueOnError {
+			r.halt(nil)
+		}
+		return nil
+	}
+	p.mu.Lock()
+	exhausted := w.budgetExhausted(task, p)
+	if !exhausted && w.counts(task) {
+		p.counted++
+	}
+	p.mu.Unlock()
+	if exhausted {
+		r.halt(nil)
+		r.mu.Lock()
+		r.exhausted = true
+		r.mu.Unlock()
+		w.runHalted(ctx, task, p, r)
+		return nil
+	}
+
+	err := w.runTask(ctx, task, p)
+	late := w.overdue(task, p)
+	p.mu.Lock()
+	p.completed++
+	expanded := p.expanded
+	if err != nil || late && !w.continueOnError {
+		// like a failure, a late task prevents further tasks from starting
+		p.failed[task.id] = true
+	}
+	p.mu.Unlock()
+	if expanded {
+		err := NewFatalError(errors.New("tasks cannot add tasks or dependencies while running in a group"))
+		r.fail(err, true)
+		return err
+	}
+	if err != nil {
+		if IsAborted(err) {
+			w.update(func() { w.aborted = abortedFrom(err) })
+		}
+		halt := !w.continueOnError || IsAborted(err)
+		r.fail(err, halt)
+		if halt {
+			return err
+		}
+		return nil
+	}
+	if late && !w.continueOnError {
+		r.halt(nil)
+	}
+	return nil
+}
+
+// runHalted handles a task whose dependencies completed after the reconcile was halted like abort does: the task is
+// only executed, if it always runs, and recorded as Skipped, if a task aborted the workflow
+func (w *Workflow) runHalted(ctx context.Context, task *Task, p *pass, r *groupRun) {
+	if !task.alwaysRun {
+		r.mu.Lock()
+		r.unstarted[task.id] = true
+		r.mu.Unlock()
+		w.mu.RLock()
+		aborted := w.aborted != nil
+		w.mu.RUnlock()
+		if aborted {
+			w.update(func() { w.states[task.id].skipped("aborted") })
+			w.audit(AuditTaskSkipped, task, "aborted", nil)
+		}
+		return
+	}
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), w.finalizerGracePeriod)
+		defer cancel()
+	}
+	if err := w.runTask(ctx, task, p); err != nil {
+		r.fail(err, false)
+	}
+}
+
+// abortedFrom returns the AbortedError in the chain of the given error
+func abortedFrom(err error) *AbortedError {
+	var abortedErr AbortedError
+	if errors.As(err, &abortedErr) {
+		return &abortedErr
+	}
+	return nil
+}
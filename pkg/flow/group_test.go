@@ -0,0 +1,175 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// limitGroup runs at most limit functions at once and blocks in Go until one returns, like an errgroup.Group
+// with SetLimit
+type limitGroup struct {
+	wg    sync.WaitGroup
+	slots chan struct{}
+	mu    sync.Mutex
+	err   error
+}
+
+func newLimitGroup(limit int) *limitGroup {
+	return &limitGroup{slots: make(chan struct{}, limit)}
+}
+
+func (g *limitGroup) Go(f func() error) {
+	g.slots <- struct{}{}
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			<-g.slots
+			g.wg.Done()
+		}()
+		if err := f(); err != nil {
+			g.mu.Lock()
+			if g.err == nil {
+				g.err = err
+			}
+			g.mu.Unlock()
+		}
+	}()
+}
+
+func (g *limitGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+// diamond creates a workflow, in which 2 and 3 depend on 1 and 4 depends on 2 and 3
+func diamond(t *testing.T, fns map[int64]flow.Fn, opts ...flow.TaskOption) *flow.Workflow {
+	t.Helper()
+	w := flow.NewWorkflow()
+	for id := int64(1); id <= 4; id++ {
+		fn := fns[id]
+		if fn == nil {
+			fn = func(context.Context, *flow.Task) error { return nil }
+		}
+		if err := w.AddTask(flow.NewTask(id, "task", fn, opts...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, deps := range map[int64][]int64{2: {1}, 3: {1}, 4: {2, 3}} {
+		if err := w.AddDependencyByID(id, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+// runWithGroup runs the workflow in the group and fails the test, if it does not return in time
+func runWithGroup(t *testing.T, g flow.GoroutineGroup, w *flow.Workflow, opts ...flow.RunOption) error {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() { errc <- flow.RunWithGroup(context.Background(), g, w, opts...) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected RunWithGroup to return")
+		return nil
+	}
+}
+
+func TestRunWithGroupLimitOne(t *testing.T) {
+	w := diamond(t, nil)
+	rec := flowtest.NewRecorder()
+	w.Use(rec.Middleware())
+	if err := runWithGroup(t, newLimitGroup(1), w); err != nil {
+		t.Fatal(err)
+	}
+	seq := rec.Sequence()
+	if len(seq) != 4 || seq[0] != 1 || seq[3] != 4 {
+		t.Fatalf("expected 1 first and 4 last, got %v", seq)
+	}
+}
+
+func TestRunWithGroupDiamond(t *testing.T) {
+	// 2 and 3 only succeed, if they run at the same time
+	var started sync.WaitGroup
+	started.Add(2)
+	both := func(context.Context, *flow.Task) error {
+		started.Done()
+		started.Wait()
+		return nil
+	}
+	w := diamond(t, map[int64]flow.Fn{2: both, 3: both})
+	rec := flowtest.NewRecorder()
+	w.Use(rec.Middleware())
+	if err := runWithGroup(t, newLimitGroup(2), w); err != nil {
+		t.Fatal(err)
+	}
+	seq := rec.Sequence()
+	if len(seq) != 4 || seq[0] != 1 || seq[3] != 4 {
+		t.Fatalf("expected 1 first and 4 last, got %v", seq)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Succeeded, 2: flow.Succeeded, 3: flow.Succeeded,
+		4: flow.Succeeded})
+}
+
+func TestRunWithGroupAbort(t *testing.T) {
+	w := diamond(t, map[int64]flow.Fn{
+		2: func(context.Context, *flow.Task) error { return flow.Abort("maintenance") },
+	})
+	cleanup := flow.NewTask(5, "cleanup", func(context.Context, *flow.Task) error { return nil }, flow.AlwaysRun())
+	if err := w.AddTask(cleanup); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependencyByID(5, 4); err != nil {
+		t.Fatal(err)
+	}
+	err := runWithGroup(t, newLimitGroup(1), w)
+	if !flow.IsAborted(err) {
+		t.Fatalf("expected the workflow to be aborted, got %v", err)
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range report.Tasks {
+		switch task.ID {
+		case 4:
+			if task.Status != flow.Skipped || task.Reason != "aborted" {
+				t.Errorf("expected task 4 to be skipped because of the abort, got %s (%s)", task.Status, task.Reason)
+			}
+		case 5:
+			if task.Status != flow.Succeeded {
+				t.Errorf("expected task 5 to always run, got %s", task.Status)
+			}
+		}
+	}
+}
+
+func TestRunWithGroupOptions(t *testing.T) {
+	type key struct{}
+	var values []any
+	var mu sync.Mutex
+	w := diamond(t, nil)
+	w.Use(func(next flow.Fn) flow.Fn {
+		return func(ctx context.Context, task *flow.Task) error {
+			mu.Lock()
+			values = append(values, ctx.Value(key{}))
+			mu.Unlock()
+			return next(ctx, task)
+		}
+	})
+	err := runWithGroup(t, newLimitGroup(1), w, flow.MaxTasksPerRun(1), flow.WithValues(map[any]any{key{}: "v"}))
+	var budgetErr flow.RunBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Completed != 1 || len(budgetErr.Remaining) != 3 {
+		t.Fatalf("expected the budget to stop the run after one task, got %v", err)
+	}
+	if len(values) != 1 || values[0] != "v" {
+		t.Fatalf("expected the value of the run in the context of one task, got %v", values)
+	}
+}
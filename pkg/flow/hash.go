@@ -0,0 +1,101 @@
+package flow
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// The hashing helpers produce short hex digests suitable as fingerprints, see WithFingerprint.
+// The digests are truncated SHA-256 hashes of a documented encoding of the inputs, so they are stable
+// across Go versions and platforms, as long as the inputs are the same.
+
+// digestLength is the number of bytes of the SHA-256 hash in a digest
+const digestLength = 16
+
+// HashStrings returns a digest of the given strings. The strings are length-prefixed before hashing, so
+// e.g. ("ab", "c") and ("a", "bc") have different digests.
+func HashStrings(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		writeLengthPrefixed(h, []byte(v))
+	}
+	return digest(h)
+}
+
+// HashJSON returns a digest of the JSON encoding of the given value. The encoding is canonicalized, i.e. object
+// keys are sorted and insignificant whitespace is removed, so that maps with the same content have the same digest.
+func HashJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error hashing json: %w", err)
+	}
+	// re-encoding the decoded value sorts the keys of all objects, including those of json.Marshaler results
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var canonical any
+	if err := decoder.Decode(&canonical); err != nil {
+		return "", fmt.Errorf("error hashing json: %w", err)
+	}
+	data, err = json.Marshal(canonical)
+	if err != nil {
+		return "", fmt.Errorf("error hashing json: %w", err)
+	}
+	h := sha256.New()
+	h.Write(data)
+	return digest(h), nil
+}
+
+// HashFiles returns a digest of the paths and contents of the given files in the given order.
+// Modification times and permissions are not part of the digest, only the content.
+func HashFiles(paths ...string) (string, error) {
+	h := sha256.New()
+	for _, path := range paths {
+		writeLengthPrefixed(h, []byte(path))
+		if err := hashFile(h, path); err != nil {
+			return "", fmt.Errorf("error hashing file %s: %w", path, err)
+		}
+	}
+	return digest(h), nil
+}
+
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(info.Size()))
+	h.Write(size[:])
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if n != info.Size() {
+		return errors.New("file changed while hashing")
+	}
+	return nil
+}
+
+func writeLengthPrefixed(h hash.Hash, data []byte) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(data)))
+	h.Write(size[:])
+	h.Write(data)
+}
+
+func digest(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil)[:digestLength])
+}
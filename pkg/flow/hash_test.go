@@ -0,0 +1,95 @@
+package flow
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashJSONMapOrdering(t *testing.T) {
+	a := map[string]any{"zone": "a", "disks": []string{"sda", "sdb"}, "nic": map[string]int{"mtu": 9000, "vlan": 7}}
+	b := make(map[string]any)
+	b["nic"] = map[string]int{"vlan": 7, "mtu": 9000}
+	b["disks"] = []string{"sda", "sdb"}
+	b["zone"] = "a"
+	raw := json.RawMessage(`{"zone":"a","nic":{"vlan":7,"mtu":9000},  "disks":["sda","sdb"]}`)
+
+	want, err := HashJSON(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		for _, v := range []any{a, b, raw} {
+			got, err := HashJSON(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("expected digest %s for %v, got %s", want, v, got)
+			}
+		}
+	}
+	// the order of arrays is significant
+	other, err := HashJSON(map[string]any{"zone": "a", "disks": []string{"sdb", "sda"}, "nic": map[string]int{"mtu": 9000, "vlan": 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == want {
+		t.Fatal("expected a different digest for a different array order")
+	}
+}
+
+func TestHashStrings(t *testing.T) {
+	if HashStrings("ab", "c") == HashStrings("a", "bc") {
+		t.Fatal("expected different digests for differently split strings")
+	}
+	// the encoding is documented, so the digests must not change
+	if got, want := HashStrings("a", "b"), "3c9d591045bc8876f9d0399bbfb05c6a"; got != want {
+		t.Fatalf("expected digest %s, got %s", want, got)
+	}
+	if len(HashStrings()) != 2*digestLength {
+		t.Fatalf("expected digests of %d hex digits", 2*digestLength)
+	}
+}
+
+func TestHashFilesDetectsModification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user-data.yaml")
+	if err := os.WriteFile(path, []byte("hostname: a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	before, err := HashFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the modification time is not part of the digest
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := HashFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if touched != before {
+		t.Fatalf("expected the same digest after touching the file, got %s and %s", before, touched)
+	}
+
+	// a change of the content of the same size is detected
+	if err := os.WriteFile(path, []byte("hostname: b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	after, err := HashFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after == before {
+		t.Fatal("expected a different digest after modifying the file")
+	}
+
+	if _, err := HashFiles(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,181 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gonum.org/v1/gonum/graph/topo"
+)
+
+// ForEach creates one task per item with the reconcile function returned by fn, adds them to the workflow and
+// adds them as dependencies of the given join task, which must already be part of the workflow.
+// The task ids are allocated from baseID upwards, skipping ids that are already in use, and the descriptions are
+// formatted from descFormat with the item as argument. It returns the created tasks in the order of the items.
+// All tasks are validated before any is added, so that the workflow is left unmodified, if one cannot be added.
+// There is no builder for workflows, so ForEach operates on the workflow directly.
+func ForEach[T any](w *Workflow, baseID int64, descFormat string, items []T, join *Task, fn func(item T) Fn) ([]*Task, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.tasks[join.id]; !ok {
+		return nil, fmt.Errorf("error adding tasks for join task id %d: %w", join.id, ErrTaskNotFound)
+	}
+
+	tasks := make([]*Task, 0, len(items))
+	id := baseID
+	for _, item := range items {
+		// placeholders reserve their ids as well
+		for w.graph.Node(id) != nil {
+			id++
+		}
+		task := NewTask(id, fmt.Sprintf(descFormat, item), fn(item))
+		if err := w.validateTask(task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+		id++
+	}
+	for i, task := range tasks {
+		if err := w.addTaskLocked(task); err != nil {
+			w.removeTasksLocked(tasks[:i])
+			return nil, err
+		}
+	}
+	if err := w.addDependencyLocked(join.id, false, "", taskIDs(tasks)...); err != nil {
+		w.removeTasksLocked(tasks)
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Chain adds the given tasks to the workflow, if they are not part of it yet, and makes each task depend on the
+// previous one, so that they run strictly one after another. It returns the first and the last task, so that the
+// chain can be linked into the rest of the workflow. Dependencies between consecutive tasks that already exist are
+// kept. If any task or dependency cannot be added, the workflow is left unmodified.
+func Chain(w *Workflow, tasks ...*Task) (first, last *Task, err error) {
+	if len(tasks) == 0 {
+		return nil, nil, errors.New("error chaining tasks: no tasks given")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.validateChain(tasks); err != nil {
+		return nil, nil, err
+	}
+	var added []*Task
+	for _, task := range tasks {
+		if w.tasks[task.id] == task {
+			continue
+		}
+		if err := w.addTaskLocked(task); err != nil {
+			w.removeTasksLocked(added)
+			return nil, nil, err
+		}
+		added = append(added, task)
+	}
+	for i := 1; i < len(tasks); i++ {
+		if w.graph.HasEdgeFromTo(tasks[i-1].id, tasks[i].id) {
+			continue
+		}
+		if err := w.addDependencyLocked(tasks[i].id, false, "", tasks[i-1].id); err != nil {
+			w.removeTasksLocked(added)
+			return nil, nil, err
+		}
+	}
+	return tasks[0], tasks[len(tasks)-1], nil
+}
+
+// removeTasksLocked removes the given tasks, which were added by a helper that failed, while the caller holds the lock
+func (w *Workflow) removeTasksLocked(tasks []*Task) {
+	for _, task := range tasks {
+		w.removeTaskLocked(task.id)
+	}
+}
+
+// validateChain returns an error, if the given tasks cannot be chained, while the caller holds the lock
+func (w *Workflow) validateChain(tasks []*Task) error {
+	seen := make(map[int64]bool, len(tasks))
+	for _, task := range tasks {
+		if err := w.validateTask(task); err != nil {
+			return err
+		}
+		if seen[task.id] {
+			return fmt.Errorf("error chaining task id %d twice: %w", task.id, ErrWouldCycle)
+		}
+		seen[task.id] = true
+		if existing, ok := w.tasks[task.id]; ok && existing != task {
+			return fmt.Errorf("error chaining task id %d: %w", task.id, ErrAlreadyExists)
+		}
+	}
+	// a later task of the chain must not be a dependency of an earlier one already
+	for i, task := range tasks {
+		for _, later := range tasks[i+1:] {
+			if w.tasks[task.id] == nil || w.tasks[later.id] == nil {
+				continue
+			}
+			if topo.PathExistsIn(w.graph, w.graph.Node(later.id), w.graph.Node(task.id)) {
+				return fmt.Errorf("error chaining task id %d after id %d: %w", later.id, task.id, ErrWouldCycle)
+			}
+		}
+	}
+	return nil
+}
+
+// Group adds the given members to the workflow, if they are not part of it yet, and a synthetic task with the given
+// id and description, that does nothing but depend on all members. Downstream tasks can depend on the returned join
+// task to continue once all members completed, while the members run in any order. The join task is marked as
+// synthetic in reports. If any task or dependency cannot be added, the workflow is left unmodified.
+func Group(w *Workflow, joinID int64, joinDesc string, members ...*Task) (*Task, error) {
+	join := NewTask(joinID, joinDesc, func(context.Context, *Task) error { return nil })
+	join.synthetic = true
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.validateJoin(join, members); err != nil {
+		return nil, err
+	}
+	var added []*Task
+	for _, member := range members {
+		if w.tasks[member.id] == member {
+			continue
+		}
+		if err := w.addTaskLocked(member); err != nil {
+			w.removeTasksLocked(added)
+			return nil, err
+		}
+		added = append(added, member)
+	}
+	if err := w.addTaskLocked(join); err != nil {
+		w.removeTasksLocked(added)
+		return nil, err
+	}
+	added = append(added, join)
+	if err := w.addDependencyLocked(join.id, false, "", taskIDs(members)...); err != nil {
+		w.removeTasksLocked(added)
+		return nil, err
+	}
+	return join, nil
+}
+
+// validateJoin returns an error, if the given join task and its members cannot be added, while the caller holds the
+// lock
+func (w *Workflow) validateJoin(join *Task, members []*Task) error {
+	if _, ok := w.tasks[join.id]; ok {
+		return fmt.Errorf("error adding join task %d: %w", join.id, ErrAlreadyExists)
+	}
+	seen := make(map[int64]bool, len(members))
+	for _, member := range members {
+		if err := w.validateTask(member); err != nil {
+			return err
+		}
+		if member.id == join.id {
+			return fmt.Errorf("error adding join task %d as its own member: %w", join.id, ErrWouldCycle)
+		}
+		if seen[member.id] {
+			return fmt.Errorf("error adding task dependency from id %d to id %d: %w", join.id, member.id, ErrDuplicateDependency)
+		}
+		seen[member.id] = true
+		if existing, ok := w.tasks[member.id]; ok && existing != member {
+			return fmt.Errorf("error adding join member %d: %w", member.id, ErrAlreadyExists)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package flow
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	w := NewWorkflow()
+	join := NewTask(1, "join", nop)
+	if err := w.AddTasks([]*Task{join, NewTask(11, "existing", nop)}); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := ForEach(w, 10, "format disk %s", []string{"sda", "sdb"}, join, func(string) Fn { return nop })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[0].ID() != 10 || tasks[1].ID() != 12 || tasks[1].Description() != "format disk sdb" {
+		t.Fatalf("unexpected tasks %v", tasks)
+	}
+	for _, task := range tasks {
+		if ok, err := w.DependsOn(join, task); err != nil || !ok {
+			t.Fatalf("expected join to depend on %s, got %v", task, err)
+		}
+	}
+}
+
+func TestForEachAllOrNothing(t *testing.T) {
+	w := NewWorkflow()
+	join := NewTask(1, "join", nop)
+	if err := w.AddTask(join); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ForEach(w, 10, "format disk %s", []string{"sda", "sdb"}, join, func(item string) Fn {
+		if item == "sdb" {
+			return nil
+		}
+		return nop
+	})
+	if !errors.Is(err, ErrInvalidTask) {
+		t.Fatalf("expected error matching ErrInvalidTask, got %v", err)
+	}
+	if n := w.NumTasks(); n != 1 {
+		t.Fatalf("expected the workflow to be unmodified, got %d tasks", n)
+	}
+	if _, err := ForEach(w, 10, "%s", []string{"sda"}, NewTask(2, "unknown", nop), func(string) Fn { return nop }); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	w := NewWorkflow()
+	existing := NewTask(2, "existing", nop)
+	if err := w.AddTask(existing); err != nil {
+		t.Fatal(err)
+	}
+	first, last, err := Chain(w, NewTask(1, "a", nop), existing, NewTask(3, "c", nop))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID() != 1 || last.ID() != 3 {
+		t.Fatalf("expected the chain from 1 to 3, got %s and %s", first, last)
+	}
+	ids, err := w.OrderedTaskIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 || w.NumDependencies() != 2 {
+		t.Fatalf("expected the chain 1, 2, 3, got %v with %d dependencies", ids, w.NumDependencies())
+	}
+}
+
+func TestChainAllOrNothing(t *testing.T) {
+	newWorkflow := func(t *testing.T) (*Workflow, *Task, *Task) {
+		w := NewWorkflow()
+		a, b := NewTask(1, "a", nop), NewTask(2, "b", nop)
+		if err := w.AddTasks([]*Task{a, b}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddDependency(a, b); err != nil {
+			t.Fatal(err)
+		}
+		return w, a, b
+	}
+	tests := []struct {
+		name  string
+		chain func(a, b *Task) []*Task
+		want  error
+	}{
+		{
+			name:  "invalid later task",
+			chain: func(*Task, *Task) []*Task { return []*Task{NewTask(3, "c", nop), NewTask(4, "d", nil)} },
+			want:  ErrInvalidTask,
+		},
+		{
+			name:  "later task is a dependency",
+			chain: func(a, b *Task) []*Task { return []*Task{NewTask(3, "c", nop), a, b} },
+			want:  ErrWouldCycle,
+		},
+		{
+			name:  "other task with the same id",
+			chain: func(*Task, *Task) []*Task { return []*Task{NewTask(3, "c", nop), NewTask(1, "other", nop)} },
+			want:  ErrAlreadyExists,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, a, b := newWorkflow(t)
+			hash := w.DefinitionHash()
+			if _, _, err := Chain(w, tt.chain(a, b)...); !errors.Is(err, tt.want) {
+				t.Fatalf("expected error matching %v, got %v", tt.want, err)
+			}
+			if w.DefinitionHash() != hash || w.NumTasks() != 2 || w.NumDependencies() != 1 {
+				t.Fatal("expected the workflow to be unmodified")
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	w := NewWorkflow()
+	existing := NewTask(1, "existing", nop)
+	if err := w.AddTask(existing); err != nil {
+		t.Fatal(err)
+	}
+	members := []*Task{existing, NewTask(2, "b", nop)}
+	join, err := Group(w, 10, "join", members...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range members {
+		if ok, err := w.DependsOn(join, member); err != nil || !ok {
+			t.Fatalf("expected the join task to depend on %s, got %v", member, err)
+		}
+	}
+	if w.NumTasks() != 3 {
+		t.Fatalf("expected the members and the join task, got %d tasks", w.NumTasks())
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range report.Tasks {
+		if task.Synthetic != (task.ID == 10) {
+			t.Fatalf("expected only the join task to be synthetic, got %+v", task)
+		}
+	}
+}
+
+func TestGroupAllOrNothing(t *testing.T) {
+	tests := []struct {
+		name    string
+		joinID  int64
+		members func(existing *Task) []*Task
+		want    error
+	}{
+		{
+			name:    "duplicate member",
+			joinID:  10,
+			members: func(existing *Task) []*Task { return []*Task{NewTask(2, "b", nop), existing, existing} },
+			want:    ErrDuplicateDependency,
+		},
+		{
+			name:    "existing join id",
+			joinID:  1,
+			members: func(*Task) []*Task { return []*Task{NewTask(2, "b", nop)} },
+			want:    ErrAlreadyExists,
+		},
+		{
+			name:    "join task as member",
+			joinID:  2,
+			members: func(*Task) []*Task { return []*Task{NewTask(2, "b", nop)} },
+			want:    ErrWouldCycle,
+		},
+		{
+			name:    "invalid member",
+			joinID:  10,
+			members: func(*Task) []*Task { return []*Task{NewTask(2, "b", nop), NewTask(3, "c", nil)} },
+			want:    ErrInvalidTask,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkflow()
+			existing := NewTask(1, "existing", nop)
+			if err := w.AddTask(existing); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Group(w, tt.joinID, "join", tt.members(existing)...); !errors.Is(err, tt.want) {
+				t.Fatalf("expected error matching %v, got %v", tt.want, err)
+			}
+			if w.NumTasks() != 1 || w.NumDependencies() != 0 {
+				t.Fatal("expected the workflow to be unmodified")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package flow
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJUnit writes the report as JUnit XML to out, e.g. to show the results of the tasks in the test reports of a
+// CI system. Each task is a testcase of a single testsuite with the given name, whose time is the duration of its
+// last invocation as measured by the timing middleware. Failed tasks carry their error and whether it is fatal,
+// skipped tasks their reason. Tasks that did not complete are reported as skipped with their status.
+func (r Report) WriteJUnit(out io.Writer, suiteName string) error {
+	suite := junitTestSuite{
+		Name:  suiteName,
+		Tests: len(r.Tasks),
+		Cases: make([]junitTestCase, 0, len(r.Tasks)),
+	}
+	var total time.Duration
+	for _, task := range r.Tasks {
+		total += task.Durations.Last
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("task %d (%s)", task.ID, task.Description),
+			ClassName: suiteName,
+			Time:      junitTime(task.Durations.Last),
+		}
+		switch task.Status {
+		case Succeeded:
+		case Failed:
+			suite.Failures++
+			kind := "retryable"
+			if IsFatal(task.Err) {
+				kind = "fatal"
+			}
+			msg := errorMessage(task.Err)
+			tc.Failure = &junitFailure{Message: msg, Type: kind, Text: msg}
+		case Skipped:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: task.Reason}
+		default:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: task.Status.String()}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitTime(total)
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return fmt.Errorf("error writing junit report of workflow %s: %w", r.Workflow, err)
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+// junitTime formats the given duration in seconds
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
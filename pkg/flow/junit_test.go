@@ -0,0 +1,55 @@
+package flow_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestWriteJUnit(t *testing.T) {
+	report := flow.Report{
+		Workflow: "deploy",
+		Tasks: []flow.TaskReport{
+			{ID: 1, Description: "create V1", Status: flow.Succeeded,
+				Durations: flow.DurationStats{Count: 1, Last: 1500 * time.Millisecond}},
+			{ID: 2, Description: "render <config>", Status: flow.Failed, Err: errors.New("api unavailable"),
+				Durations: flow.DurationStats{Count: 2, Last: 250 * time.Millisecond}},
+			{ID: 3, Description: "validate spec", Status: flow.Failed,
+				Err: flow.NewFatalError(errors.New("invalid spec"))},
+			{ID: 4, Description: "upload", Status: flow.Skipped, Reason: "unchanged"},
+			{ID: 5, Description: "release", Status: flow.Pending},
+		},
+	}
+	var out bytes.Buffer
+	if err := report.WriteJUnit(&out, "deploy"); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "junit.golden.xml", out.Bytes())
+}
+
+// assertGolden compares the given output with the golden file of the given name in testdata, which is written
+// instead, if the tests run with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
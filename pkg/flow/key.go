@@ -0,0 +1,82 @@
+package flow
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// KeyCollisionError indicates that a task cannot be added, because its id is already used by a task with a
+// different key, see NewKeyedTask, e.g. a keyed task whose id derived from its key collides with another keyed or
+// unkeyed task. It matches ErrAlreadyExists.
+type KeyCollisionError struct {
+	// ID is the colliding id
+	ID int64
+	// Key is the key of the task that was added, which is empty, if it has no key
+	Key string
+	// ExistingKey is the key of the task that already uses the id, which is empty, if it has no key
+	ExistingKey string
+}
+
+func (e KeyCollisionError) Error() string {
+	switch {
+	case e.Key == "":
+		return fmt.Sprintf("%v: id %d of a task without key collides with key %q", ErrAlreadyExists, e.ID,
+			e.ExistingKey)
+	case e.ExistingKey == "":
+		return fmt.Sprintf("%v: id %d of key %q collides with a task without key", ErrAlreadyExists, e.ID, e.Key)
+	}
+	return fmt.Sprintf("%v: id %d of key %q collides with key %q", ErrAlreadyExists, e.ID, e.Key, e.ExistingKey)
+}
+
+// Is returns true for ErrAlreadyExists
+func (e KeyCollisionError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+// IDFromKey derives a non-negative task id from the given key with the 64-bit FNV-1a hash, whose sign bit is
+// cleared, e.g. from "create-vlan-120". The id is stable across regenerations of a workflow from the same source
+// data, so that its recorded state keeps matching. For n keys, the probability of a collision is about
+// n²/2⁶⁴, i.e. about 5e-8 for a million keys.
+func IDFromKey(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+// NewKeyedTask creates a new task like NewTask, whose id is derived from the given key, see IDFromKey. The task
+// remembers the key, see Task.Key and Workflow.TaskByKey. Adding it to a workflow, that has a task with the same
+// id but a different or no key, fails with a KeyCollisionError, as does adding an unkeyed task with the id of a
+// keyed one. Adding a second task with the same key fails with ErrAlreadyExists. The id can be overridden with
+// OverrideID, e.g. to resolve a collision.
+func NewKeyedTask(key string, desc string, fn Fn, opts ...TaskOption) *Task {
+	task := NewTask(IDFromKey(key), desc, fn, opts...)
+	task.key = key
+	return task
+}
+
+// OverrideID sets the id of the task, e.g. instead of the id derived from the key of a keyed task
+func OverrideID(id int64) TaskOption {
+	return func(t *Task) {
+		t.id = id
+	}
+}
+
+// Key returns the key of the task, or an empty string, if it was not created with a key, see NewKeyedTask
+func (j *Task) Key() string {
+	return j.key
+}
+
+// TaskByKey returns the task with the given key, see NewKeyedTask
+func (w *Workflow) TaskByKey(key string) (*Task, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if key == "" {
+		return nil, false
+	}
+	id, ok := w.keys[key]
+	if !ok {
+		return nil, false
+	}
+	return w.tasks[id], true
+}
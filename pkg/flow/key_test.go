@@ -0,0 +1,114 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestIDFromKey(t *testing.T) {
+	id := flow.IDFromKey("create-vlan-120")
+	if id < 0 || id != flow.IDFromKey("create-vlan-120") {
+		t.Fatalf("expected a stable non-negative id, got %d", id)
+	}
+	if id == flow.IDFromKey("create-vlan-121") {
+		t.Fatal("expected different keys to have different ids")
+	}
+	if task := flow.NewKeyedTask("create-vlan-120", "create VLAN 120", nop); task.ID() != id ||
+		task.Key() != "create-vlan-120" {
+		t.Fatalf("expected the keyed task to have id %d and its key, got %s with key %q", id, task, task.Key())
+	}
+}
+
+func TestKeyCollision(t *testing.T) {
+	tests := []struct {
+		name        string
+		task        *flow.Task
+		key         string
+		existingKey string
+	}{
+		{
+			name: "keyed task",
+			task: flow.NewKeyedTask("b", "b", nop, flow.OverrideID(flow.IDFromKey("a"))),
+			key:  "b", existingKey: "a",
+		},
+		{
+			name: "unkeyed task",
+			task: flow.NewTask(flow.IDFromKey("a"), "b", nop),
+			key:  "", existingKey: "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := flow.NewWorkflow()
+			if err := w.AddTask(flow.NewKeyedTask("a", "a", nop)); err != nil {
+				t.Fatal(err)
+			}
+			err := w.AddTask(tt.task)
+			var collisionErr flow.KeyCollisionError
+			if !errors.Is(err, flow.ErrAlreadyExists) || !errors.As(err, &collisionErr) {
+				t.Fatalf("expected a KeyCollisionError, got %v", err)
+			}
+			if collisionErr.ID != flow.IDFromKey("a") || collisionErr.Key != tt.key ||
+				collisionErr.ExistingKey != tt.existingKey {
+				t.Fatalf("expected the collision of keys %q and %q, got %+v", tt.key, tt.existingKey, collisionErr)
+			}
+		})
+	}
+
+	t.Run("keyed task on unkeyed task", func(t *testing.T) {
+		w := flow.NewWorkflow()
+		if err := w.AddTask(flow.NewTask(flow.IDFromKey("a"), "a", nop)); err != nil {
+			t.Fatal(err)
+		}
+		var collisionErr flow.KeyCollisionError
+		if err := w.AddTask(flow.NewKeyedTask("a", "a", nop)); !errors.As(err, &collisionErr) ||
+			collisionErr.Key != "a" || collisionErr.ExistingKey != "" {
+			t.Fatalf("expected a KeyCollisionError with key a, got %v", err)
+		}
+	})
+
+	t.Run("same key", func(t *testing.T) {
+		w := flow.NewWorkflow()
+		if err := w.AddTask(flow.NewKeyedTask("a", "a", nop)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.AddTask(flow.NewKeyedTask("a", "a", nop, flow.OverrideID(1))); !errors.Is(err,
+			flow.ErrAlreadyExists) {
+			t.Fatalf("expected error matching ErrAlreadyExists, got %v", err)
+		}
+	})
+}
+
+func TestTaskByKey(t *testing.T) {
+	w := flow.NewWorkflow()
+	task := flow.NewKeyedTask("create-vlan-120", "create VLAN 120", nop, flow.OverrideID(1))
+	if err := w.AddTasks([]*flow.Task{task, flow.NewTask(2, "unkeyed", nop)}); err != nil {
+		t.Fatal(err)
+	}
+	if task.ID() != 1 {
+		t.Fatalf("expected OverrideID to set the id, got %d", task.ID())
+	}
+	if found, ok := w.TaskByKey("create-vlan-120"); !ok || found != task {
+		t.Fatalf("expected the keyed task, got %v", found)
+	}
+	if _, ok := w.TaskByKey(""); ok {
+		t.Fatal("expected no task for the empty key")
+	}
+	if err := w.RemapIDs(func(old int64) int64 { return old + 10 }); err != nil {
+		t.Fatal(err)
+	}
+	if found, ok := w.TaskByKey("create-vlan-120"); !ok || found != task || found.ID() != 11 {
+		t.Fatalf("expected the keyed task with its new id, got %v", found)
+	}
+	if err := w.RemoveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.TaskByKey("create-vlan-120"); ok {
+		t.Fatal("expected no task after it was removed")
+	}
+}
+
+func nop(context.Context, *flow.Task) error { return nil }
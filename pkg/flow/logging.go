@@ -0,0 +1,104 @@
+package flow
+
+import (
+	"context"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// DefaultMaxErrorLength is the default length, after which the logging middleware truncates error strings
+const DefaultMaxErrorLength = 1024
+
+// LoggingOption configures the logging middleware
+type LoggingOption func(c *loggingConfig)
+
+type loggingConfig struct {
+	logger       *slog.Logger
+	successLevel slog.Level
+	failureLevel slog.Level
+	maxErrorLen  int
+}
+
+// LoggingLogger sets the logger of the logging middleware. Defaults to the logger of the workflow, see WithLogger.
+func LoggingLogger(logger *slog.Logger) LoggingOption {
+	return func(c *loggingConfig) {
+		c.logger = logger
+	}
+}
+
+// LoggingLevels sets the levels for logging successful and failed tasks. Defaults to slog.LevelInfo and
+// slog.LevelError.
+func LoggingLevels(success, failure slog.Level) LoggingOption {
+	return func(c *loggingConfig) {
+		c.successLevel = success
+		c.failureLevel = failure
+	}
+}
+
+// LoggingMaxErrorLength sets the length, after which error strings are truncated. Defaults to DefaultMaxErrorLength.
+func LoggingMaxErrorLength(n int) LoggingOption {
+	return func(c *loggingConfig) {
+		c.maxErrorLen = n
+	}
+}
+
+// Logging returns a middleware that logs the start of each task at debug level and its end with duration,
+// attempt and error, if any. If neither the option nor the workflow provide a logger, nothing is logged.
+func Logging(opts ...LoggingOption) Middleware {
+	config := loggingConfig{
+		successLevel: slog.LevelInfo,
+		failureLevel: slog.LevelError,
+		maxErrorLen:  DefaultMaxErrorLength,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return func(next Fn) Fn {
+		return func(ctx context.Context, task *Task) error {
+			logger := config.logger
+			tc, tcErr := fromContext(ctx)
+			if logger == nil && tcErr == nil {
+				logger = tc.w.logger
+			}
+			if logger == nil {
+				return next(ctx, task)
+			}
+
+			attrs := []any{slog.Int64("task", task.id), slog.String("description", task.desc)}
+			if tcErr == nil {
+				if tc.w.name != "" {
+					attrs = append(attrs, slog.String("workflow", tc.w.name))
+				}
+				tc.w.mu.RLock()
+				attrs = append(attrs, slog.Uint64("run", tc.w.runID))
+				attrs = append(attrs, slog.Int("attempt", tc.w.states[task.id].attempts))
+				tc.w.mu.RUnlock()
+			}
+			logger.DebugContext(ctx, "task started", attrs...)
+
+			clock := clockFrom(ctx)
+			start := clock.Now()
+			err := next(ctx, task)
+			attrs = append(attrs, slog.Duration("duration", clock.Now().Sub(start)))
+			if err != nil {
+				attrs = append(attrs, slog.String("error", truncate(err.Error(), config.maxErrorLen)))
+				logger.Log(ctx, config.failureLevel, "task failed", attrs...)
+				return err
+			}
+			logger.Log(ctx, config.successLevel, "task succeeded", attrs...)
+			return nil
+		}
+	}
+}
+
+// truncate shortens the given string to at most n bytes, if it is longer, without splitting a multi-byte rune
+func truncate(s string, n int) string {
+	if n <= 0 || len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,131 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "short", n: 10, want: "short"},
+		{s: "exactly", n: 7, want: "exactly"},
+		{s: "too long", n: 3, want: "too..."},
+		{s: "unlimited", n: 0, want: "unlimited"},
+		// "ü" and "€" are encoded with 2 and 3 bytes
+		{s: "grüße", n: 3, want: "gr..."},
+		{s: "grüße", n: 4, want: "grü..."},
+		{s: "10€ fee", n: 3, want: "10..."},
+		{s: "10€ fee", n: 4, want: "10..."},
+		{s: "10€ fee", n: 5, want: "10€..."},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d): expected %q, got %q", tt.s, tt.n, tt.want, got)
+		}
+	}
+}
+
+// logRecords returns the JSON log records written to the given buffer
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("expected a JSON log record, got %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLogging(t *testing.T) {
+	newLogger := func(buf *bytes.Buffer) *slog.Logger {
+		return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	failing := func(context.Context, *Task) error { return errors.New("quota exceeded for project") }
+	tests := []struct {
+		name string
+		// workflowLogger sets the logger of the workflow instead of the middleware
+		workflowLogger bool
+		opts           []LoggingOption
+		fn             Fn
+		want           []string
+	}{
+		{name: "success with workflow logger", workflowLogger: true, fn: nop,
+			want: []string{"DEBUG task started", "INFO task succeeded"}},
+		{name: "failure", fn: failing,
+			want: []string{"DEBUG task started", "ERROR task failed: quota exceeded for project"}},
+		{name: "levels", opts: []LoggingOption{LoggingLevels(slog.LevelDebug, slog.LevelWarn)}, fn: failing,
+			want: []string{"DEBUG task started", "WARN task failed: quota exceeded for project"}},
+		{name: "truncated error", opts: []LoggingOption{LoggingMaxErrorLength(5)}, fn: failing,
+			want: []string{"DEBUG task started", "ERROR task failed: quota..."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			wopts := []Option{WithName("machine-1")}
+			if tt.workflowLogger {
+				wopts = append(wopts, WithLogger(newLogger(&buf)))
+			} else {
+				tt.opts = append(tt.opts, LoggingLogger(newLogger(&buf)))
+			}
+			w := NewWorkflow(wopts...)
+			w.Use(Logging(tt.opts...))
+			if err := w.AddTask(NewTask(1, "create V1", tt.fn)); err != nil {
+				t.Fatal(err)
+			}
+			_ = w.Reconcile(context.Background())
+
+			var got []string
+			for _, record := range logRecords(t, &buf) {
+				if record["workflow"] != "machine-1" || record["task"] != 1.0 || record["run"] != 1.0 ||
+					record["attempt"] != 1.0 {
+					t.Fatalf("expected the workflow, task, run and attempt in the record, got %v", record)
+				}
+				msg := fmt.Sprintf("%s %s", record["level"], record["msg"])
+				if errMsg, ok := record["error"]; ok {
+					msg += fmt.Sprintf(": %s", errMsg)
+				}
+				if _, ok := record["duration"]; !ok && record["msg"] != "task started" {
+					t.Fatalf("expected the duration in the record of the end of the task, got %v", record)
+				}
+				got = append(got, msg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected records %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoggingWithoutLogger(t *testing.T) {
+	called := false
+	w := NewWorkflow()
+	w.Use(Logging())
+	if err := w.AddTask(NewTask(1, "create V1", func(context.Context, *Task) error {
+		called = true
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil || !called {
+		t.Fatalf("expected the task to run without logger, got %v", err)
+	}
+}
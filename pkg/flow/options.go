@@ -0,0 +1,298 @@
+package flow
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Option configures a Workflow at construction
+type Option func(w *Workflow)
+
+// WithName sets the name that identifies the workflow, e.g. in errors returned from Reconcile
+func WithName(name string) Option {
+	return func(w *Workflow) {
+		w.name = name
+	}
+}
+
+// WithMeta adds the metadata entry with the given key and value to the workflow
+func WithMeta(key, value string) Option {
+	return func(w *Workflow) {
+		w.meta[key] = value
+	}
+}
+
+// WithErrorClassifier sets the classifier that is consulted after each task failure to decide whether the error
+// is retryable or fatal. It can upgrade an ordinary error to a FatalError or downgrade a FatalError to its cause.
+// Defaults to DefaultErrorClassifier.
+func WithErrorClassifier(classifier ErrorClassifier) Option {
+	return func(w *Workflow) {
+		w.classifier = classifier
+	}
+}
+
+// WithContinueOnError enables the continue-on-error mode, in which Reconcile does not stop at the first error,
+// but executes all tasks whose dependencies did not fail and returns all errors joined.
+// Soft dependencies do not prevent the execution of a task, even if they failed.
+func WithContinueOnError() Option {
+	return func(w *Workflow) {
+		w.continueOnError = true
+	}
+}
+
+// WithFinalizerGracePeriod sets the timeout for tasks that always run, if the reconcile context is already done.
+// Defaults to DefaultFinalizerGracePeriod.
+func WithFinalizerGracePeriod(d time.Duration) Option {
+	return func(w *Workflow) {
+		w.finalizerGracePeriod = d
+	}
+}
+
+// WithRetryBudget limits the total number of retryable task failures across all tasks and reconciles.
+// When the budget is exhausted, the next retryable failure is escalated to a FatalError that lists the retries
+// spent per task. Failures of tasks that wait deliberately, i.e. RequeueErrors and ErrWaitingForApproval, are not
+// counted. The budget is reset by ResetStatus.
+func WithRetryBudget(totalAttempts int) Option {
+	return func(w *Workflow) {
+		w.retryBudget = totalAttempts
+	}
+}
+
+// WithErrorHistory sets the number of recent errors that are kept per task, see Workflow.ErrorHistory.
+// A size less than 1 disables the history. Defaults to DefaultErrorHistorySize.
+func WithErrorHistory(size int) Option {
+	return func(w *Workflow) {
+		w.errorHistorySize = size
+	}
+}
+
+// WithStallDetection detects tasks that fail in a number of consecutive reconciles without progress, e.g. to stop
+// an outer loop that retries forever, see StallDetection.
+func WithStallDetection(detection StallDetection) Option {
+	return func(w *Workflow) {
+		w.stall = &detection
+	}
+}
+
+// WithLazyTasks allows dependencies to reference tasks that were not added yet, e.g. to build a workflow from an
+// unordered list of edges. A referenced task that does not exist is created as a placeholder, that must be
+// fulfilled by adding the task before the workflow is ordered or reconciled, see Workflow.Validate.
+// By default, dependencies on unknown tasks are rejected with ErrTaskNotFound.
+func WithLazyTasks() Option {
+	return func(w *Workflow) {
+		w.lazy = true
+	}
+}
+
+// WithStrictTasks additionally rejects tasks with an empty description or a negative id with ErrInvalidTask,
+// when they are added
+func WithStrictTasks() Option {
+	return func(w *Workflow) {
+		w.strict = true
+	}
+}
+
+// WithIncrementalOrder maintains the executable order of the tasks while tasks and dependencies are added,
+// instead of sorting all tasks, when the order is needed after a change. This pays off for large workflows that
+// are built incrementally and queried in between. The order is a valid executable order, but independent tasks are
+// not ordered by id.
+func WithIncrementalOrder() Option {
+	return func(w *Workflow) {
+		w.incremental = newIncrementalOrder()
+	}
+}
+
+// WithDescriptionOrder orders tasks that do not depend on each other by description and then by id, so that the
+// order does not depend on how ids were assigned, e.g. when workflows with remapped ids are merged.
+// See Workflow.SetTieBreaker.
+func WithDescriptionOrder() Option {
+	return func(w *Workflow) {
+		w.tieBreaker = ByDescription
+	}
+}
+
+// WithClock sets the clock used by time-based tasks, e.g. to test them without waiting. Defaults to RealClock.
+func WithClock(clock Clock) Option {
+	return func(w *Workflow) {
+		w.clock = clock
+	}
+}
+
+// WithLogger sets the logger of the workflow, which is used e.g. by the logging middleware
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Workflow) {
+		w.logger = logger
+	}
+}
+
+// WithCheckFirst calls the check function of a task before invoking its reconcile function, see WithCheck.
+// If the task is in sync, it succeeds without invoking the reconcile function. If the check drifted or failed, the
+// reconcile function is invoked as usual.
+func WithCheckFirst() Option {
+	return func(w *Workflow) {
+		w.checkFirst = true
+	}
+}
+
+// WithPersistentState includes the state bag in snapshots, so that a reconcile after Restore continues with the
+// values of the reconcile that was snapshotted, see State. The values must be JSON-marshalable.
+func WithPersistentState() Option {
+	return func(w *Workflow) {
+		w.persistState = true
+	}
+}
+
+// WithAuditWriter enables the audit trail of the workflow, which writes an AuditEvent as a line of JSON to out
+// for the start and end of every reconcile and for every task that is started, succeeds, fails or is skipped.
+// Each line is written by a single call and flushed, if out has a Flush method like bufio.Writer. Errors writing
+// the audit trail do not affect the workflow, but are counted, see Workflow.AuditErrors.
+func WithAuditWriter(out io.Writer) Option {
+	return func(w *Workflow) {
+		w.auditor = &auditor{out: out}
+	}
+}
+
+// WithTaskOutput sets the destination of the output that tasks write with TaskWriter. Defaults to os.Stdout.
+func WithTaskOutput(out io.Writer) Option {
+	return func(w *Workflow) {
+		w.output = &taskOutput{out: out}
+	}
+}
+
+// TaskOption configures a Task at construction
+type TaskOption func(t *Task)
+
+// Labels adds the given labels to the task, e.g. to categorize tasks and query them with Workflow.TasksByLabel.
+// The labels are copied, so they cannot change after the task was created.
+func Labels(labels map[string]string) TaskOption {
+	return func(t *Task) {
+		for k, v := range labels {
+			t.labels[k] = v
+		}
+	}
+}
+
+// WithValue sets the metadata value for the given key, which the task's Fn can read with Task.Value or TaskValue
+func WithValue(key string, value any) TaskOption {
+	return func(t *Task) {
+		t.values[key] = value
+	}
+}
+
+// WithCompensation sets the function that undoes the effects of the task, see Workflow.Teardown
+func WithCompensation(fn Fn) TaskOption {
+	return func(t *Task) {
+		t.compensateFn = fn
+	}
+}
+
+// AlwaysRun marks the task to be executed even if the workflow is aborted by an earlier error, e.g. to release
+// locks or send notifications.
+func AlwaysRun() TaskOption {
+	return func(t *Task) {
+		t.alwaysRun = true
+	}
+}
+
+// SkipIf sets a predicate that is evaluated before the task's reconcile function. If it returns true, the task is
+// recorded as Skipped and its dependents proceed as if it succeeded. An error of the predicate is treated like an
+// error of the task.
+func SkipIf(predicate SkipPredicate) TaskOption {
+	return func(t *Task) {
+		t.skipIf = predicate
+	}
+}
+
+// WithFingerprint sets a function computing a fingerprint of the task's inputs. The fingerprint of the last
+// successful execution is recorded and the task is recorded as Skipped("unchanged") instead of executing its
+// reconcile function, while the fingerprint stays the same. If the fingerprint cannot be computed, the task is
+// executed as usual.
+func WithFingerprint(fn FingerprintFn) TaskOption {
+	return func(t *Task) {
+		t.fingerprintFn = fn
+	}
+}
+
+// FatalOnTimeout treats errors of the task that match context.DeadlineExceeded as fatal instead of retryable,
+// e.g. for tasks that must not be retried blindly after an interruption.
+func FatalOnTimeout() TaskOption {
+	return func(t *Task) {
+		t.fatalOnTimeout = true
+	}
+}
+
+// WithMiddleware adds middleware that wraps the reconcile function of the task at execution time.
+// It is applied in the given order within the middleware of the workflow, see Workflow.Use.
+func WithMiddleware(mw ...Middleware) TaskOption {
+	return func(t *Task) {
+		t.middleware = append(t.middleware, mw...)
+	}
+}
+
+// SLA sets the duration, within which the task should normally complete. If an execution of the task exceeds it,
+// the breach is recorded in the report and the audit trail and onBreach is called once with the elapsed time, while
+// the task keeps running, e.g. to alert about a hanging task. The time is measured with the workflow's clock. The
+// task completes only after onBreach returned.
+func SLA(d time.Duration, onBreach func(task *Task, elapsed time.Duration)) TaskOption {
+	return func(t *Task) {
+		t.sla = &slaConfig{
+			duration: d,
+			onBreach: onBreach,
+		}
+	}
+}
+
+// TaskTimeout limits each invocation of the task's reconcile function to the given duration, so that its context
+// is canceled with context.DeadlineExceeded afterwards, see FatalOnTimeout. A duration that is not positive is
+// reported as ErrInvalidTask, when the task is added.
+func TaskTimeout(d time.Duration) TaskOption {
+	return func(t *Task) {
+		if d <= 0 {
+			t.problems = append(t.problems, fmt.Sprintf("timeout %s is not positive", d))
+		}
+		t.timeout = d
+	}
+}
+
+// TaskRetries limits the number of retryable failures of the task since it last succeeded, across reconciles.
+// The failure after the last retry is escalated to a FatalError. Failures of tasks that wait deliberately, i.e.
+// RequeueErrors and ErrWaitingForApproval, are not counted. A negative number is reported as ErrInvalidTask, when
+// the task is added.
+func TaskRetries(n int) TaskOption {
+	return func(t *Task) {
+		if n < 0 {
+			t.problems = append(t.problems, fmt.Sprintf("retries %d are negative", n))
+		}
+		t.retries = n
+	}
+}
+
+// WithCheck sets the function that verifies whether the desired state of the task is present without changing
+// anything, see Workflow.Check and WithCheckFirst
+func WithCheck(check CheckFn) TaskOption {
+	return func(t *Task) {
+		t.check = check
+	}
+}
+
+// CircuitBreaker adds a circuit breaker to the task, which opens after the given number of consecutive failures.
+// While the circuit is open, the task fails fast with a RequeueAfter error matching ErrCircuitOpen instead of
+// invoking its reconcile function. After the open duration, a single invocation probes whether the task recovered.
+// The state of the circuit persists across reconciles. A threshold or open duration that is not positive is reported
+// as ErrInvalidTask, when the task is added.
+func CircuitBreaker(threshold int, openDuration time.Duration) TaskOption {
+	return func(t *Task) {
+		if threshold <= 0 {
+			t.problems = append(t.problems, fmt.Sprintf("circuit breaker threshold %d is not positive", threshold))
+		}
+		if openDuration <= 0 {
+			t.problems = append(t.problems, fmt.Sprintf("circuit breaker open duration %s is not positive", openDuration))
+		}
+		t.breaker = &breakerConfig{
+			threshold:    threshold,
+			openDuration: openDuration,
+		}
+	}
+}
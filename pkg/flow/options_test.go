@@ -0,0 +1,161 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestInvalidTaskOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []flow.TaskOption
+		problem string
+	}{
+		{name: "zero timeout", opts: []flow.TaskOption{flow.TaskTimeout(0)}, problem: "timeout 0s is not positive"},
+		{name: "negative timeout", opts: []flow.TaskOption{flow.TaskTimeout(-time.Second)},
+			problem: "timeout -1s is not positive"},
+		{name: "negative retries", opts: []flow.TaskOption{flow.TaskRetries(-1)}, problem: "retries -1 are negative"},
+		{name: "several problems", opts: []flow.TaskOption{flow.TaskTimeout(0), flow.TaskRetries(-2)},
+			problem: "timeout 0s is not positive, retries -2 are negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := flow.NewWorkflow()
+			err := w.AddTask(flow.NewTask(1, "create V1", nop, tt.opts...))
+			if !errors.Is(err, flow.ErrInvalidTask) || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("expected error matching ErrInvalidTask with %q, got %v", tt.problem, err)
+			}
+			if w.NumTasks() != 0 {
+				t.Fatal("expected the invalid task not to be added")
+			}
+		})
+	}
+
+	w := flow.NewWorkflow()
+	if err := w.AddTask(flow.NewTask(1, "create V1", nop, flow.TaskTimeout(time.Second), flow.TaskRetries(0))); err != nil {
+		t.Fatalf("expected valid options to be accepted, got %v", err)
+	}
+}
+
+func TestTaskRetries(t *testing.T) {
+	w := flow.NewWorkflow()
+	attempts := 0
+	if err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		attempts++
+		if attempts == 2 {
+			return nil
+		}
+		return errors.New("api unavailable")
+	}, flow.TaskRetries(1))); err != nil {
+		t.Fatal(err)
+	}
+	// a success resets the retries of the task
+	for _, fatal := range []bool{false, false, false, true} {
+		if err := w.Reconcile(context.Background()); (err != nil && flow.IsFatal(err)) != fatal {
+			t.Fatalf("attempt %d: expected the error to be fatal: %t, got %v", attempts, fatal, err)
+		}
+	}
+}
+
+func TestValidateTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		task    *flow.Task
+		problem string
+	}{
+		{name: "nil task", task: nil, problem: "task is nil"},
+		{name: "nil reconcile function", task: flow.NewTask(1, "create V1", nil), problem: "reconcile function is nil"},
+		{name: "empty description", strict: true, task: flow.NewTask(1, "", nop), problem: "description is empty"},
+		{name: "negative id", strict: true, task: flow.NewTask(-1, "create V1", nop), problem: "id is negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []flow.Option
+			if tt.strict {
+				opts = append(opts, flow.WithStrictTasks())
+			}
+			err := flow.NewWorkflow(opts...).AddTask(tt.task)
+			if !errors.Is(err, flow.ErrInvalidTask) || !strings.Contains(err.Error(), tt.problem) {
+				t.Fatalf("expected error matching ErrInvalidTask with %q, got %v", tt.problem, err)
+			}
+		})
+	}
+
+	// without strict validation, an empty description and a negative id are accepted
+	w := flow.NewWorkflow()
+	if err := w.AddTasks([]*flow.Task{flow.NewTask(1, "", nop), flow.NewTask(-1, "create V1", nop)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTasksByLabel(t *testing.T) {
+	labels := map[string]string{"kind": "network"}
+	w := flow.NewWorkflow()
+	err := w.AddTasks([]*flow.Task{
+		flow.NewTask(3, "create VLAN", nop, flow.Labels(labels)),
+		flow.NewTask(1, "create bond", nop, flow.Labels(labels), flow.Labels(map[string]string{"optional": "true"})),
+		flow.NewTask(2, "format disk", nop, flow.Labels(map[string]string{"kind": "storage"})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(tasks []*flow.Task) []int64 {
+		var ids []int64
+		for _, task := range tasks {
+			ids = append(ids, task.ID())
+		}
+		return ids
+	}
+	if got := ids(w.TasksByLabel("kind", "network")); !reflect.DeepEqual(got, []int64{1, 3}) {
+		t.Fatalf("expected the network tasks ordered by id, got %v", got)
+	}
+	if got := ids(w.TasksByLabel("optional", "true")); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("expected the optional task, got %v", got)
+	}
+	if got := w.TasksByLabel("kind", "compute"); len(got) != 0 {
+		t.Fatalf("expected no tasks, got %v", ids(got))
+	}
+
+	// the labels are fixed once the task is created
+	labels["kind"] = "storage"
+	w.TasksByLabel("kind", "network")[0].Labels()["kind"] = "storage"
+	if got := ids(w.TasksByLabel("kind", "network")); !reflect.DeepEqual(got, []int64{1, 3}) {
+		t.Fatalf("expected the labels not to change, got network tasks %v", got)
+	}
+}
+
+func TestTaskValues(t *testing.T) {
+	var (
+		host    string
+		version int
+		hasHost bool
+		wrong   bool
+		missing any
+	)
+	w := flow.NewWorkflow()
+	if err := w.AddTask(flow.NewTask(1, "upgrade firmware", func(_ context.Context, task *flow.Task) error {
+		host, hasHost = flow.TaskValue[string](task, "host")
+		version = task.Value("version").(int)
+		_, wrong = flow.TaskValue[string](task, "version")
+		missing = task.Value("missing")
+		return nil
+	}, flow.WithValue("host", "node-1"), flow.WithValue("version", 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if host != "node-1" || !hasHost || version != 3 {
+		t.Fatalf("expected the values of the task, got %q (%t) and %d", host, hasHost, version)
+	}
+	if wrong || missing != nil {
+		t.Fatalf("expected no value of the wrong type or for a missing key, got %t and %v", wrong, missing)
+	}
+}
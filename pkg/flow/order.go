@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"fmt"
+	"sort"
+)
+
+// incrementalOrder maintains a topological order of the graph while nodes and edges are added,
+// following the algorithm of Pearce and Kelly. Adding an edge only reorders the affected region
+// between its endpoints instead of sorting the whole graph.
+type incrementalOrder struct {
+	// ids of the nodes in topological order
+	seq []int64
+	// position of each node in seq, key is nodeID
+	index map[int64]int
+}
+
+func newIncrementalOrder() *incrementalOrder {
+	return &incrementalOrder{index: make(map[int64]int)}
+}
+
+// addNode appends the node, which has no edges yet, to the order
+func (o *incrementalOrder) addNode(id int64) {
+	o.index[id] = len(o.seq)
+	o.seq = append(o.seq, id)
+}
+
+// removeNode removes the node, whose edges were removed from the workflow's graph, from the order
+func (o *incrementalOrder) removeNode(id int64) {
+	i := o.index[id]
+	delete(o.index, id)
+	o.seq = append(o.seq[:i], o.seq[i+1:]...)
+	for ; i < len(o.seq); i++ {
+		o.index[o.seq[i]] = i
+	}
+}
+
+// addEdge restores the order after an edge from -> to was added to the workflow's graph,
+// which must not have introduced a cycle
+func (o *incrementalOrder) addEdge(w *Workflow, from, to int64) {
+	lb, ub := o.index[to], o.index[from]
+	if ub < lb {
+		return
+	}
+
+	// nodes after "to" that must move behind "from" and nodes before "from" that must move ahead of "to"
+	forward := o.collect(to, func(id int64) []int64 { return successors(w, id) }, func(i int) bool { return i < ub })
+	backward := o.collect(from, func(id int64) []int64 { return predecessors(w, id) }, func(i int) bool { return i > lb })
+
+	// the affected positions are reassigned in order, the backward region first
+	positions := make([]int, 0, len(forward)+len(backward))
+	for _, id := range backward {
+		positions = append(positions, o.index[id])
+	}
+	for _, id := range forward {
+		positions = append(positions, o.index[id])
+	}
+	sort.Ints(positions)
+	for i, id := range append(backward, forward...) {
+		o.index[id] = positions[i]
+		o.seq[positions[i]] = id
+	}
+}
+
+// collect returns the nodes reachable from start through next, whose positions are within the bound,
+// sorted by their current position
+func (o *incrementalOrder) collect(start int64, next func(id int64) []int64, within func(i int) bool) []int64 {
+	visited := map[int64]bool{start: true}
+	region := []int64{start}
+	stack := []int64{start}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, n := range next(id) {
+			if !visited[n] && within(o.index[n]) {
+				visited[n] = true
+				region = append(region, n)
+				stack = append(stack, n)
+			}
+		}
+	}
+	sort.Slice(region, func(i, j int) bool { return o.index[region[i]] < o.index[region[j]] })
+	return region
+}
+
+// successors returns the ids of the tasks that depend on the given task
+func successors(w *Workflow, id int64) []int64 {
+	var ids []int64
+	nodes := w.graph.From(id)
+	for nodes.Next() {
+		ids = append(ids, nodes.Node().ID())
+	}
+	return ids
+}
+
+// predecessors returns the ids of the dependencies of the given task
+func predecessors(w *Workflow, id int64) []int64 {
+	var ids []int64
+	nodes := w.graph.To(id)
+	for nodes.Next() {
+		ids = append(ids, nodes.Node().ID())
+	}
+	return ids
+}
+
+// VerifyOrder checks that the given sequence of task ids is a valid execution order of the workflow, i.e. that it
+// contains every task exactly once and never places a task before one of its dependencies. It returns an error
+// describing the first violation in the sequence.
+func VerifyOrder(w *Workflow, order []int64) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	position := make(map[int64]int, len(order))
+	for i, id := range order {
+		if _, ok := w.tasks[id]; !ok {
+			return fmt.Errorf("invalid order at position %d: task id %d: %w", i, id, ErrTaskNotFound)
+		}
+		if first, ok := position[id]; ok {
+			return fmt.Errorf("invalid order at position %d: task id %d was already executed at position %d", i, id, first)
+		}
+		position[id] = i
+	}
+	if len(position) != len(w.tasks) {
+		ids := make([]int64, 0, len(w.tasks)-len(position))
+		for id := range w.tasks {
+			if _, ok := position[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return fmt.Errorf("invalid order: task ids %v are missing", ids)
+	}
+
+	// checking the direct dependencies of each task is sufficient for the transitive ones
+	for i, id := range order {
+		deps := w.graph.To(id)
+		for deps.Next() {
+			if depID := deps.Node().ID(); position[depID] > i {
+				return fmt.Errorf("invalid order at position %d: %s is executed before its dependency %s at position %d",
+					i, w.tasks[id], w.tasks[depID], position[depID])
+			}
+		}
+	}
+	return nil
+}
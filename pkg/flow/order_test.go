@@ -0,0 +1,259 @@
+package flow
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/graph/topo"
+)
+
+// layeredWorkflow creates a workflow with the given number of tasks in layers of the given width, where each task
+// depends on two tasks of the previous layer
+func layeredWorkflow(tb testing.TB, tasks, width int, opts ...Option) *Workflow {
+	tb.Helper()
+	w := NewWorkflow(opts...)
+	for i := 0; i < tasks; i++ {
+		if err := w.AddTask(NewTask(int64(i), fmt.Sprintf("task %d", i), nop)); err != nil {
+			tb.Fatal(err)
+		}
+		if i < width {
+			continue
+		}
+		layer := i - i%width - width
+		deps := []int64{int64(layer + i%width)}
+		if other := int64(layer + (i+1)%width); other != deps[0] {
+			deps = append(deps, other)
+		}
+		if err := w.AddDependencyByID(int64(i), deps...); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return w
+}
+
+func TestOrderCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(w *Workflow) error
+		want   []int64
+	}{
+		{
+			name:   "AddTask",
+			mutate: func(w *Workflow) error { return w.AddTask(NewTask(0, "first", nop)) },
+			want:   []int64{0, 1, 2, 3},
+		},
+		{
+			name:   "AddDependency",
+			mutate: func(w *Workflow) error { return w.AddDependencyByID(1, 3) },
+			want:   []int64{2, 3, 1},
+		},
+		{
+			name:   "RemoveTask",
+			mutate: func(w *Workflow) error { return w.RemoveTaskByID(1) },
+			want:   []int64{2, 3},
+		},
+		{
+			name:   "RemoveDependency",
+			mutate: func(w *Workflow) error { return w.RemoveDependencyByID(3, 2) },
+			want:   []int64{1, 2, 3},
+		},
+		{
+			name: "SetTieBreaker",
+			mutate: func(w *Workflow) error {
+				w.SetTieBreaker(func(a, b *Task) bool { return a.id > b.id })
+				return nil
+			},
+			want: []int64{2, 3, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 3 depends on 2 and 1 has no dependencies
+			w := NewWorkflow()
+			if err := w.AddTasks([]*Task{NewTask(3, "c", nop), NewTask(1, "a", nop), NewTask(2, "b", nop)}); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.AddDependencyByID(3, 2); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.OrderedTaskIDs(); err != nil {
+				t.Fatal(err)
+			}
+			if w.order == nil {
+				t.Fatal("expected the order to be cached")
+			}
+			if err := tt.mutate(w); err != nil {
+				t.Fatal(err)
+			}
+			if w.order != nil {
+				t.Fatalf("expected %s to invalidate the cached order", tt.name)
+			}
+			ids, err := w.OrderedTaskIDs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Fatalf("expected order %v, got %v", tt.want, ids)
+			}
+		})
+	}
+}
+
+func TestOrderCacheNotShared(t *testing.T) {
+	w := layeredWorkflow(t, 10, 3)
+	tasks, err := w.GetOrderedTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks[0] = nil
+	again, err := w.GetOrderedTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again[0] == nil {
+		t.Fatal("expected modifying the result not to modify the cached order")
+	}
+}
+
+func BenchmarkOrderCache(b *testing.B) {
+	w := layeredWorkflow(b, 5000, 50)
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := w.GetOrderedTasks(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.order = nil
+			if _, err := w.GetOrderedTasks(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestIncrementalOrderMatchesSort(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := 0; run < 50; run++ {
+		incremental := NewWorkflow(WithIncrementalOrder())
+		sorted := NewWorkflow()
+		nodes := 5 + r.Intn(60)
+		for i := 0; i < nodes; i++ {
+			// ids are added in random order, so that the order of insertion is no topological order
+			id := int64(r.Intn(1000))
+			for incremental.tasks[id] != nil {
+				id = int64(r.Intn(1000))
+			}
+			for _, w := range []*Workflow{incremental, sorted} {
+				if err := w.AddTask(NewTask(id, fmt.Sprint(id), nop)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		ids := make([]int64, 0, nodes)
+		for id := range incremental.tasks {
+			ids = append(ids, id)
+		}
+		for i := 0; i < 3*nodes; i++ {
+			from, to := ids[r.Intn(len(ids))], ids[r.Intn(len(ids))]
+			errIncremental := incremental.AddDependencyByID(from, to)
+			errSorted := sorted.AddDependencyByID(from, to)
+			// both reject duplicates and cycles the same way
+			if fmt.Sprint(errIncremental) != fmt.Sprint(errSorted) {
+				t.Fatalf("run %d: expected the same error for dependency from %d to %d, got %v and %v", run, from, to,
+					errIncremental, errSorted)
+			}
+			if i%5 != 0 {
+				continue
+			}
+			// the maintained order must be valid and contain the same tasks as the sorted one
+			incrementalIDs, err := incremental.OrderedTaskIDs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyOrder(incremental, incrementalIDs); err != nil {
+				t.Fatalf("run %d: invalid incremental order: %v", run, err)
+			}
+			nodes, err := topo.SortStabilized(sorted.graph, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortedIDs := make([]int64, len(nodes))
+			for i, node := range nodes {
+				sortedIDs[i] = node.ID()
+			}
+			if err := VerifyOrder(incremental, sortedIDs); err != nil {
+				t.Fatalf("run %d: sorted order is invalid for the incremental workflow: %v", run, err)
+			}
+		}
+	}
+}
+
+func BenchmarkBuildAndOrder(b *testing.B) {
+	for _, tasks := range []int{10_000, 100_000} {
+		b.Run(fmt.Sprintf("%dk", tasks/1000), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// about two dependencies per task
+				w := layeredWorkflow(b, tasks, 100)
+				if _, err := w.GetOrderedTasks(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDescriptionOrderIndependentOfIDs(t *testing.T) {
+	// dependencies between descriptions, the same logical workflow is built with different ids
+	descs := []string{"create network", "create volume", "create firewall", "start machine", "announce"}
+	deps := map[string][]string{
+		"start machine": {"create network", "create volume"},
+		"announce":      {"start machine", "create firewall"},
+	}
+	build := func(t *testing.T, ids []int64, opts ...Option) *Workflow {
+		t.Helper()
+		w := NewWorkflow(opts...)
+		byDesc := map[string]int64{}
+		for i, desc := range descs {
+			byDesc[desc] = ids[i]
+			if err := w.AddTask(NewTask(ids[i], desc, nop)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, desc := range descs {
+			for _, dep := range deps[desc] {
+				if err := w.AddDependencyByID(byDesc[desc], byDesc[dep]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		return w
+	}
+	visualize := func(t *testing.T, w *Workflow) string {
+		t.Helper()
+		s, err := w.Visualize(FormatTask(func(task *Task) string { return task.Description() }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	assignments := [][]int64{{1, 2, 3, 4, 5}, {5, 4, 3, 2, 1}, {30, 10, 20, 50, 40}}
+
+	want := visualize(t, build(t, assignments[0], WithDescriptionOrder()))
+	for _, ids := range assignments[1:] {
+		if got := visualize(t, build(t, ids, WithDescriptionOrder())); got != want {
+			t.Fatalf("ids %v: expected %q, got %q", ids, want, got)
+		}
+	}
+	// without the option, the order depends on the ids
+	if got := visualize(t, build(t, assignments[1])); got == visualize(t, build(t, assignments[0])) {
+		t.Fatalf("expected the default order to depend on the ids, got %q", got)
+	}
+}
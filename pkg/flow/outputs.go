@@ -0,0 +1,143 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gonum.org/v1/gonum/graph/topo"
+)
+
+// SetOutput records an output value of the task reconciled with the given context, e.g. an allocated IP address,
+// which the dependents of the task can read with Output. Unless the output is Sensitive, the value must be
+// JSON-marshalable to be part of a Snapshot.
+func SetOutput(ctx context.Context, key string, value any, opts ...OutputOption) error {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return err
+	}
+	var config outputConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if !config.sensitive {
+		if _, err := marshalOutput(value); err != nil {
+			return fmt.Errorf("error setting output %q of %s: %w", key, tc.task, err)
+		}
+	}
+
+	tc.w.mu.Lock()
+	defer tc.w.mu.Unlock()
+	state := tc.w.states[tc.task.id]
+	if state.outputs == nil {
+		state.outputs = make(map[string]any)
+	}
+	state.outputs[key] = value
+	if config.sensitive {
+		if state.sensitive == nil {
+			state.sensitive = make(map[string]bool)
+		}
+		state.sensitive[key] = true
+	} else {
+		delete(state.sensitive, key)
+	}
+	return nil
+}
+
+// OutputOption configures an output value, see SetOutput
+type OutputOption func(c *outputConfig)
+
+type outputConfig struct {
+	sensitive bool
+}
+
+// RedactedOutput replaces the value of a Sensitive output in reports
+const RedactedOutput = "[redacted]"
+
+// Sensitive excludes the output value from snapshots and replaces it by RedactedOutput in reports, e.g. because it
+// is a credential. Dependents read the value with Output as usual.
+func Sensitive() OutputOption {
+	return func(c *outputConfig) {
+		c.sensitive = true
+	}
+}
+
+// Output returns the output value with the given key recorded by the task with the given id.
+// To keep the data flow explicit, the task reconciled with the given context must depend (transitively)
+// on that task, otherwise an error matching ErrNotUpstream is returned.
+func Output(ctx context.Context, taskID int64, key string) (any, error) {
+	value, err := output(ctx, taskID, key)
+	if err != nil {
+		return nil, err
+	}
+	if restored, ok := value.(restoredValue); ok {
+		var decoded any
+		if err := json.Unmarshal(restored, &decoded); err != nil {
+			return nil, fmt.Errorf("error decoding restored output %q of task id %d: %w", key, taskID, err)
+		}
+		return decoded, nil
+	}
+	return value, nil
+}
+
+// output returns the recorded output value, which has not yet been decoded, if it was restored from a snapshot
+func output(ctx context.Context, taskID int64, key string) (any, error) {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w := tc.w
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	upstream := w.graph.Node(taskID)
+	if upstream == nil {
+		return nil, fmt.Errorf("error getting output %q of task id %d: %w", key, taskID, ErrTaskNotFound)
+	}
+	if !topo.PathExistsIn(w.graph, upstream, w.graph.Node(tc.task.id)) || taskID == tc.task.id {
+		return nil, fmt.Errorf("error getting output %q of task id %d for %s: %w", key, taskID, tc.task, ErrNotUpstream)
+	}
+	value, ok := w.states[taskID].outputs[key]
+	if !ok {
+		return nil, fmt.Errorf("error getting output %q of task id %d: %w", key, taskID, ErrNoOutput)
+	}
+	return value, nil
+}
+
+// resultKey is the output key under which typed tasks record their result
+const resultKey = "flow.result"
+
+// TypedFn is a reconcile function that produces a result of type T on success
+type TypedFn[T any] func(ctx context.Context, task *Task) (T, error)
+
+// NewTypedTask creates a task whose reconcile function produces a result of type T,
+// which its dependents can retrieve with Result.
+func NewTypedTask[T any](id int64, desc string, fn TypedFn[T], opts ...TaskOption) *Task {
+	return NewTask(id, desc, func(ctx context.Context, task *Task) error {
+		result, err := fn(ctx, task)
+		if err != nil {
+			return err
+		}
+		return SetOutput(ctx, resultKey, result)
+	}, opts...)
+}
+
+// Result returns the result of the typed task with the given id, see NewTypedTask.
+// Like Output, it requires the task reconciled with the given context to depend on that task.
+func Result[T any](ctx context.Context, taskID int64) (T, error) {
+	var result T
+	value, err := output(ctx, taskID, resultKey)
+	if err != nil {
+		return result, err
+	}
+	if restored, ok := value.(restoredValue); ok {
+		if err := json.Unmarshal(restored, &result); err != nil {
+			return result, fmt.Errorf("error decoding restored result of task id %d: %w", taskID, err)
+		}
+		return result, nil
+	}
+	result, ok := value.(T)
+	if !ok {
+		return result, fmt.Errorf("error getting result of task id %d: result is of type %T, not %T", taskID, value, result)
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package flow_test
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+// disk is a result type of a typed task
+type disk struct {
+	Device string
+	SizeGB int
+}
+
+func ExampleNewTypedTask() {
+	w := flow.NewWorkflow()
+	allocate := flow.NewTypedTask(1, "allocate ip", func(context.Context, *flow.Task) (netip.Addr, error) {
+		return netip.MustParseAddr("10.0.0.7"), nil
+	})
+	format := flow.NewTypedTask(2, "format disk", func(context.Context, *flow.Task) (disk, error) {
+		return disk{Device: "/dev/sda", SizeGB: 512}, nil
+	})
+	provision := flow.NewTask(3, "provision machine", func(ctx context.Context, _ *flow.Task) error {
+		ip, err := flow.Result[netip.Addr](ctx, 1)
+		if err != nil {
+			return err
+		}
+		d, err := flow.Result[disk](ctx, 2)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("provisioning %s with %s (%d GB)\n", ip, d.Device, d.SizeGB)
+		return nil
+	})
+	if err := w.AddTasks([]*flow.Task{allocate, format, provision}); err != nil {
+		panic(err)
+	}
+	if err := w.AddDependency(provision, allocate, format); err != nil {
+		panic(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		panic(err)
+	}
+	// Output: provisioning 10.0.0.7 with /dev/sda (512 GB)
+}
+
+func TestResultTypeMismatch(t *testing.T) {
+	w := flow.NewWorkflow()
+	allocate := flow.NewTypedTask(1, "allocate ip", func(context.Context, *flow.Task) (netip.Addr, error) {
+		return netip.MustParseAddr("10.0.0.7"), nil
+	})
+	var err error
+	provision := flow.NewTask(2, "provision machine", func(ctx context.Context, _ *flow.Task) error {
+		_, err = flow.Result[disk](ctx, 1)
+		return nil
+	})
+	if err := w.AddTasks([]*flow.Task{allocate, provision}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(provision, allocate); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err == nil {
+		t.Fatal("expected an error for a result of another type")
+	}
+}
+
+func TestSensitiveOutput(t *testing.T) {
+	w := flow.NewWorkflow()
+	var password any
+	login := flow.NewTask(1, "create user", func(ctx context.Context, _ *flow.Task) error {
+		if err := flow.SetOutput(ctx, "user", "admin"); err != nil {
+			return err
+		}
+		return flow.SetOutput(ctx, "password", "s3cr3t", flow.Sensitive())
+	})
+	configure := flow.NewTask(2, "configure", func(ctx context.Context, _ *flow.Task) (err error) {
+		password, err = flow.Output(ctx, 1, "password")
+		return err
+	})
+	if err := w.AddTasks([]*flow.Task{login, configure}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(configure, login); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if password != "s3cr3t" {
+		t.Fatalf("expected the dependent to read the sensitive value, got %v", password)
+	}
+
+	report, err := w.Report(flow.IncludeOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"user": "admin", "password": flow.RedactedOutput}
+	if outputs := report.Tasks[0].Outputs; !reflect.DeepEqual(outputs, want) {
+		t.Fatalf("expected outputs %v in the report, got %v", want, outputs)
+	}
+
+	snapshot, err := w.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := snapshot.Tasks[1].Outputs
+	if _, ok := outputs["password"]; ok || string(outputs["user"]) != `"admin"` {
+		t.Fatalf("expected only the output that is not sensitive in the snapshot, got %s", outputs)
+	}
+}
@@ -0,0 +1,145 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry maps names to workflows, e.g. so that handlers, metrics and schedulers of a process can find the
+// workflows they serve. It is safe for concurrent use. Create a Registry with NewRegistry and pass it explicitly
+// to its users.
+type Registry struct {
+	mu        sync.RWMutex
+	workflows map[string]*Workflow
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		workflows: make(map[string]*Workflow),
+	}
+}
+
+// Register registers the workflow with the given name.
+// It returns an error matching ErrDuplicateWorkflow, if a workflow with the name is already registered.
+func (r *Registry) Register(name string, w *Workflow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.workflows[name]; ok {
+		return fmt.Errorf("error registering workflow %q: %w", name, ErrDuplicateWorkflow)
+	}
+	r.workflows[name] = w
+	return nil
+}
+
+// Get returns the workflow registered with the given name
+func (r *Registry) Get(name string) (*Workflow, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, ok := r.workflows[name]
+	return w, ok
+}
+
+// Names returns the sorted names of the registered workflows
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.workflows))
+	for name := range r.workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Deregister removes the workflow with the given name from the registry and returns true, if it was registered
+func (r *Registry) Deregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.workflows[name]
+	delete(r.workflows, name)
+	return ok
+}
+
+// Handler returns an HTTP handler that serves the sorted names of the registered workflows at /workflows and the
+// status of the workflow with the given name at /workflows/{name} as JSON, e.g. for a status endpoint of the process.
+// Unknown names and paths are answered with 404 Not Found. Mount it with both paths, e.g. with
+// mux.Handle("/workflows", h) and mux.Handle("/workflows/", h).
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			rw.Header().Set("Allow", "GET, HEAD")
+			http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.TrimSuffix(req.URL.Path, "/") == "/workflows" {
+			writeJSON(rw, r.Names())
+			return
+		}
+		name, ok := strings.CutPrefix(req.URL.Path, "/workflows/")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			http.NotFound(rw, req)
+			return
+		}
+		w, ok := r.Get(name)
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+		report, err := w.Report()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(rw, newWorkflowStatus(name, report))
+	})
+}
+
+// workflowStatus is the JSON representation of a registered workflow served by the handler of a Registry
+type workflowStatus struct {
+	Name  string       `json:"name"`
+	RunID uint64       `json:"runId"`
+	Tasks []taskStatus `json:"tasks"`
+}
+
+type taskStatus struct {
+	ID          int64        `json:"id"`
+	Description string       `json:"description,omitempty"`
+	Status      string       `json:"status"`
+	Reason      string       `json:"reason,omitempty"`
+	Attempts    int          `json:"attempts,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	LastError   *ErrorRecord `json:"lastError,omitempty"`
+}
+
+// newWorkflowStatus returns the status of the workflow registered with the given name from its report
+func newWorkflowStatus(name string, report Report) workflowStatus {
+	status := workflowStatus{Name: name, RunID: report.RunID, Tasks: make([]taskStatus, 0, len(report.Tasks))}
+	for _, task := range report.Tasks {
+		status.Tasks = append(status.Tasks, taskStatus{
+			ID:          task.ID,
+			Description: task.Description,
+			Status:      task.Status.String(),
+			Reason:      task.Reason,
+			Attempts:    task.Attempts,
+			Error:       errorMessage(task.Err),
+			LastError:   task.LastError,
+		})
+	}
+	return status
+}
+
+// writeJSON writes the given value as JSON response
+func writeJSON(rw http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = rw.Write(data)
+}
@@ -0,0 +1,85 @@
+package flow_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestRegistryHandler(t *testing.T) {
+	r := flow.NewRegistry()
+	w := flow.NewWorkflow()
+	err := w.AddTasks([]*flow.Task{
+		flow.NewTask(1, "create network", func(context.Context, *flow.Task) error { return nil }),
+		flow.NewTask(2, "create machine", func(context.Context, *flow.Task) error { return errors.New("no capacity") }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Reconcile(context.Background())
+	if err := r.Register("machine-2", flow.NewWorkflow()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("machine-1", w); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/workflows")
+	var names []string
+	if err := json.Unmarshal(rec.Body.Bytes(), &names); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected the names, got %d %q: %v", rec.Code, rec.Body, err)
+	}
+	if !reflect.DeepEqual(names, []string{"machine-1", "machine-2"}) {
+		t.Fatalf("unexpected names %v", names)
+	}
+
+	rec = get("/workflows/machine-1")
+	var status struct {
+		Name  string
+		RunID uint64
+		Tasks []struct {
+			ID        int64
+			Status    string
+			Error     string
+			LastError *struct {
+				Error   string
+				Time    time.Time
+				Attempt int
+			}
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected the status, got %d %q: %v", rec.Code, rec.Body, err)
+	}
+	if status.Name != "machine-1" || status.RunID != 1 || len(status.Tasks) != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if task := status.Tasks[1]; task.ID != 2 || task.Status != "failed" || task.Error == "" {
+		t.Fatalf("unexpected status of the failed task %+v", task)
+	}
+	if last := status.Tasks[1].LastError; last == nil || last.Error != "no capacity" || last.Time.IsZero() ||
+		last.Attempt != 1 {
+		t.Fatalf("expected the last error of the failed task, got %+v", last)
+	}
+	if last := status.Tasks[0].LastError; last != nil {
+		t.Fatalf("expected no last error of the succeeded task, got %+v", last)
+	}
+
+	for _, path := range []string{"/workflows/unknown", "/workflows/machine-1/tasks", "/other"} {
+		if rec := get(path); rec.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package flow
+
+import (
+	"fmt"
+	"sort"
+
+	"gonum.org/v1/gonum/graph/simple"
+)
+
+// IDCollisionError indicates that a mapping of task ids is not injective, see Workflow.RemapIDs.
+// It matches ErrIDCollision.
+type IDCollisionError struct {
+	// Collisions are the old ids in ascending order, key is the new id they are mapped to
+	Collisions map[int64][]int64
+}
+
+func (e IDCollisionError) Error() string {
+	ids := make([]int64, 0, len(e.Collisions))
+	for id := range e.Collisions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	msg := ErrIDCollision.Error()
+	for i, id := range ids {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += fmt.Sprintf("%sids %v to id %d", sep, e.Collisions[id], id)
+	}
+	return msg
+}
+
+// Is returns true for ErrIDCollision
+func (e IDCollisionError) Is(target error) bool {
+	return target == ErrIDCollision
+}
+
+// RemapIDs changes the id of every task and placeholder of the workflow to the id returned by the mapper for it,
+// e.g. to number the tasks of merged workflows densely. The dependencies, the recorded state, the stages and the
+// spent retries move with the tasks, so that the workflow and its snapshots use the new ids from then on.
+// The ids of the given tasks change as well. If the mapping is not injective, the workflow is left unmodified and an
+// IDCollisionError is returned. RemapIDs must not be called while the workflow is reconciled.
+func (w *Workflow) RemapIDs(mapper func(old int64) int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	mapping := make(map[int64]int64, w.graph.Nodes().Len())
+	olds := make(map[int64][]int64)
+	nodes := w.graph.Nodes()
+	for nodes.Next() {
+		old := nodes.Node().ID()
+		id := mapper(old)
+		mapping[old] = id
+		olds[id] = append(olds[id], old)
+	}
+	collisions := make(map[int64][]int64)
+	for id, ids := range olds {
+		if len(ids) > 1 {
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			collisions[id] = ids
+		}
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("error remapping task ids: %w", IDCollisionError{Collisions: collisions})
+	}
+
+	g := simple.NewDirectedGraph()
+	for _, id := range mapping {
+		g.AddNode(simple.Node(id))
+	}
+	edges := w.graph.Edges()
+	for edges.Next() {
+		e := edges.Edge()
+		g.SetEdge(g.NewEdge(g.Node(mapping[e.From().ID()]), g.Node(mapping[e.To().ID()])))
+	}
+	w.graph = g
+
+	tasks := make(map[int64]*Task, len(w.tasks))
+	for old, task := range w.tasks {
+		task.id = mapping[old]
+		tasks[task.id] = task
+	}
+	w.tasks = tasks
+	for key, old := range w.keys {
+		w.keys[key] = mapping[old]
+	}
+	w.states = remapKeys(w.states, mapping)
+	w.retriesSpent = remapKeys(w.retriesSpent, mapping)
+	if w.placeholders != nil {
+		w.placeholders = remapKeys(w.placeholders, mapping)
+	}
+	for _, s := range w.stages {
+		s.tasks = remapKeys(s.tasks, mapping)
+	}
+	w.softEdges = remapEdges(w.softEdges, mapping)
+	if w.edgeLabels != nil {
+		w.edgeLabels = remapEdges(w.edgeLabels, mapping)
+	}
+	if w.incremental != nil {
+		// the order stays valid, only the ids change
+		incremental := newIncrementalOrder()
+		for _, old := range w.incremental.seq {
+			incremental.addNode(mapping[old])
+		}
+		w.incremental = incremental
+	}
+	w.order = nil
+	return nil
+}
+
+// remapKeys returns a copy of the given map with the keys changed according to the mapping
+func remapKeys[V any](m map[int64]V, mapping map[int64]int64) map[int64]V {
+	remapped := make(map[int64]V, len(m))
+	for old, v := range m {
+		remapped[mapping[old]] = v
+	}
+	return remapped
+}
+
+// remapEdges returns a copy of the given map with the ids of the edges changed according to the mapping
+func remapEdges[V any](m map[edgeID]V, mapping map[int64]int64) map[edgeID]V {
+	remapped := make(map[edgeID]V, len(m))
+	for old, v := range m {
+		remapped[edgeID{from: mapping[old.from], to: mapping[old.to]}] = v
+	}
+	return remapped
+}
@@ -0,0 +1,109 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+// sparseWorkflow creates a workflow with the tasks 10, 20, 30 and 40, in which 20 and 30 depend on 10 and 40 depends
+// on 20 and softly on 30. Task 30 skips itself.
+func sparseWorkflow(t *testing.T) (*flow.Workflow, []*flow.Task) {
+	t.Helper()
+	nop := func(context.Context, *flow.Task) error { return nil }
+	tasks := []*flow.Task{
+		flow.NewTask(10, "create V1", nop),
+		flow.NewTask(20, "create V2", nop),
+		flow.NewTask(30, "render config", func(context.Context, *flow.Task) error { return flow.SkipTask("unchanged") }),
+		flow.NewTask(40, "deploy", nop),
+	}
+	w := flow.NewWorkflow()
+	if err := w.AddTasks(tasks); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(tasks[1], tasks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(tasks[2], tasks[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddDependency(tasks[3], tasks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddSoftDependency(tasks[3], tasks[2]); err != nil {
+		t.Fatal(err)
+	}
+	return w, tasks
+}
+
+func TestRemapIDsDense(t *testing.T) {
+	w, tasks := sparseWorkflow(t)
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.RemapIDs(func(old int64) int64 { return old / 10 }); err != nil {
+		t.Fatal(err)
+	}
+	for i, task := range tasks {
+		if task.ID() != int64(i+1) {
+			t.Fatalf("expected task %s to have id %d", task, i+1)
+		}
+	}
+	ids, err := w.OrderedTaskIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3, 4}) {
+		t.Fatalf("expected order [1 2 3 4], got %v", ids)
+	}
+	for _, dep := range [][2]int{{1, 0}, {2, 0}, {3, 1}, {3, 2}} {
+		if ok, err := w.DependsOn(tasks[dep[0]], tasks[dep[1]]); err != nil || !ok {
+			t.Fatalf("expected %s to depend on %s, got %v", tasks[dep[0]], tasks[dep[1]], err)
+		}
+	}
+	if w.NumDependencies() != 4 || !w.IsSoftDependency(tasks[3], tasks[2]) || w.IsSoftDependency(tasks[3], tasks[1]) {
+		t.Fatal("expected the dependencies to move with the tasks")
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range report.Tasks {
+		status, reason := flow.Succeeded, ""
+		if task.ID == 3 {
+			status, reason = flow.Skipped, "unchanged"
+		}
+		if task.Status != status || task.Reason != reason || task.Attempts != 1 {
+			t.Fatalf("expected the state to move with task %d, got %+v", task.ID, task)
+		}
+	}
+}
+
+func TestRemapIDsCollision(t *testing.T) {
+	w, tasks := sparseWorkflow(t)
+	hash := w.DefinitionHash()
+	err := w.RemapIDs(func(old int64) int64 {
+		if old <= 30 {
+			return old / 20
+		}
+		return old
+	})
+	var collisionErr flow.IDCollisionError
+	if !errors.Is(err, flow.ErrIDCollision) || !errors.As(err, &collisionErr) {
+		t.Fatalf("expected an IDCollisionError, got %v", err)
+	}
+	if !reflect.DeepEqual(collisionErr.Collisions, map[int64][]int64{1: {20, 30}}) {
+		t.Fatalf("expected ids 20 and 30 to collide at id 1, got %v", collisionErr.Collisions)
+	}
+	if w.DefinitionHash() != hash {
+		t.Fatal("expected the workflow to be unmodified")
+	}
+	for i, task := range tasks {
+		if task.ID() != int64(10*(i+1)) {
+			t.Fatalf("expected task %s to keep its id", task)
+		}
+	}
+}
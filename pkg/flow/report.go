@@ -0,0 +1,187 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Report summarizes the recorded state of the tasks of a workflow
+type Report struct {
+	// Workflow is the name of the workflow
+	Workflow string
+	// RunID is the id of the last reconcile, see Workflow.RunID
+	RunID uint64
+	// Aborted is set, if the last reconcile was aborted by a task instead of failing
+	Aborted *AbortedError
+	// ShuffleSeed is the seed of the last reconcile, if it ordered independent tasks randomly, see ShuffleIndependent
+	ShuffleSeed *int64
+	// Tasks are the reports of the workflow's tasks in execution order
+	Tasks []TaskReport
+}
+
+// TaskReport is the recorded state of a single task
+type TaskReport struct {
+	// ID is the id of the task
+	ID int64
+	// Description is the description of the task
+	Description string
+	// Status is the recorded status of the task
+	Status Status
+	// Reason explains why the task was skipped
+	Reason string
+	// Attempts is the number of invocations of the task's reconcile function
+	Attempts int
+	// Err is the error of the last invocation, if it failed
+	Err error
+	// Durations are the durations of the invocations measured by the timing middleware
+	Durations DurationStats
+	// Circuit is the state of the task's circuit breaker, which is always closed if it has none
+	Circuit CircuitState
+	// Outputs are the output values recorded by the task, if the report includes them, see IncludeOutputs
+	Outputs map[string]any
+	// LastError is the most recent failure of the task, unless it succeeded since, see Workflow.LastError
+	LastError *ErrorRecord
+	// ErrorHistory are the most recent errors of the task, if the report includes them, see IncludeErrorHistory
+	ErrorHistory []ErrorRecord
+	// SLABreached is set, if the last execution of the task exceeded its service level, see SLA
+	SLABreached bool
+	// Synthetic is set for tasks that were created by a helper instead of the user, e.g. the join task of Group
+	Synthetic bool
+}
+
+// ReportOption configures the content of a Report
+type ReportOption func(c *reportConfig)
+
+type reportConfig struct {
+	outputs      bool
+	errorHistory bool
+}
+
+// IncludeOutputs includes the output values recorded by the tasks in the report, except for Sensitive values
+func IncludeOutputs() ReportOption {
+	return func(c *reportConfig) {
+		c.outputs = true
+	}
+}
+
+// IncludeErrorHistory includes the most recent errors of the tasks in the report, see Workflow.ErrorHistory
+func IncludeErrorHistory() ReportOption {
+	return func(c *reportConfig) {
+		c.errorHistory = true
+	}
+}
+
+// Report returns a report of the recorded state of all tasks in execution order
+func (w *Workflow) Report(opts ...ReportOption) (Report, error) {
+	var config reportConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	tasks, err := w.orderedTasks()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		Workflow:    w.name,
+		RunID:       w.runID,
+		Aborted:     w.aborted,
+		ShuffleSeed: w.shuffleSeed,
+		Tasks:       make([]TaskReport, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		state := w.states[t.id]
+		var outputs map[string]any
+		if config.outputs && len(state.outputs) > 0 {
+			outputs = make(map[string]any, len(state.outputs))
+			for k, v := range state.outputs {
+				if restored, ok := v.(restoredValue); ok {
+					v = json.RawMessage(restored)
+				}
+				if state.sensitive[k] {
+					v = RedactedOutput
+				}
+				outputs[k] = v
+			}
+		}
+		var history []ErrorRecord
+		if config.errorHistory {
+			history = append(history, state.history...)
+		}
+		report.Tasks = append(report.Tasks, TaskReport{
+			ID:           t.id,
+			Description:  t.desc,
+			Status:       state.status,
+			Reason:       state.reason,
+			Attempts:     state.attempts,
+			Err:          state.err,
+			Durations:    state.durations,
+			Circuit:      state.breaker.state,
+			Outputs:      outputs,
+			LastError:    state.lastErr,
+			ErrorHistory: history,
+			SLABreached:  state.slaBreached,
+			Synthetic:    t.synthetic,
+		})
+	}
+	return report, nil
+}
+
+// Blocker is a dependency of a task that did not succeed yet, see BlockedBy
+type Blocker struct {
+	// Task is the dependency
+	Task *Task
+	// Status is the recorded status of the dependency
+	Status Status
+}
+
+// Failed returns true, if the dependency failed, i.e. the task is blocked until the failure is remediated,
+// instead of pending
+func (b Blocker) Failed() bool {
+	return b.Status == Failed || b.Status == WaitingForApproval
+}
+
+// BlockedBy returns the dependencies of the given task that are neither Succeeded nor Skipped according to the
+// recorded state, in execution order. If transitive is true, indirect dependencies are included.
+func (w *Workflow) BlockedBy(task *Task, transitive bool) ([]Blocker, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if _, ok := w.tasks[task.id]; !ok {
+		return nil, fmt.Errorf("error getting blockers of task id %d: %w", task.id, ErrTaskNotFound)
+	}
+
+	deps := make(map[int64]bool)
+	stack := []int64{task.id}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		nodes := w.graph.To(id)
+		for nodes.Next() {
+			depID := nodes.Node().ID()
+			if !deps[depID] {
+				deps[depID] = true
+				if transitive {
+					stack = append(stack, depID)
+				}
+			}
+		}
+	}
+
+	tasks, err := w.orderedTasks()
+	if err != nil {
+		return nil, err
+	}
+	var blockers []Blocker
+	for _, t := range tasks {
+		if !deps[t.id] {
+			continue
+		}
+		if status := w.states[t.id].status; status != Succeeded && status != Skipped {
+			blockers = append(blockers, Blocker{Task: t, Status: status})
+		}
+	}
+	return blockers, nil
+}
@@ -0,0 +1,175 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestBlockedBy(t *testing.T) {
+	// 1 (gate) -> 2 -> 3 <- 4
+	w := flow.NewWorkflow(flow.WithContinueOnError())
+	gate := flow.NewGateTask(1, "approve")
+	task2, task3, task4 := flow.NewTask(2, "2", nop), flow.NewTask(3, "3", nop), flow.NewTask(4, "4", nop)
+	if err := w.AddTasks([]*flow.Task{gate.Task, task2, task3, task4}); err != nil {
+		t.Fatal(err)
+	}
+	for _, dep := range [][2]*flow.Task{{task2, gate.Task}, {task3, task2}, {task3, task4}} {
+		if err := w.AddDependency(dep[0], dep[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Reconcile(context.Background()); !errors.Is(err, flow.ErrWaitingForApproval) {
+		t.Fatalf("expected error matching ErrWaitingForApproval, got %v", err)
+	}
+
+	type blocker struct {
+		id     int64
+		status flow.Status
+		failed bool
+	}
+	tests := []struct {
+		name       string
+		transitive bool
+		want       []blocker
+	}{
+		{name: "direct", want: []blocker{{id: 2, status: flow.Pending}}},
+		{name: "transitive", transitive: true, want: []blocker{
+			{id: 1, status: flow.WaitingForApproval, failed: true},
+			{id: 2, status: flow.Pending},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blockers, err := w.BlockedBy(task3, tt.transitive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []blocker
+			for _, b := range blockers {
+				got = append(got, blocker{id: b.Task.ID(), status: b.Status, failed: b.Failed()})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected blockers %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBlockedByUnknownTask(t *testing.T) {
+	w := flow.NewWorkflow()
+	if _, err := w.BlockedBy(flow.NewTask(1, "unknown", nop), true); !errors.Is(err, flow.ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestLastError(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := flowtest.NewFakeClock(start)
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	var results []error
+	if err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		err := results[0]
+		results = results[1:]
+		return err
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := w.LastError(1); ok {
+		t.Fatal("expected no last error before the first reconcile")
+	}
+
+	errQuota, errCapacity := errors.New("quota exceeded"), errors.New("no capacity")
+	results = []error{errQuota, errCapacity, nil}
+	_ = w.Reconcile(context.Background())
+	clock.Advance(time.Minute)
+	_ = w.Reconcile(context.Background())
+	err, at, ok := w.LastError(1)
+	if !ok || !errors.Is(err, errCapacity) || !at.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected the last error at %s, got %v at %s", start.Add(time.Minute), err, at)
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := report.Tasks[0].LastError; last == nil || last.Attempt != 2 || !errors.Is(last.Err, errCapacity) {
+		t.Fatalf("expected the last error of attempt 2 in the report, got %+v", last)
+	}
+
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err, _, ok := w.LastError(1); ok {
+		t.Fatalf("expected the last error to be cleared by the success, got %v", err)
+	}
+	if _, _, ok := w.LastError(2); ok {
+		t.Fatal("expected no last error of an unknown task")
+	}
+}
+
+func TestErrorHistory(t *testing.T) {
+	w := flow.NewWorkflow(flow.WithErrorHistory(2))
+	var results []error
+	if err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		err := results[0]
+		results = results[1:]
+		return err
+	})); err != nil {
+		t.Fatal(err)
+	}
+	results = []error{errors.New("quota exceeded"), errors.New("no capacity"), errors.New("quota exceeded"), nil}
+	for range results {
+		_ = w.Reconcile(context.Background())
+	}
+
+	history, err := w.ErrorHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, record := range history {
+		got = append(got, fmt.Sprintf("%d: %v", record.Attempt, record.Err))
+	}
+	// the history is bounded to the most recent errors and kept after the success
+	if want := []string{"2: no capacity", "3: quota exceeded"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the history %q, got %q", want, got)
+	}
+
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Tasks[0].ErrorHistory) != 0 {
+		t.Fatalf("expected no history in the report by default, got %v", report.Tasks[0].ErrorHistory)
+	}
+	report, err = w.Report(flow.IncludeErrorHistory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Tasks[0].ErrorHistory, history) {
+		t.Fatalf("expected the history %v in the report, got %v", history, report.Tasks[0].ErrorHistory)
+	}
+
+	if _, err := w.ErrorHistory(2); !errors.Is(err, flow.ErrTaskNotFound) {
+		t.Fatalf("expected error matching ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestErrorHistoryDisabled(t *testing.T) {
+	w := flow.NewWorkflow(flow.WithErrorHistory(0))
+	if err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		return errors.New("quota exceeded")
+	})); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Reconcile(context.Background())
+	if history, err := w.ErrorHistory(1); err != nil || len(history) != 0 {
+		t.Fatalf("expected no history, got %v, %v", history, err)
+	}
+}
@@ -0,0 +1,258 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackoffStrategy returns the delay before the given retry attempt, starting at 1 for the first retry
+type BackoffStrategy interface {
+	Backoff(attempt int) time.Duration
+}
+
+// ConstantBackoff waits the same delay before each retry
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+// Backoff returns the constant delay
+func (b ConstantBackoff) Backoff(_ int) time.Duration {
+	return b.Delay
+}
+
+// ExponentialBackoff doubles the delay before each retry, starting at Initial and limited by Max, if set
+type ExponentialBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Backoff returns Initial * 2^(attempt-1), limited by Max
+func (b ExponentialBackoff) Backoff(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt; i++ {
+		if d > math.MaxInt64/2 {
+			d = math.MaxInt64
+			break
+		}
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		return b.Max
+	}
+	return d
+}
+
+// FullJitterBackoff waits a random delay between zero and the delay of the exponential backoff
+type FullJitterBackoff struct {
+	ExponentialBackoff
+	// Source is the source of the random delays, e.g. rand.NewSource(seed) to make them reproducible.
+	// It defaults to the global source, if nil, and must be safe for concurrent use, if the backoff is shared.
+	Source rand.Source
+}
+
+// Backoff returns a random duration in [0, ExponentialBackoff.Backoff(attempt)]
+func (b FullJitterBackoff) Backoff(attempt int) time.Duration {
+	d := b.ExponentialBackoff.Backoff(attempt)
+	if d <= 0 {
+		return 0
+	}
+	if b.Source == nil {
+		return time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	return time.Duration(rand.New(b.Source).Int63n(int64(d) + 1))
+}
+
+// lockedSource makes a rand.Source safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// RetryPolicy decides whether and after which delay a failed task is retried.
+// The attempt is the number of the failed attempt, starting at 1, and the task is nil, if the failure cannot
+// be attributed to a single task. If NextDelay returns false, the retries are given up.
+type RetryPolicy interface {
+	NextDelay(task *Task, attempt int, err error) (time.Duration, bool)
+}
+
+// backoffPolicy retries up to a maximum number of attempts with the delays of a backoff strategy
+type backoffPolicy struct {
+	backoff  BackoffStrategy
+	attempts int
+}
+
+func (p backoffPolicy) NextDelay(_ *Task, attempt int, _ error) (time.Duration, bool) {
+	if attempt >= p.attempts {
+		return 0, false
+	}
+	return p.backoff.Backoff(attempt), true
+}
+
+// BackoffPolicy returns a RetryPolicy that allows up to the given number of attempts with the delays of the
+// backoff strategy
+func BackoffPolicy(backoff BackoffStrategy, attempts int) RetryPolicy {
+	return backoffPolicy{backoff: backoff, attempts: attempts}
+}
+
+// ConstantPolicy returns a RetryPolicy that allows up to the given number of attempts with a constant delay
+func ConstantPolicy(delay time.Duration, attempts int) RetryPolicy {
+	return BackoffPolicy(ConstantBackoff{Delay: delay}, attempts)
+}
+
+// ExponentialPolicy returns a RetryPolicy that allows up to the given number of attempts with an exponential
+// backoff, see ExponentialBackoff
+func ExponentialPolicy(initial, maxDelay time.Duration, attempts int) RetryPolicy {
+	return BackoffPolicy(ExponentialBackoff{Initial: initial, Max: maxDelay}, attempts)
+}
+
+// JitteredPolicy returns a RetryPolicy that allows up to the given number of attempts with an exponential
+// backoff with full jitter, see FullJitterBackoff. The jitter is drawn from a source seeded with the given seed,
+// which should differ between the workflows sharing a dependency, e.g. a hash of their names.
+func JitteredPolicy(initial, maxDelay time.Duration, attempts int, seed int64) RetryPolicy {
+	return BackoffPolicy(FullJitterBackoff{
+		ExponentialBackoff: ExponentialBackoff{Initial: initial, Max: maxDelay},
+		Source:             &lockedSource{src: rand.NewSource(seed)},
+	}, attempts)
+}
+
+// maxElapsedPolicy gives up retries of a task, once the given duration elapsed since its first failed attempt
+type maxElapsedPolicy struct {
+	policy RetryPolicy
+	limit  time.Duration
+	clock  Clock
+
+	mu    sync.Mutex
+	start map[int64]time.Time
+}
+
+// MaxElapsed decorates the given policy to give up, once the given duration elapsed since the first failed attempt
+// of a task or would elapse during the next delay. The time is measured with the given clock, which defaults to the
+// real clock if nil.
+func MaxElapsed(policy RetryPolicy, limit time.Duration, clock Clock) RetryPolicy {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &maxElapsedPolicy{
+		policy: policy,
+		limit:  limit,
+		clock:  clock,
+		start:  make(map[int64]time.Time),
+	}
+}
+
+func (p *maxElapsedPolicy) NextDelay(task *Task, attempt int, err error) (time.Duration, bool) {
+	// failures that cannot be attributed to a task share the id -1, which is never a valid node id
+	id := int64(-1)
+	if task != nil {
+		id = task.id
+	}
+	now := p.clock.Now()
+	p.mu.Lock()
+	start, ok := p.start[id]
+	if !ok || attempt == 1 {
+		start = now
+		p.start[id] = start
+	}
+	p.mu.Unlock()
+
+	delay, retry := p.policy.NextDelay(task, attempt, err)
+	if !retry || now.Add(delay).Sub(start) > p.limit {
+		return 0, false
+	}
+	return delay, true
+}
+
+// Retry returns a middleware that invokes the reconcile function up to the given number of attempts within a
+// single reconcile, waiting on the workflow's clock according to the backoff strategy between them.
+// A FatalError stops the retries immediately. The final error mentions the number of attempts. If the context is
+// done while waiting, the returned error wraps the error of the context and mentions the last error of the task.
+func Retry(attempts int, backoff BackoffStrategy) Middleware {
+	return retry(BackoffPolicy(backoff, attempts), func(err error) error { return err })
+}
+
+// RetryWithPolicy returns a middleware that retries the reconcile function within a single reconcile according
+// to the given policy, waiting on the workflow's clock between the attempts.
+// A FatalError stops the retries immediately. If the policy gives up, the last error is escalated to a FatalError.
+func RetryWithPolicy(policy RetryPolicy) Middleware {
+	return retry(policy, func(err error) error { return NewFatalError(err) })
+}
+
+// retry returns a retry middleware, that passes the final error through giveUp, if the policy gives up
+func retry(policy RetryPolicy, giveUp func(err error) error) Middleware {
+	return func(next Fn) Fn {
+		return func(ctx context.Context, task *Task) error {
+			for attempt := 1; ; attempt++ {
+				err := next(ctx, task)
+				if err == nil || IsFatal(err) {
+					return err
+				}
+				delay, ok := policy.NextDelay(task, attempt, err)
+				if !ok {
+					return giveUp(fmt.Errorf("giving up after %d attempts: %w", attempt, err))
+				}
+				if sleepErr := sleep(ctx, clockFrom(ctx), delay); sleepErr != nil {
+					// the cancellation decides about the outcome, the task error is context only
+					return fmt.Errorf("retry canceled after %d attempts (last error: %v): %w", attempt, err, sleepErr)
+				}
+			}
+		}
+	}
+}
+
+// RunUntilDone reconciles the workflow until it succeeds, fails with a FatalError or the context is done.
+// Between the reconciles, it waits on the workflow's clock according to the given policy, which receives the
+// failed task, if the error can be attributed to one. If the policy gives up, the last error is escalated to a
+// FatalError.
+// If the workflow has a logger, the changes since the previous attempt are logged between the attempts, see
+// DiffReports.
+func (w *Workflow) RunUntilDone(ctx context.Context, policy RetryPolicy) error {
+	var prev *Report
+	for attempt := 1; ; attempt++ {
+		err := w.Reconcile(ctx)
+		if err == nil || IsFatal(err) {
+			return err
+		}
+		if w.logger != nil {
+			if report, reportErr := w.Report(); reportErr == nil {
+				if prev != nil {
+					w.logger.InfoContext(ctx, "reconcile attempt failed", slog.Int("attempt", attempt),
+						slog.String("changes", DiffReports(*prev, report).String()))
+				}
+				prev = &report
+			}
+		}
+		var task *Task
+		var taskErr TaskError
+		if errors.As(err, &taskErr) {
+			w.mu.RLock()
+			task = w.tasks[taskErr.TaskID]
+			w.mu.RUnlock()
+		}
+		delay, ok := policy.NextDelay(task, attempt, err)
+		if !ok {
+			return NewFatalError(fmt.Errorf("giving up after %d reconciles: %w", attempt, err))
+		}
+		if sleepErr := sleep(ctx, w.clock, delay); sleepErr != nil {
+			return fmt.Errorf("run canceled after %d reconciles (last error: %v): %w", attempt, err,
+				canceledError(sleepErr))
+		}
+	}
+}
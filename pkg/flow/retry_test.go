@@ -0,0 +1,239 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestRetryCanceledDuringBackoff(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	unavailable := errors.New("api unavailable")
+	attempts := 0
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		attempts++
+		return unavailable
+	}, flow.WithMiddleware(flow.Retry(3, flow.ConstantBackoff{Delay: time.Minute}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(ctx) }()
+	clock.BlockUntil(1)
+	cancel()
+	err = <-errc
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, flow.ErrCanceled) {
+		t.Fatalf("expected error matching context.Canceled and ErrCanceled, got %v", err)
+	}
+	var taskErr flow.TaskError
+	if !errors.As(err, &taskErr) || !taskErr.Canceled || flow.IsFatal(err) {
+		t.Fatalf("expected a retryable TaskError of a canceled reconcile, got %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected no further attempt after the cancellation, got %d attempts", attempts)
+	}
+	if errors.Is(err, unavailable) || !strings.Contains(err.Error(), unavailable.Error()) {
+		t.Fatalf("expected the task error to be mentioned, but not wrapped, got %v", err)
+	}
+}
+
+func TestReconcileCanceledBeforeTask(t *testing.T) {
+	w := flow.NewWorkflow()
+	ran := false
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		ran = true
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = w.Reconcile(ctx)
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, flow.ErrCanceled) {
+		t.Fatalf("expected error matching context.Canceled and ErrCanceled, got %v", err)
+	}
+	if ran {
+		t.Fatal("expected the task not to start after the cancellation")
+	}
+}
+
+func TestTaskCanceledWithinTask(t *testing.T) {
+	w := flow.NewWorkflow()
+	err := w.AddTask(flow.NewTask(1, "create V1", func(ctx context.Context, _ *flow.Task) error {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		return ctx.Err()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Reconcile(context.Background())
+	if !errors.Is(err, context.Canceled) || errors.Is(err, flow.ErrCanceled) {
+		t.Fatalf("expected error matching context.Canceled, but not ErrCanceled, got %v", err)
+	}
+	var taskErr flow.TaskError
+	if !errors.As(err, &taskErr) || taskErr.Canceled {
+		t.Fatalf("expected a TaskError of a task that was not canceled by the reconcile, got %v", err)
+	}
+}
+
+func TestRetryStopsAtFatalError(t *testing.T) {
+	w := flow.NewWorkflow()
+	attempts := 0
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		attempts++
+		return flow.NewFatalError(errors.New("invalid spec"))
+	}, flow.WithMiddleware(flow.Retry(3, flow.ConstantBackoff{}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); !flow.IsFatal(err) || attempts != 1 {
+		t.Fatalf("expected one attempt and a fatal error, got %d attempts and %v", attempts, err)
+	}
+}
+
+func TestRunUntilDoneCanceled(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	unavailable := errors.New("api unavailable")
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error { return unavailable }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- w.RunUntilDone(ctx, flow.ConstantPolicy(time.Minute, 3)) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-errc; !errors.Is(err, flow.ErrCanceled) || errors.Is(err, unavailable) {
+		t.Fatalf("expected error matching ErrCanceled, got %v", err)
+	}
+}
+
+func TestRetryBackoffSequence(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := flowtest.NewFakeClock(start)
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	var attempts []time.Duration
+	backoff := flow.ExponentialBackoff{Initial: time.Second, Max: 3 * time.Second}
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		attempts = append(attempts, clock.Now().Sub(start))
+		return errors.New("api unavailable")
+	}, flow.WithMiddleware(flow.Retry(4, backoff))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(context.Background()) }()
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second} {
+		clock.BlockUntil(1)
+		clock.Advance(delay)
+	}
+	if err := <-errc; err == nil || !strings.Contains(err.Error(), "giving up after 4 attempts") {
+		t.Fatalf("expected the retries to give up after 4 attempts, got %v", err)
+	}
+	want := []time.Duration{0, time.Second, 3 * time.Second, 6 * time.Second}
+	if !reflect.DeepEqual(attempts, want) {
+		t.Fatalf("expected attempts at %v, got %v", want, attempts)
+	}
+}
+
+func TestRetryWithPolicyMaxElapsed(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	attempts := 0
+	policy := flow.MaxElapsed(flow.ConstantPolicy(time.Minute, 10), 150*time.Second, clock)
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		attempts++
+		return errors.New("api unavailable")
+	}, flow.WithMiddleware(flow.RetryWithPolicy(policy))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(context.Background()) }()
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+	}
+	// the third delay would end after 3m, which exceeds the limit of 2.5m
+	if err := <-errc; !flow.IsFatal(err) || !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Fatalf("expected a fatal error giving up after 3 attempts, got %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestFullJitterBackoffIsDeterministicBySeed(t *testing.T) {
+	exponential := flow.ExponentialBackoff{Initial: time.Second, Max: time.Minute}
+	delays := func(backoff flow.BackoffStrategy) []time.Duration {
+		var delays []time.Duration
+		for attempt := 1; attempt <= 8; attempt++ {
+			delay := backoff.Backoff(attempt)
+			if delay < 0 || delay > exponential.Backoff(attempt) {
+				t.Fatalf("attempt %d: delay %s is not within [0, %s]", attempt, delay, exponential.Backoff(attempt))
+			}
+			delays = append(delays, delay)
+		}
+		return delays
+	}
+	first := delays(flow.FullJitterBackoff{ExponentialBackoff: exponential, Source: rand.NewSource(7)})
+	second := delays(flow.FullJitterBackoff{ExponentialBackoff: exponential, Source: rand.NewSource(7)})
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected the same delays for the same seed, got %v and %v", first, second)
+	}
+
+	policies := []flow.RetryPolicy{
+		flow.JitteredPolicy(time.Second, time.Minute, 8, 7),
+		flow.JitteredPolicy(time.Second, time.Minute, 8, 7),
+	}
+	for attempt := 1; attempt < 8; attempt++ {
+		a, _ := policies[0].NextDelay(nil, attempt, nil)
+		b, _ := policies[1].NextDelay(nil, attempt, nil)
+		if a != b {
+			t.Fatalf("attempt %d: expected the same delay for the same seed, got %s and %s", attempt, a, b)
+		}
+	}
+}
+
+func TestFatalOnTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []flow.TaskOption
+		fatal bool
+	}{
+		{name: "retryable by default", opts: []flow.TaskOption{flow.TaskTimeout(time.Millisecond)}},
+		{name: "fatal", opts: []flow.TaskOption{flow.TaskTimeout(time.Millisecond), flow.FatalOnTimeout()}, fatal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := flow.NewWorkflow()
+			if err := w.AddTask(flow.NewTask(1, "flash firmware", func(ctx context.Context, _ *flow.Task) error {
+				<-ctx.Done()
+				return fmt.Errorf("flashing interrupted: %w", ctx.Err())
+			}, tt.opts...)); err != nil {
+				t.Fatal(err)
+			}
+			err := w.Reconcile(context.Background())
+			var taskErr flow.TaskError
+			if !errors.As(err, &taskErr) || !taskErr.Timeout || !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected a TaskError of a timeout, got %v", err)
+			}
+			if flow.IsFatal(err) != tt.fatal {
+				t.Fatalf("expected the timeout to be fatal: %t, got %v", tt.fatal, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package flow
+
+import (
+	"fmt"
+	"time"
+)
+
+// RunOption configures a single Reconcile
+type RunOption func(c *runConfig)
+
+type runConfig struct {
+	// maximum number of tasks to execute, that did not succeed before, unlimited if not positive
+	maxTasks int
+	// soft limit of the duration of the reconcile, unlimited if not positive
+	timeBudget time.Duration
+	// values attached to the context of the reconcile
+	values map[any]any
+	// closed to stop the reconcile before the next task, see RunWithGracefulShutdown
+	stop <-chan struct{}
+	// order independent tasks randomly from the seed, see ShuffleIndependent
+	shuffle bool
+	seed    int64
+}
+
+// MaxTasksPerRun limits the number of tasks that Reconcile executes, which did not succeed or were skipped in a
+// previous reconcile, e.g. to limit the amount of change in a maintenance window. Tasks that already succeeded are
+// executed as usual and do not count. When the limit is reached, Reconcile stops before the next task that would
+// count and returns a RunBudgetError, so that the next Reconcile continues where this one stopped.
+func MaxTasksPerRun(n int) RunOption {
+	return func(c *runConfig) {
+		c.maxTasks = n
+	}
+}
+
+// TimeBudget limits the duration of Reconcile softly: before starting each task, Reconcile checks the time elapsed
+// since its start on the workflow's clock and stops cleanly with a RunBudgetError, once the budget is exceeded.
+// Unlike a deadline of ctx, the budget never interrupts a running task.
+func TimeBudget(d time.Duration) RunOption {
+	return func(c *runConfig) {
+		c.timeBudget = d
+	}
+}
+
+// WithValues attaches the given values to the context of the reconcile, e.g. a correlation id, so that the
+// reconcile functions of all tasks, their middleware and the hooks of the workflow can read them with
+// ctx.Value. The values apply to this reconcile only, unlike the values of a task, see WithValue.
+func WithValues(values map[any]any) RunOption {
+	return func(c *runConfig) {
+		if c.values == nil {
+			c.values = make(map[any]any, len(values))
+		}
+		for k, v := range values {
+			c.values[k] = v
+		}
+	}
+}
+
+// RunBudgetError indicates that Reconcile stopped cleanly, because the budget of the run was exhausted.
+// It matches ErrRunBudgetExhausted and is retryable.
+type RunBudgetError struct {
+	// Completed is the number of tasks that were executed in the run
+	Completed int
+	// Remaining are the ids of the tasks that were not executed in execution order
+	Remaining []int64
+}
+
+func (e RunBudgetError) Error() string {
+	return fmt.Sprintf("%v after %d tasks, remaining task ids %v", ErrRunBudgetExhausted, e.Completed, e.Remaining)
+}
+
+// Is returns true for ErrRunBudgetExhausted
+func (e RunBudgetError) Is(target error) bool {
+	return target == ErrRunBudgetExhausted
+}
+
+// counts returns true, if the execution of the given task counts against the task limit of the run
+func (w *Workflow) counts(task *Task) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	status := w.states[task.id].status
+	return status != Succeeded && status != Skipped
+}
+
+// budgetExhausted returns true, if the given task must not be executed, because the run exhausted its budget
+func (w *Workflow) budgetExhausted(task *Task, p *pass) bool {
+	if p.config.timeBudget > 0 && w.clock.Now().Sub(p.started) > p.config.timeBudget {
+		return true
+	}
+	return p.config.maxTasks > 0 && p.counted >= p.config.maxTasks && w.counts(task)
+}
+
+// runBudgetError returns the error for a run, that exhausted its budget before the given remaining tasks.
+// The remaining tasks that always run are executed anyway and thus not included.
+func (w *Workflow) runBudgetError(remaining []*Task, p *pass) error {
+	var ids []int64
+	for _, t := range remaining {
+		if !t.alwaysRun {
+			ids = append(ids, t.id)
+		}
+	}
+	return RunBudgetError{Completed: p.completed, Remaining: ids}
+}
@@ -0,0 +1,85 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestTimeBudget(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	tasks := []*flow.Task{
+		flow.NewTask(1, "create V1", nop),
+		flow.NewTask(2, "migrate data", func(context.Context, *flow.Task) error {
+			clock.Advance(2 * time.Minute)
+			return nil
+		}),
+		flow.NewTask(3, "create V3", nop),
+		flow.NewTask(4, "create V4", nop),
+		flow.NewTask(5, "notify", nop, flow.AlwaysRun()),
+	}
+	if _, _, err := flow.Chain(w, tasks...); err != nil {
+		t.Fatal(err)
+	}
+	err := w.Reconcile(context.Background(), flow.TimeBudget(time.Minute))
+	var budgetErr flow.RunBudgetError
+	if !errors.Is(err, flow.ErrRunBudgetExhausted) || !errors.As(err, &budgetErr) || flow.IsFatal(err) {
+		t.Fatalf("expected a retryable RunBudgetError, got %v", err)
+	}
+	// the task that always runs is executed anyway
+	if budgetErr.Completed != 2 || !reflect.DeepEqual(budgetErr.Remaining, []int64{3, 4}) {
+		t.Fatalf("expected the budget to stop the run after 2 tasks before 3 and 4, got %+v", budgetErr)
+	}
+	flowtest.AssertStatuses(t, w, map[int64]flow.Status{1: flow.Succeeded, 2: flow.Succeeded, 3: flow.Pending,
+		4: flow.Pending, 5: flow.Succeeded})
+}
+
+func TestWithValues(t *testing.T) {
+	type key struct{}
+	runs := map[string]func(w *flow.Workflow, opts ...flow.RunOption) error{
+		"Reconcile": func(w *flow.Workflow, opts ...flow.RunOption) error {
+			return w.Reconcile(context.Background(), opts...)
+		},
+		"RunWithGroup": func(w *flow.Workflow, opts ...flow.RunOption) error {
+			return runWithGroup(t, newLimitGroup(2), w, opts...)
+		},
+	}
+	for name, run := range runs {
+		t.Run(name, func(t *testing.T) {
+			values := make(chan any, 8)
+			w := diamond(t, map[int64]flow.Fn{
+				4: func(ctx context.Context, _ *flow.Task) error {
+					values <- ctx.Value(key{})
+					return nil
+				},
+			})
+			w.SetBeforeTask(func(ctx context.Context, task *flow.Task) error {
+				if task.ID() == 1 {
+					values <- ctx.Value(key{})
+				}
+				return nil
+			})
+			if err := run(w, flow.WithValues(map[any]any{key{}: "correlation-1"})); err != nil {
+				t.Fatal(err)
+			}
+			if err := run(w); err != nil {
+				t.Fatal(err)
+			}
+			close(values)
+			var got []any
+			for v := range values {
+				got = append(got, v)
+			}
+			// the values apply to a single reconcile only
+			if want := []any{"correlation-1", "correlation-1", nil, nil}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected the values %v in the hook and the task, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DefaultScheduleInterval is the default interval between the reconciles of a Scheduler
+const DefaultScheduleInterval = time.Minute
+
+// ErrSchedulerRunning indicates that a scheduler is already running
+var ErrSchedulerRunning = errors.New("scheduler is already running")
+
+// Scheduler reconciles a workflow periodically, e.g. to keep the reconciled object in its desired state.
+// A reconcile never starts while the previous one is still executing.
+type Scheduler struct {
+	w        *Workflow
+	interval time.Duration
+	jitter   time.Duration
+	debounce time.Duration
+	cooldown time.Duration
+	// source of the jitter, only used by Run
+	rng *rand.Rand
+	// pending trigger, buffered to coalesce triggers
+	trigger chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	report  Report
+	err     error
+	runs    int
+	next    time.Time
+}
+
+// ScheduleOption configures a Scheduler
+type ScheduleOption func(s *Scheduler)
+
+// Every sets the interval between the start of a reconcile and the start of the next one. If a reconcile takes
+// longer than the interval, the next one starts right after it. Defaults to DefaultScheduleInterval.
+func Every(interval time.Duration) ScheduleOption {
+	return func(s *Scheduler) {
+		s.interval = interval
+	}
+}
+
+// Jitter adds a random delay between zero and the given duration to each interval, so that many schedulers
+// started at the same time do not reconcile simultaneously.
+func Jitter(jitter time.Duration) ScheduleOption {
+	return func(s *Scheduler) {
+		s.jitter = jitter
+	}
+}
+
+// JitterSeed draws the jitter from a source seeded with the given seed, e.g. to test the scheduler
+// deterministically. By default, the source is seeded with the current time.
+func JitterSeed(seed int64) ScheduleOption {
+	return func(s *Scheduler) {
+		s.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithDebounce delays a triggered reconcile until no further trigger arrived for the given quiet period, so that
+// a burst of triggers results in a single reconcile after the burst. See Scheduler.Trigger.
+func WithDebounce(d time.Duration) ScheduleOption {
+	return func(s *Scheduler) {
+		s.debounce = d
+	}
+}
+
+// WithCooldown enforces the given minimum duration between the start of a reconcile and the start of the next one,
+// regardless of whether it is triggered or periodic, e.g. to protect rate-limited downstream APIs.
+func WithCooldown(d time.Duration) ScheduleOption {
+	return func(s *Scheduler) {
+		s.cooldown = d
+	}
+}
+
+// NewScheduler creates a scheduler for the given workflow configured by the given options
+func NewScheduler(w *Workflow, opts ...ScheduleOption) *Scheduler {
+	s := &Scheduler{
+		w:        w,
+		interval: DefaultScheduleInterval,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		trigger:  make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run reconciles the workflow immediately and then periodically, waiting on the workflow's clock.
+// It blocks until ctx is done or a reconcile fails with a FatalError and returns that error.
+// Start it in a goroutine to reconcile in the background.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrSchedulerRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.next = time.Time{}
+		s.mu.Unlock()
+	}()
+
+	for {
+		start := s.w.clock.Now()
+		err := s.w.Reconcile(ctx)
+		s.record(err)
+		if IsFatal(err) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.wait(ctx, start); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits until the next reconcile is due, if the last one started at the given time
+func (s *Scheduler) wait(ctx context.Context, start time.Time) error {
+	earliest := start.Add(s.cooldown)
+	periodic := s.w.clock.Now().Add(s.nextDelay(start))
+	if periodic.Before(earliest) {
+		periodic = earliest
+	}
+	// the next run is published before the timer is armed, so that it can be read once the clock has a waiter
+	next := periodic
+	s.setNext(next)
+	timer := s.w.clock.NewTimer(next.Sub(s.w.clock.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C():
+			s.setNext(time.Time{})
+			return nil
+		case <-s.trigger:
+			// every trigger restarts the quiet period, but never delays the periodic reconcile
+			triggered := s.w.clock.Now().Add(s.debounce)
+			if triggered.Before(earliest) {
+				triggered = earliest
+			}
+			next = periodic
+			if triggered.Before(next) {
+				next = triggered
+			}
+			if !timer.Stop() {
+				// the timer fired, but the trigger was selected
+				select {
+				case <-timer.C():
+				default:
+				}
+			}
+			s.setNext(next)
+			timer.Reset(next.Sub(s.w.clock.Now()))
+		}
+	}
+}
+
+// setNext records the time of the next reconcile, which is zero while reconciling
+func (s *Scheduler) setNext(next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = next
+}
+
+// Trigger requests an immediate reconcile, e.g. when an external event indicates that the reconciled object
+// changed. Triggers that arrive while a reconcile is executing are coalesced into a single follow-up reconcile.
+// Trigger does not block.
+func (s *Scheduler) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// nextDelay returns the delay until the next reconcile, if the last one started at the given time
+func (s *Scheduler) nextDelay(start time.Time) time.Duration {
+	delay := s.interval - s.w.clock.Now().Sub(start)
+	if s.jitter > 0 {
+		delay += time.Duration(s.rng.Int63n(int64(s.jitter)))
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// record records the report and error of a finished reconcile
+func (s *Scheduler) record(err error) {
+	report, reportErr := s.w.Report()
+	if reportErr != nil {
+		report = Report{Workflow: s.w.Name(), RunID: s.w.RunID()}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = report
+	s.err = err
+	s.runs++
+}
+
+// LastReport returns the report and the error of the last reconcile of the scheduler
+func (s *Scheduler) LastReport() (Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.report, s.err
+}
+
+// NextRun returns the earliest time at which the next reconcile of the scheduler starts, taking triggers,
+// debounce and cooldown into account. It returns the zero time while the scheduler reconciles or is not running.
+func (s *Scheduler) NextRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// Runs returns the number of reconciles of the scheduler
+func (s *Scheduler) Runs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs
+}
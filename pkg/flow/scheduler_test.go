@@ -0,0 +1,164 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// startScheduler runs the scheduler until the test ends
+func startScheduler(t *testing.T, s *flow.Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errc; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the scheduler to stop with the context, got %v", err)
+		}
+	})
+}
+
+func TestSchedulerJitterSeed(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	nextRun := func(t *testing.T) time.Time {
+		clock := flowtest.NewFakeClock(start)
+		s := flow.NewScheduler(flow.NewWorkflow(flow.WithClock(clock)), flow.Every(time.Minute),
+			flow.Jitter(time.Minute), flow.JitterSeed(7))
+		startScheduler(t, s)
+		clock.BlockUntil(1)
+		return s.NextRun()
+	}
+	first, second := nextRun(t), nextRun(t)
+	if !first.Equal(second) {
+		t.Fatalf("expected the same seed to give the same next run, got %v and %v", first, second)
+	}
+	if first.Before(start.Add(time.Minute)) || !first.Before(start.Add(2*time.Minute)) {
+		t.Fatalf("expected the next run within the jitter after the interval, got %v", first)
+	}
+}
+
+func TestSchedulerCoalescesTriggers(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := flowtest.NewFakeClock(start)
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	started, release := make(chan time.Time), make(chan struct{})
+	err := w.AddTask(flow.NewTask(1, "sync inventory", func(context.Context, *flow.Task) error {
+		started <- clock.Now()
+		<-release
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := flow.NewScheduler(w, flow.Every(time.Hour))
+	startScheduler(t, s)
+
+	// the triggers arrive while the first run is executing
+	<-started
+	for i := 0; i < 100; i++ {
+		s.Trigger()
+	}
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	// the next run is the periodic one, which starts only when the clock advances
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	if at := <-started; !at.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected the triggers to result in a single follow-up run, but a third run started at %v", at)
+	}
+	if n := s.Runs(); n != 2 {
+		t.Fatalf("expected 2 runs before the periodic one, got %d", n)
+	}
+	release <- struct{}{}
+}
+
+// recordingWorkflow creates a workflow that sends the time of the clock to the returned channel when it reconciles
+func recordingWorkflow(t *testing.T, clock *flowtest.FakeClock) (*flow.Workflow, <-chan time.Time) {
+	t.Helper()
+	starts := make(chan time.Time, 10)
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	err := w.AddTask(flow.NewTask(1, "sync inventory", func(context.Context, *flow.Task) error {
+		starts <- clock.Now()
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, starts
+}
+
+// awaitNextRun waits until the scheduler plans its next run at the given time, e.g. after it received a trigger
+func awaitNextRun(t *testing.T, s *flow.Scheduler, next time.Time) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.NextRun().Equal(next) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the next run at %v, got %v", next, s.NextRun())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// expectNoRun fails the test, if the workflow started reconciling
+func expectNoRun(t *testing.T, starts <-chan time.Time) {
+	t.Helper()
+	select {
+	case at := <-starts:
+		t.Fatalf("expected no run, got one at %v", at)
+	default:
+	}
+}
+
+func TestSchedulerDebounce(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := flowtest.NewFakeClock(start)
+	w, starts := recordingWorkflow(t, clock)
+	s := flow.NewScheduler(w, flow.Every(time.Hour), flow.WithDebounce(10*time.Second))
+	startScheduler(t, s)
+	<-starts
+	awaitNextRun(t, s, start.Add(time.Hour))
+
+	s.Trigger()
+	awaitNextRun(t, s, start.Add(10*time.Second))
+	clock.Advance(5 * time.Second)
+	// every trigger restarts the quiet period
+	s.Trigger()
+	awaitNextRun(t, s, start.Add(15*time.Second))
+	clock.Advance(9 * time.Second)
+	expectNoRun(t, starts)
+	clock.Advance(time.Second)
+	if at := <-starts; !at.Equal(start.Add(15 * time.Second)) {
+		t.Fatalf("expected a single run after the quiet period, got one at %v", at)
+	}
+	awaitNextRun(t, s, start.Add(15*time.Second+time.Hour))
+	expectNoRun(t, starts)
+}
+
+func TestSchedulerCooldown(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := flowtest.NewFakeClock(start)
+	w, starts := recordingWorkflow(t, clock)
+	s := flow.NewScheduler(w, flow.Every(10*time.Second), flow.WithCooldown(time.Minute))
+	startScheduler(t, s)
+	<-starts
+	// the cooldown delays the periodic run
+	awaitNextRun(t, s, start.Add(time.Minute))
+
+	// and a triggered one
+	s.Trigger()
+	awaitNextRun(t, s, start.Add(time.Minute))
+	clock.Advance(59 * time.Second)
+	expectNoRun(t, starts)
+	clock.Advance(time.Second)
+	if at := <-starts; !at.Equal(start.Add(time.Minute)) {
+		t.Fatalf("expected the next run after the cooldown, got one at %v", at)
+	}
+	awaitNextRun(t, s, start.Add(2*time.Minute))
+}
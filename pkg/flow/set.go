@@ -0,0 +1,227 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrDuplicateWorkflow indicates that a workflow with the given name already exists or the workflow is already part
+// of the set under another name
+var ErrDuplicateWorkflow = errors.New("workflow already exists")
+
+// Set holds named workflows and reconciles them concurrently, e.g. one workflow per managed machine.
+// Workflows can be added and removed while the set is reconciled.
+type Set struct {
+	parallelism int
+	// serializes the reconciles, so that no workflow is reconciled concurrently
+	reconcileMu sync.Mutex
+
+	mu        sync.RWMutex
+	workflows map[string]*Workflow
+	// errors of the last reconcile of each workflow
+	errs map[string]error
+}
+
+// NewSet creates an empty set that reconciles at most the given number of workflows at the same time.
+// A parallelism less than 1 does not bound the number of concurrent reconciles.
+func NewSet(parallelism int) *Set {
+	return &Set{
+		parallelism: parallelism,
+		workflows:   make(map[string]*Workflow),
+		errs:        make(map[string]error),
+	}
+}
+
+// Add adds the workflow with the given name to the set.
+// It returns an error matching ErrDuplicateWorkflow, if the set already holds a workflow with the name or the
+// workflow under another name, since a workflow must not be reconciled concurrently.
+func (s *Set) Add(name string, w *Workflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.workflows[name]; ok {
+		return fmt.Errorf("error adding workflow %q: %w", name, ErrDuplicateWorkflow)
+	}
+	for existing, other := range s.workflows {
+		if other == w {
+			return fmt.Errorf("error adding workflow %q, which is part of the set as %q: %w", name, existing,
+				ErrDuplicateWorkflow)
+		}
+	}
+	s.workflows[name] = w
+	return nil
+}
+
+// Remove removes the workflow with the given name from the set and returns true, if the set held it.
+// A reconcile of the workflow that is already executing is not canceled.
+func (s *Set) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.workflows[name]
+	delete(s.workflows, name)
+	delete(s.errs, name)
+	return ok
+}
+
+// Get returns the workflow with the given name
+func (s *Set) Get(name string) (*Workflow, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.workflows[name]
+	return w, ok
+}
+
+// Names returns the sorted names of the workflows in the set
+func (s *Set) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.workflows))
+	for name := range s.workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of workflows in the set
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.workflows)
+}
+
+// Reconcile reconciles all workflows of the set concurrently within the bound of the parallelism and waits until
+// all reconciles completed. The failure of a workflow, even with a FatalError, does not affect the others.
+// If any workflow fails, a SetError is returned, which holds the errors by name of the workflow.
+// Workflows added during the reconcile are reconciled by the next one. Concurrent calls are serialized.
+func (s *Set) Reconcile(ctx context.Context) error {
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+	s.mu.RLock()
+	workflows := make(map[string]*Workflow, len(s.workflows))
+	for name, w := range s.workflows {
+		workflows[name] = w
+	}
+	s.mu.RUnlock()
+
+	var sem chan struct{}
+	if s.parallelism > 0 {
+		sem = make(chan struct{}, s.parallelism)
+	}
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	var wg sync.WaitGroup
+	for name, w := range workflows {
+		name, w := name, w
+		if sem != nil {
+			sem <- struct{}{}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := w.Reconcile(ctx)
+			if sem != nil {
+				<-sem
+			}
+			s.record(name, w, err)
+			if err != nil {
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return SetError{Errors: errs}
+	}
+	return nil
+}
+
+// record records the error of the last reconcile of the named workflow, unless it was removed in the meantime
+func (s *Set) record(name string, w *Workflow, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.workflows[name] != w {
+		return
+	}
+	if err == nil {
+		delete(s.errs, name)
+		return
+	}
+	s.errs[name] = err
+}
+
+// SetReport is the aggregated report of the workflows in a Set
+type SetReport struct {
+	// Workflows are the reports of the workflows by name
+	Workflows map[string]Report
+	// Errors are the errors of the last reconcile of the failed workflows by name
+	Errors map[string]error
+}
+
+// Count returns the number of tasks across all workflows, that have the given status
+func (r SetReport) Count(status Status) int {
+	count := 0
+	for _, report := range r.Workflows {
+		for _, task := range report.Tasks {
+			if task.Status == status {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Report returns the reports of all workflows in the set and the errors of their last reconcile.
+// See Workflow.Report for the options.
+func (s *Set) Report(opts ...ReportOption) (SetReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	report := SetReport{
+		Workflows: make(map[string]Report, len(s.workflows)),
+		Errors:    make(map[string]error, len(s.errs)),
+	}
+	for name, w := range s.workflows {
+		r, err := w.Report(opts...)
+		if err != nil {
+			return SetReport{}, fmt.Errorf("error reporting workflow %q: %w", name, err)
+		}
+		report.Workflows[name] = r
+	}
+	for name, err := range s.errs {
+		report.Errors[name] = err
+	}
+	return report, nil
+}
+
+// SetError holds the errors of the failed workflows of a Set's reconcile by name of the workflow.
+type SetError struct {
+	Errors map[string]error
+}
+
+func (e SetError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("workflow %q: %v", name, e.Errors[name]))
+	}
+	return fmt.Sprintf("%d workflows failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the failed workflows, so that errors.Is and errors.As match any of them.
+func (e SetError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
@@ -0,0 +1,107 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestSetAddDuplicate(t *testing.T) {
+	s := flow.NewSet(2)
+	w := flow.NewWorkflow()
+	if err := s.Add("machine-1", w); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("machine-1", flow.NewWorkflow()); !errors.Is(err, flow.ErrDuplicateWorkflow) {
+		t.Fatalf("expected error matching ErrDuplicateWorkflow for the same name, got %v", err)
+	}
+	if err := s.Add("machine-2", w); !errors.Is(err, flow.ErrDuplicateWorkflow) {
+		t.Fatalf("expected error matching ErrDuplicateWorkflow for the same workflow, got %v", err)
+	}
+	if n := s.Len(); n != 1 {
+		t.Fatalf("expected 1 workflow, got %d", n)
+	}
+}
+
+func TestSetReconcileParallelism(t *testing.T) {
+	var active atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	s := flow.NewSet(2)
+	for i := 0; i < 6; i++ {
+		w := flow.NewWorkflow()
+		err := w.AddTask(flow.NewTask(1, "provision", func(context.Context, *flow.Task) error {
+			if n := active.Add(1); n > 2 {
+				t.Errorf("expected at most 2 concurrent reconciles, got %d", n)
+			}
+			entered <- struct{}{}
+			<-release
+			active.Add(-1)
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add(fmt.Sprintf("machine-%d", i), w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Reconcile(context.Background()) }()
+	// two workflows reconcile at the same time, each further one starts when one of them completed
+	<-entered
+	<-entered
+	for i := 0; i < 4; i++ {
+		release <- struct{}{}
+		<-entered
+	}
+	release <- struct{}{}
+	release <- struct{}{}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetReconcileErrors(t *testing.T) {
+	unavailable, invalid := errors.New("api unavailable"), errors.New("invalid spec")
+	fns := map[string]flow.Fn{
+		"healthy":   func(context.Context, *flow.Task) error { return nil },
+		"retryable": func(context.Context, *flow.Task) error { return unavailable },
+		"fatal":     func(context.Context, *flow.Task) error { return flow.NewFatalError(invalid) },
+	}
+	// a parallelism of one reconciles the workflows one after another, so that the fatal error may precede others
+	s := flow.NewSet(1)
+	for name, fn := range fns {
+		w := flow.NewWorkflow()
+		if err := w.AddTask(flow.NewTask(1, "provision", fn)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Add(name, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.Reconcile(context.Background())
+	var setErr flow.SetError
+	if !errors.As(err, &setErr) || len(setErr.Errors) != 2 {
+		t.Fatalf("expected a SetError of two workflows, got %v", err)
+	}
+	if !errors.Is(setErr.Errors["retryable"], unavailable) || flow.IsFatal(setErr.Errors["retryable"]) {
+		t.Fatalf("expected the retryable error, got %v", setErr.Errors["retryable"])
+	}
+	if !errors.Is(setErr.Errors["fatal"], invalid) || !flow.IsFatal(setErr.Errors["fatal"]) {
+		t.Fatalf("expected the fatal error, got %v", setErr.Errors["fatal"])
+	}
+	if !errors.Is(err, unavailable) || !errors.Is(err, invalid) {
+		t.Fatalf("expected the SetError to match the errors of the workflows, got %v", err)
+	}
+	report, err := s.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(flow.Succeeded) != 1 || report.Count(flow.Failed) != 2 || len(report.Errors) != 2 {
+		t.Fatalf("expected the healthy workflow to succeed despite the fatal error, got %+v", report)
+	}
+}
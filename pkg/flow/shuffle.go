@@ -0,0 +1,60 @@
+package flow
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// ShuffleIndependent orders the tasks, that do not depend on each other, randomly instead of by id, e.g. to detect
+// hidden dependencies between tasks in soak tests. The order is derived from the given seed, so that a failing order
+// can be reproduced. The seed of the last reconcile is part of the report, see Report.ShuffleSeed.
+func ShuffleIndependent(seed int64) RunOption {
+	return func(c *runConfig) {
+		c.shuffle = true
+		c.seed = seed
+	}
+}
+
+// runOrder returns the order in which the tasks are executed with the given configuration
+func (w *Workflow) runOrder(config runConfig) ([]*Task, error) {
+	if !config.shuffle {
+		return w.orderedTasks()
+	}
+	return w.shuffledOrder(config.seed)
+}
+
+// shuffledOrder returns an executable order, in which the tasks that do not depend on each other are ordered
+// randomly, deterministically from the given seed
+func (w *Workflow) shuffledOrder(seed int64) ([]*Task, error) {
+	// the regular order validates the workflow and contains all tasks in a deterministic order
+	ordered, err := w.orderedTasks()
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	pending := make(map[int64]int, len(ordered))
+	var ready []int64
+	for _, task := range ordered {
+		pending[task.id] = w.graph.To(task.id).Len()
+		if pending[task.id] == 0 {
+			ready = append(ready, task.id)
+		}
+	}
+	order := make([]*Task, 0, len(ordered))
+	for len(ready) > 0 {
+		i := rng.Intn(len(ready))
+		id := ready[i]
+		ready[i] = ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		order = append(order, w.tasks[id])
+		next := successors(w, id)
+		sort.Slice(next, func(i, j int) bool { return next[i] < next[j] })
+		for _, n := range next {
+			pending[n]--
+			if pending[n] == 0 {
+				ready = append(ready, n)
+			}
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,70 @@
+package flow_test
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// shuffledIDs returns the ids of the tasks of the workflow in the order shuffled with the given seed
+func shuffledIDs(t *testing.T, w *flow.Workflow, seed int64) []int64 {
+	t.Helper()
+	tasks, err := w.GetOrderedTasks(flow.ShuffleIndependent(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID()
+	}
+	return ids
+}
+
+func TestShuffleIndependent(t *testing.T) {
+	w := flowtest.RandomDAG(rand.New(rand.NewSource(1)), 30, 40)
+	ids := shuffledIDs(t, w, 42)
+	if err := flow.VerifyOrder(w, ids); err != nil {
+		t.Fatalf("expected the shuffled order to respect the dependencies: %v", err)
+	}
+	if again := shuffledIDs(t, w, 42); !reflect.DeepEqual(again, ids) {
+		t.Fatalf("expected the same order for the same seed, got %v and %v", ids, again)
+	}
+	regular, err := w.OrderedTaskIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	differs := false
+	for seed := int64(0); seed < 10 && !differs; seed++ {
+		differs = !reflect.DeepEqual(shuffledIDs(t, w, seed), regular)
+	}
+	if !differs {
+		t.Fatal("expected a shuffled order to differ from the regular order")
+	}
+}
+
+func TestShuffleSeedInReport(t *testing.T) {
+	w := flowtest.RandomDAG(rand.New(rand.NewSource(1)), 5, 3)
+	if err := w.Reconcile(context.Background(), flow.ShuffleIndependent(42)); err != nil {
+		t.Fatal(err)
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ShuffleSeed == nil || *report.ShuffleSeed != 42 {
+		t.Fatalf("expected the seed 42 in the report, got %v", report.ShuffleSeed)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = w.Report(); err != nil {
+		t.Fatal(err)
+	}
+	if report.ShuffleSeed != nil {
+		t.Fatalf("expected no seed after a regular reconcile, got %d", *report.ShuffleSeed)
+	}
+}
@@ -0,0 +1,133 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownGracePeriod is the default time RunWithGracefulShutdown waits for the running task after a signal
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// ShutdownOption configures RunWithGracefulShutdown
+type ShutdownOption func(c *shutdownConfig)
+
+type shutdownConfig struct {
+	signals []os.Signal
+	source  <-chan os.Signal
+	grace   time.Duration
+	persist func(ctx context.Context, snapshot Snapshot) error
+}
+
+// ShutdownSignals sets the signals that shut the workflow down. Defaults to SIGINT and SIGTERM.
+func ShutdownSignals(signals ...os.Signal) ShutdownOption {
+	return func(c *shutdownConfig) {
+		c.signals = signals
+	}
+}
+
+// ShutdownSignalSource sets the channel on which signals are received instead of the signals of the process,
+// e.g. to test the shutdown.
+func ShutdownSignalSource(source <-chan os.Signal) ShutdownOption {
+	return func(c *shutdownConfig) {
+		c.source = source
+	}
+}
+
+// ShutdownGracePeriod sets the time to wait for the running task after a signal, before its context is canceled.
+// The time is measured with the workflow's clock. Defaults to DefaultShutdownGracePeriod.
+func ShutdownGracePeriod(d time.Duration) ShutdownOption {
+	return func(c *shutdownConfig) {
+		c.grace = d
+	}
+}
+
+// PersistWith sets the function that persists the snapshot of the workflow after the reconcile, e.g. to a file
+// or a database, so that the next process resumes where this one stopped, see Restore.
+func PersistWith(persist func(ctx context.Context, snapshot Snapshot) error) ShutdownOption {
+	return func(c *shutdownConfig) {
+		c.persist = persist
+	}
+}
+
+// RunWithGracefulShutdown reconciles the workflow once like Reconcile, which stops early, if one of the shutdown
+// signals is received. It does not retry a failed reconcile, see RunUntilDone for that. After a signal, no further
+// task is started except those that always run, and the running task gets the grace period to complete before its
+// context is canceled. Then the state of the workflow is persisted, if configured, see PersistWith. It returns the
+// report of the workflow and the error of Reconcile, which matches ErrShutdown after a signal, joined with the error
+// persisting the state.
+func RunWithGracefulShutdown(ctx context.Context, w *Workflow, opts ...ShutdownOption) (Report, error) {
+	config := shutdownConfig{
+		signals: []os.Signal{os.Interrupt, syscall.SIGTERM},
+		grace:   DefaultShutdownGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.source == nil {
+		source := make(chan os.Signal, 1)
+		signal.Notify(source, config.signals...)
+		defer signal.Stop(source)
+		config.source = source
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Reconcile(runCtx, stopOn(stop))
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-config.source:
+		close(stop)
+		select {
+		case err = <-done:
+		case <-w.clock.After(config.grace):
+			cancel()
+			err = <-done
+			// the canceled task fails the reconcile before it notices the shutdown
+			if !errors.Is(err, ErrShutdown) {
+				err = errors.Join(err, ErrShutdown)
+			}
+		}
+	}
+
+	if config.persist != nil {
+		snapshot, persistErr := w.Snapshot()
+		if persistErr == nil {
+			persistErr = config.persist(ctx, snapshot)
+		}
+		if persistErr != nil {
+			err = errors.Join(err, fmt.Errorf("error persisting workflow state: %w", persistErr))
+		}
+	}
+	report, reportErr := w.Report()
+	if reportErr != nil {
+		return Report{}, errors.Join(err, reportErr)
+	}
+	return report, err
+}
+
+// stopOn stops the reconcile before the next task, once the given channel is closed
+func stopOn(stop <-chan struct{}) RunOption {
+	return func(c *runConfig) {
+		c.stop = stop
+	}
+}
+
+// stopped returns true, if the reconcile must not start further tasks, see stopOn
+func (c runConfig) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,120 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// shutdownWorkflow creates a workflow whose first task signals its start and runs the given function,
+// followed by a second task
+func shutdownWorkflow(t *testing.T, clock flow.Clock, fn flow.Fn) (*flow.Workflow, <-chan struct{}) {
+	t.Helper()
+	started := make(chan struct{})
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	_, _, err := flow.Chain(w,
+		flow.NewTask(1, "flash firmware", func(ctx context.Context, task *flow.Task) error {
+			close(started)
+			return fn(ctx, task)
+		}),
+		flow.NewTask(2, "reboot", nop),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, started
+}
+
+func TestRunWithGracefulShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// completes determines, whether the running task completes within the grace period
+		completes bool
+		want      map[int64]flow.Status
+	}{
+		{name: "task completes within grace period", completes: true,
+			want: map[int64]flow.Status{1: flow.Succeeded, 2: flow.Pending}},
+		{name: "grace period expires", want: map[int64]flow.Status{1: flow.Failed, 2: flow.Pending}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			release := make(chan struct{})
+			w, started := shutdownWorkflow(t, clock, func(ctx context.Context, _ *flow.Task) error {
+				select {
+				case <-release:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			signals := make(chan os.Signal, 1)
+			var persisted []flow.Snapshot
+			type result struct {
+				report flow.Report
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				report, err := flow.RunWithGracefulShutdown(context.Background(), w,
+					flow.ShutdownSignalSource(signals), flow.ShutdownGracePeriod(time.Minute),
+					flow.PersistWith(func(_ context.Context, snapshot flow.Snapshot) error {
+						persisted = append(persisted, snapshot)
+						return nil
+					}))
+				done <- result{report: report, err: err}
+			}()
+
+			<-started
+			signals <- os.Interrupt
+			clock.BlockUntil(1)
+			if tt.completes {
+				close(release)
+			} else {
+				clock.Advance(time.Minute)
+			}
+			res := <-done
+			if !errors.Is(res.err, flow.ErrShutdown) {
+				t.Fatalf("expected error matching ErrShutdown, got %v", res.err)
+			}
+			if len(persisted) != 1 {
+				t.Fatalf("expected the state to be persisted once, got %d times", len(persisted))
+			}
+			snapshotStatuses := make(map[int64]flow.Status)
+			for id, task := range persisted[0].Tasks {
+				snapshotStatuses[id] = task.Status
+			}
+			reportStatuses := make(map[int64]flow.Status)
+			for _, task := range res.report.Tasks {
+				reportStatuses[task.ID] = task.Status
+			}
+			if !reflect.DeepEqual(snapshotStatuses, tt.want) || !reflect.DeepEqual(reportStatuses, tt.want) {
+				t.Fatalf("expected statuses %v, got %v persisted and %v reported", tt.want, snapshotStatuses,
+					reportStatuses)
+			}
+		})
+	}
+}
+
+func TestRunWithGracefulShutdownWithoutSignal(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w, _ := shutdownWorkflow(t, clock, nop)
+	persistErr := errors.New("disk full")
+	report, err := flow.RunWithGracefulShutdown(context.Background(), w,
+		flow.ShutdownSignalSource(make(chan os.Signal)),
+		flow.PersistWith(func(context.Context, flow.Snapshot) error { return persistErr }))
+	if !errors.Is(err, persistErr) || errors.Is(err, flow.ErrShutdown) {
+		t.Fatalf("expected only the error persisting the state, got %v", err)
+	}
+	for _, task := range report.Tasks {
+		if task.Status != flow.Succeeded {
+			t.Fatalf("expected all tasks to succeed, got %+v", report.Tasks)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package flow
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// slaConfig is the service level of a task, see SLA
+type slaConfig struct {
+	duration time.Duration
+	onBreach func(task *Task, elapsed time.Duration)
+}
+
+// watchSLA starts watching the service level of the given task, if it has one, and returns the function that
+// stops watching once the task finished. The breach is recorded in the state of the task and the audit trail.
+func (w *Workflow) watchSLA(task *Task, state *taskState) (stop func()) {
+	if task.sla == nil {
+		return func() {}
+	}
+	start := w.clock.Now()
+	timer := w.clock.NewTimer(task.sla.duration)
+	done := make(chan struct{})
+	breached := false
+	breach := func() {
+		breached = true
+		w.update(func() { state.slaBreached = true })
+		w.audit(AuditSLABreached, task, fmt.Sprintf("exceeded %s", task.sla.duration), nil)
+		if task.sla.onBreach != nil {
+			task.sla.onBreach(task, w.clock.Now().Sub(start))
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-done:
+		case <-timer.C():
+			breach()
+		}
+	}()
+	return func() {
+		timer.Stop()
+		close(done)
+		wg.Wait()
+		// the task can finish at the time the timer fires
+		if !breached && w.clock.Now().Sub(start) >= task.sla.duration {
+			breach()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package flow_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+func TestSLABreach(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	var audit bytes.Buffer
+	w := flow.NewWorkflow(flow.WithClock(clock), flow.WithAuditWriter(&audit))
+	var breached []time.Duration
+	err := w.AddTask(flow.NewTask(1, "drain node", func(ctx context.Context, _ *flow.Task) error {
+		return flow.Sleep(ctx, 2*time.Minute)
+	}, flow.SLA(time.Minute, func(_ *flow.Task, elapsed time.Duration) {
+		breached = append(breached, elapsed)
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- w.Reconcile(context.Background()) }()
+	// the timer of the SLA and the one of the sleep
+	clock.BlockUntil(2)
+	clock.Advance(2 * time.Minute)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if len(breached) != 1 || breached[0] != 2*time.Minute {
+		t.Fatalf("expected one breach after 2m, got %v", breached)
+	}
+	report, err := w.Report()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Tasks[0].SLABreached {
+		t.Fatal("expected the breach in the report")
+	}
+	if !strings.Contains(audit.String(), `"event":"sla breached"`) {
+		t.Fatalf("expected the breach in the audit trail, got %s", audit.String())
+	}
+}
+
+func TestSLAStopsTimer(t *testing.T) {
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(flow.WithClock(clock))
+	err := w.AddTask(flow.NewTask(1, "label node", func(context.Context, *flow.Task) error { return nil },
+		flow.SLA(time.Minute, func(*flow.Task, time.Duration) { t.Error("expected no breach") })))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := clock.Waiters(); n != 0 {
+		t.Fatalf("expected the timer of the SLA to be stopped, got %d pending timers", n)
+	}
+	clock.Advance(time.Hour)
+}
@@ -0,0 +1,216 @@
+package flow
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Snapshot is the persistable state of the tasks of a workflow, e.g. to resume it after a restart.
+// It marshals to versioned JSON, see SnapshotVersion.
+type Snapshot struct {
+	// RunID is the id of the last reconcile, so that a restored workflow continues counting from it
+	RunID uint64 `json:"runID,omitempty"`
+	// Definition is the definition hash of the workflow, see Workflow.DefinitionHash
+	Definition string `json:"definition,omitempty"`
+	// Tasks are the states of the tasks, key is the task id
+	Tasks map[int64]TaskSnapshot `json:"tasks"`
+	// State are the values of the state bag, if the workflow persists it, see WithPersistentState
+	State map[string]json.RawMessage `json:"state,omitempty"`
+}
+
+// TaskSnapshot is the persistable state of a single task
+type TaskSnapshot struct {
+	Description string                     `json:"description,omitempty"`
+	Status      Status                     `json:"status"`
+	Reason      string                     `json:"reason,omitempty"`
+	Attempts    int                        `json:"attempts,omitempty"`
+	Fingerprint string                     `json:"fingerprint,omitempty"`
+	Outputs     map[string]json.RawMessage `json:"outputs,omitempty"`
+	LastError   *ErrorRecord               `json:"lastError,omitempty"`
+}
+
+// restoredValue is an output value restored from a snapshot, which is decoded when it is read
+type restoredValue json.RawMessage
+
+// Snapshot returns the persistable state of the tasks of the workflow. Sensitive outputs are not included.
+// The state bag is only included, if the workflow persists it, see WithPersistentState.
+func (w *Workflow) Snapshot() (Snapshot, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	snapshot := Snapshot{
+		RunID:      w.runID,
+		Definition: w.definitionHash(),
+		Tasks:      make(map[int64]TaskSnapshot, len(w.states)),
+	}
+	for id, state := range w.states {
+		task := TaskSnapshot{
+			Description: w.tasks[id].desc,
+			Status:      state.status,
+			Reason:      state.reason,
+			Attempts:    state.attempts,
+			Fingerprint: state.fingerprint,
+			LastError:   state.lastErr,
+		}
+		for key, value := range state.outputs {
+			if state.sensitive[key] {
+				continue
+			}
+			data, err := marshalOutput(value)
+			if err != nil {
+				return Snapshot{}, fmt.Errorf("error snapshotting output %q of task id %d: %w", key, id, err)
+			}
+			if task.Outputs == nil {
+				task.Outputs = make(map[string]json.RawMessage)
+			}
+			task.Outputs[key] = data
+		}
+		snapshot.Tasks[id] = task
+	}
+	if w.persistState {
+		state, err := w.state.marshal()
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.State = state
+	}
+	return snapshot, nil
+}
+
+// RestoreOption configures Restore
+type RestoreOption func(c *restoreConfig)
+
+type restoreConfig struct {
+	acceptDrift bool
+}
+
+// AcceptDefinitionDrift restores a snapshot, even if the definition of the workflow changed since it was taken.
+// Only the states of the tasks, whose id and description still match, are restored, all other tasks are reset to
+// Pending and tasks of the snapshot that are no longer part of the workflow are ignored.
+func AcceptDefinitionDrift() RestoreOption {
+	return func(c *restoreConfig) {
+		c.acceptDrift = true
+	}
+}
+
+// Restore replaces the state of the tasks of the workflow with the given snapshot. Tasks that are not part of the
+// snapshot are reset to Pending. A task that was Running, when the snapshot was taken, is restored as Pending.
+// Restored outputs are decoded from JSON when they are read, see Output and Result, as are the values of the state
+// bag, if the snapshot includes it, see WithPersistentState.
+// If the definition of the workflow changed since the snapshot was taken, i.e. its definition hash differs, Restore
+// fails with ErrDefinitionChanged, unless the drift is accepted, see AcceptDefinitionDrift. Snapshots without a
+// definition hash are restored without this check.
+func (w *Workflow) Restore(snapshot Snapshot, opts ...RestoreOption) error {
+	var config restoreConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	drift := false
+	if snapshot.Definition != "" {
+		if current := w.definitionHash(); current != snapshot.Definition {
+			if !config.acceptDrift {
+				return fmt.Errorf("error restoring snapshot with definition %s into definition %s: %w",
+					snapshot.Definition, current, ErrDefinitionChanged)
+			}
+			drift = true
+		}
+	}
+	if !drift {
+		for id := range snapshot.Tasks {
+			if _, ok := w.states[id]; !ok {
+				return fmt.Errorf("error restoring task id %d: %w", id, ErrTaskNotFound)
+			}
+		}
+	}
+	for id := range w.states {
+		task := snapshot.Tasks[id]
+		if drift && task.Description != w.tasks[id].desc {
+			task = TaskSnapshot{}
+		}
+		state := &taskState{
+			status:      task.Status,
+			reason:      task.Reason,
+			attempts:    task.Attempts,
+			fingerprint: task.Fingerprint,
+			lastErr:     task.LastError,
+		}
+		if state.status == Running {
+			state.status = Pending
+		}
+		for key, data := range task.Outputs {
+			if state.outputs == nil {
+				state.outputs = make(map[string]any)
+			}
+			state.outputs[key] = restoredValue(data)
+		}
+		w.states[id] = state
+	}
+	w.runID = snapshot.RunID
+	if snapshot.State != nil {
+		state := &StateBag{values: make(map[string]any, len(snapshot.State))}
+		for key, data := range snapshot.State {
+			state.values[key] = restoredValue(data)
+		}
+		w.state = state
+		// the next reconcile continues with the restored values
+		w.stateRunID = 0
+	}
+	return nil
+}
+
+// DefinitionHash returns a digest of the structure of the workflow, i.e. the ids and descriptions of its tasks and
+// their dependencies, which is stored in snapshots to detect changes of the definition, see Restore.
+func (w *Workflow) DefinitionHash() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.definitionHash()
+}
+
+func (w *Workflow) definitionHash() string {
+	ids := make([]int64, 0, len(w.tasks))
+	for id := range w.tasks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	h := sha256.New()
+	var buf [8]byte
+	writeID := func(id int64) {
+		binary.BigEndian.PutUint64(buf[:], uint64(id))
+		h.Write(buf[:])
+	}
+	for _, id := range ids {
+		writeID(id)
+		writeLengthPrefixed(h, []byte(w.tasks[id].desc))
+		var deps []int64
+		nodes := w.graph.To(id)
+		for nodes.Next() {
+			deps = append(deps, nodes.Node().ID())
+		}
+		sort.Slice(deps, func(i, j int) bool { return deps[i] < deps[j] })
+		writeID(int64(len(deps)))
+		for _, dep := range deps {
+			writeID(dep)
+			if w.softEdges[edgeID{from: dep, to: id}] {
+				h.Write([]byte{1})
+			} else {
+				h.Write([]byte{0})
+			}
+		}
+	}
+	return digest(h)
+}
+
+func marshalOutput(value any) (json.RawMessage, error) {
+	if restored, ok := value.(restoredValue); ok {
+		return json.RawMessage(restored), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("output is not JSON-marshalable: %w", err)
+	}
+	return data, nil
+}
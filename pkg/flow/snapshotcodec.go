@@ -0,0 +1,128 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// SnapshotVersion is the version of the JSON format of snapshots written by this package.
+// Snapshots of older versions are migrated when they are unmarshaled, newer versions are rejected.
+//
+// Version 1 encoded the status of a task as number, version 2 encodes it as name, e.g. "succeeded",
+// so that the format does not depend on the order of the statuses.
+const SnapshotVersion = 2
+
+// snapshotMigrations upgrade the JSON of a snapshot of the version given by the index to the next version
+var snapshotMigrations = []func(data []byte) ([]byte, error){
+	1: migrateSnapshotV1,
+}
+
+// snapshotFields and taskSnapshotFields have the fields, but not the methods of their snapshot types
+type (
+	snapshotFields     Snapshot
+	taskSnapshotFields TaskSnapshot
+)
+
+// MarshalJSON encodes the snapshot in the format of SnapshotVersion
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Version int `json:"version"`
+		snapshotFields
+	}{
+		Version:        SnapshotVersion,
+		snapshotFields: snapshotFields(s),
+	})
+}
+
+// UnmarshalJSON decodes the snapshot and migrates older versions of the format.
+// A newer version than SnapshotVersion or a negative version is rejected with ErrUnsupportedSnapshotVersion.
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+	version := header.Version
+	// the version was not written before version 2
+	if version == 0 {
+		version = 1
+	}
+	if version < 1 || version > SnapshotVersion {
+		return fmt.Errorf("error decoding snapshot of version %d, supported are versions 1 to %d: %w", version,
+			SnapshotVersion, ErrUnsupportedSnapshotVersion)
+	}
+	for ; version < SnapshotVersion; version++ {
+		var err error
+		data, err = snapshotMigrations[version](data)
+		if err != nil {
+			return fmt.Errorf("error migrating snapshot from version %d: %w", version, err)
+		}
+	}
+	return json.Unmarshal(data, (*snapshotFields)(s))
+}
+
+// MarshalJSON encodes the task snapshot with the name of its status
+func (t TaskSnapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Status string `json:"status"`
+		taskSnapshotFields
+	}{
+		Status:             t.Status.String(),
+		taskSnapshotFields: taskSnapshotFields(t),
+	})
+}
+
+// UnmarshalJSON decodes the task snapshot with the name of its status
+func (t *TaskSnapshot) UnmarshalJSON(data []byte) error {
+	fields := struct {
+		Status string `json:"status"`
+		*taskSnapshotFields
+	}{
+		taskSnapshotFields: (*taskSnapshotFields)(t),
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	status, err := parseStatus(fields.Status)
+	if err != nil {
+		return err
+	}
+	t.Status = status
+	return nil
+}
+
+// migrateSnapshotV1 replaces the numeric statuses of version 1 by their names
+func migrateSnapshotV1(data []byte) ([]byte, error) {
+	var snapshot map[string]json.RawMessage
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	var tasks map[string]map[string]json.RawMessage
+	if raw, ok := snapshot["tasks"]; ok {
+		if err := json.Unmarshal(raw, &tasks); err != nil {
+			return nil, err
+		}
+	}
+	for id, task := range tasks {
+		number := 0
+		if raw, ok := task["status"]; ok {
+			if err := json.Unmarshal(raw, &number); err != nil {
+				return nil, fmt.Errorf("error migrating status of task id %s: %w", id, err)
+			}
+		}
+		name, err := json.Marshal(Status(number).String())
+		if err != nil {
+			return nil, err
+		}
+		task["status"] = name
+	}
+	raw, err := json.Marshal(tasks)
+	if err != nil {
+		return nil, err
+	}
+	snapshot["tasks"] = raw
+	snapshot["version"] = json.RawMessage(strconv.Itoa(2))
+	return json.Marshal(snapshot)
+}
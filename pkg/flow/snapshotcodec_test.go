@@ -0,0 +1,81 @@
+package flow_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	snapshot := flow.Snapshot{
+		RunID:      3,
+		Definition: "abc",
+		Tasks: map[int64]flow.TaskSnapshot{
+			1: {Description: "create V1", Status: flow.Succeeded, Attempts: 2, Fingerprint: "f1",
+				Outputs: map[string]json.RawMessage{"id": json.RawMessage(`"v1"`)}},
+			2: {Description: "render config", Status: flow.Skipped, Reason: "unchanged"},
+			3: {Description: "approve", Status: flow.WaitingForApproval},
+		},
+		State: map[string]json.RawMessage{"token": json.RawMessage(`42`)},
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"version":2`) || !strings.Contains(string(data), `"status":"succeeded"`) {
+		t.Fatalf("expected version 2 with status names, got %s", data)
+	}
+	var decoded flow.Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, snapshot) {
+		t.Fatalf("expected %+v, got %+v", snapshot, decoded)
+	}
+}
+
+func TestSnapshotMigratesV1(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "without version", data: `{"runID":3,"tasks":{"1":{"status":2,"attempts":1},"2":{"status":4,` +
+			`"reason":"unchanged"},"3":{}}}`},
+		{name: "version 1", data: `{"version":1,"runID":3,"tasks":{"1":{"status":2,"attempts":1},"2":{"status":4,` +
+			`"reason":"unchanged"},"3":{}}}`},
+	}
+	want := flow.Snapshot{
+		RunID: 3,
+		Tasks: map[int64]flow.TaskSnapshot{
+			1: {Status: flow.Succeeded, Attempts: 1},
+			2: {Status: flow.Skipped, Reason: "unchanged"},
+			3: {Status: flow.Pending},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var snapshot flow.Snapshot
+			if err := json.Unmarshal([]byte(tt.data), &snapshot); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(snapshot, want) {
+				t.Fatalf("expected %+v, got %+v", want, snapshot)
+			}
+		})
+	}
+}
+
+func TestSnapshotRejectsUnsupportedVersion(t *testing.T) {
+	for _, version := range []int{3, -1, -100} {
+		var snapshot flow.Snapshot
+		data := fmt.Sprintf(`{"version":%d,"tasks":{"1":{"status":"succeeded"}}}`, version)
+		if err := json.Unmarshal([]byte(data), &snapshot); !errors.Is(err, flow.ErrUnsupportedSnapshotVersion) {
+			t.Fatalf("expected error matching ErrUnsupportedSnapshotVersion for version %d, got %v", version, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// stage is a named group of tasks with a deadline, see SetStageDeadline
+type stage struct {
+	deadline time.Duration
+	tasks    map[int64]bool
+}
+
+// StageDeadlineError indicates that tasks of a stage did not finish within the deadline of the stage
+type StageDeadlineError struct {
+	// Stage is the name of the stage
+	Stage string
+	// Deadline is the deadline of the stage, measured from the start of its first task
+	Deadline time.Duration
+	// Laggards are the ids of the tasks of the stage, that did not finish within the deadline
+	Laggards []int64
+}
+
+func (e StageDeadlineError) Error() string {
+	return fmt.Sprintf("stage %q exceeded its deadline of %s with unfinished task ids %v", e.Stage, e.Deadline, e.Laggards)
+}
+
+// SetStageDeadline groups the given tasks into a stage with the given name, whose tasks must finish within the
+// deadline after the first of them started, e.g. all tasks of a level, see Levels. A task of the stage that would
+// start after the deadline is not executed, but recorded as Failed, so that Reconcile stops like after a failed
+// task. In continue-on-error mode, the tasks of other stages are still executed. Reconcile returns a
+// StageDeadlineError for each stage that exceeded its deadline. The time is measured with the workflow's clock.
+// Setting the deadline of an existing stage replaces it.
+func (w *Workflow) SetStageDeadline(name string, deadline time.Duration, tasks ...*Task) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := &stage{
+		deadline: deadline,
+		tasks:    make(map[int64]bool, len(tasks)),
+	}
+	for _, task := range tasks {
+		if _, ok := w.tasks[task.id]; !ok {
+			return fmt.Errorf("error setting deadline of stage %q for task id %d: %w", name, task.id, ErrTaskNotFound)
+		}
+		s.tasks[task.id] = true
+	}
+	if w.stages == nil {
+		w.stages = make(map[string]*stage)
+	}
+	w.stages[name] = s
+	return nil
+}
+
+// Levels returns the tasks grouped by their topological level, i.e. the length of the longest dependency chain
+// leading to them. The tasks of level 0 have no dependencies. Within a level, tasks are in execution order.
+func (w *Workflow) Levels() ([][]*Task, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	tasks, err := w.orderedTasks()
+	if err != nil {
+		return nil, err
+	}
+	level := make(map[int64]int, len(tasks))
+	var levels [][]*Task
+	for _, task := range tasks {
+		l := 0
+		deps := w.graph.To(task.id)
+		for deps.Next() {
+			if dl := level[deps.Node().ID()] + 1; dl > l {
+				l = dl
+			}
+		}
+		level[task.id] = l
+		for len(levels) <= l {
+			levels = append(levels, nil)
+		}
+		levels[l] = append(levels[l], task)
+	}
+	return levels, nil
+}
+
+// overdue records the task as laggard of the stages it belongs to, whose deadline passed, and returns true, if
+// there is any. The deadline of a stage starts, when its first task starts.
+func (w *Workflow) overdue(task *Task, p *pass) bool {
+	now := w.clock.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	overdue := false
+	for name, s := range w.stages {
+		if !s.tasks[task.id] {
+			continue
+		}
+		start, ok := p.stageStarts[name]
+		if !ok {
+			p.stageStarts[name] = now
+			continue
+		}
+		if now.Sub(start) > s.deadline {
+			if p.laggards[name] == nil {
+				p.laggards[name] = make(map[int64]bool)
+			}
+			p.laggards[name][task.id] = true
+			overdue = true
+		}
+	}
+	return overdue
+}
+
+// missDeadline records the task, that was not executed because a deadline of its stages passed, as Failed
+func (w *Workflow) missDeadline(task *Task, p *pass) {
+	p.mu.Lock()
+	w.mu.RLock()
+	var err error
+	for _, name := range w.stageNames() {
+		if p.laggards[name][task.id] {
+			err = StageDeadlineError{Stage: name, Deadline: w.stages[name].deadline, Laggards: []int64{task.id}}
+			break
+		}
+	}
+	w.mu.RUnlock()
+	p.failed[task.id] = true
+	p.mu.Unlock()
+	w.update(func() { w.states[task.id].failed(err, w.clock.Now(), w.errorHistorySize) })
+	w.audit(AuditTaskFailed, task, "", err)
+}
+
+// stageErrors returns the errors of the stages that exceeded their deadline ordered by name.
+// The given tasks were not processed and are laggards of their stages as well.
+func (w *Workflow) stageErrors(p *pass, unprocessed []*Task) []error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var errs []error
+	for _, name := range w.stageNames() {
+		laggards := p.laggards[name]
+		if len(laggards) == 0 {
+			continue
+		}
+		s := w.stages[name]
+		for _, task := range unprocessed {
+			if s.tasks[task.id] {
+				laggards[task.id] = true
+			}
+		}
+		ids := make([]int64, 0, len(laggards))
+		for id := range laggards {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		errs = append(errs, StageDeadlineError{Stage: name, Deadline: s.deadline, Laggards: ids})
+	}
+	return errs
+}
+
+// stageNames returns the sorted names of the stages
+func (w *Workflow) stageNames() []string {
+	names := make([]string, 0, len(w.stages))
+	for name := range w.stages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
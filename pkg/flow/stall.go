@@ -0,0 +1,60 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StallDetection configures the detection of stalled tasks, i.e. tasks that fail in a number of consecutive
+// reconciles, see WithStallDetection
+type StallDetection struct {
+	// Threshold is the number of consecutive reconciles in which a task must fail to be considered stalled
+	Threshold int
+	// SameError only counts consecutive failures with the same error message
+	SameError bool
+	// ResetOnProgress treats progress elsewhere in the workflow as a sign that failing tasks are not stalled: when a
+	// task succeeds whose previous execution did not succeed, the consecutive failures of every task are reset,
+	// including those of tasks that already reached the threshold. Without it, only a task's own success resets
+	// its failures.
+	ResetOnProgress bool
+	// Escalate escalates the error of a stalled task to a FatalError matching ErrStalled
+	Escalate bool
+	// OnStall is called once when a task reaches the threshold with the number of failures and the last error
+	OnStall func(task *Task, failures int, err error)
+}
+
+// detectStall counts the given retryable failure of the task and returns the error, escalated to a FatalError if
+// the task stalled and the detection escalates. The returned bool is true, if the task just reached the threshold.
+// Errors of tasks that wait deliberately, i.e. RequeueErrors and ErrWaitingForApproval, are not counted.
+func (w *Workflow) detectStall(task *Task, state *taskState, err error) (error, bool) {
+	var requeueErr RequeueError
+	if w.stall == nil || errors.As(err, &requeueErr) || errors.Is(err, ErrWaitingForApproval) {
+		return err, false
+	}
+	msg := err.Error()
+	if w.stall.SameError && state.failures > 0 && msg != state.failureMsg {
+		state.failures = 0
+	}
+	state.failures++
+	state.failureMsg = msg
+
+	if state.failures < w.stall.Threshold {
+		return err, false
+	}
+	if w.stall.Escalate {
+		err = NewFatalError(fmt.Errorf("%w after %d consecutive failures: %w", ErrStalled, state.failures, err))
+	}
+	return err, state.failures == w.stall.Threshold
+}
+
+// progress resets the failures of the given task, which just succeeded. If it did not succeed before and the
+// detection resets on progress, the failures of all tasks are reset.
+func (w *Workflow) progress(state *taskState, previous Status) {
+	state.failures = 0
+	if w.stall == nil || !w.stall.ResetOnProgress || previous == Succeeded {
+		return
+	}
+	for _, s := range w.states {
+		s.failures = 0
+	}
+}
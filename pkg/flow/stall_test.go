@@ -0,0 +1,124 @@
+package flow_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+	"github.com/x-cellent/go-dags/pkg/flow/flowtest"
+)
+
+// stall is a call of OnStall
+type stall struct {
+	id       int64
+	failures int
+	err      error
+}
+
+// stallWorkflow creates a workflow with the given stall detection, whose task 1 fails with the errors returned by
+// fail for each attempt counting from 1, and records the calls of OnStall
+func stallWorkflow(t *testing.T, detection flow.StallDetection, fail func(attempt int) error,
+	opts ...flow.Option) (*flow.Workflow, *[]stall) {
+	t.Helper()
+	var stalls []stall
+	detection.OnStall = func(task *flow.Task, failures int, err error) {
+		stalls = append(stalls, stall{id: task.ID(), failures: failures, err: err})
+	}
+	clock := flowtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := flow.NewWorkflow(append([]flow.Option{flow.WithClock(clock), flow.WithStallDetection(detection)},
+		opts...)...)
+	attempt := 0
+	err := w.AddTask(flow.NewTask(1, "create V1", func(context.Context, *flow.Task) error {
+		attempt++
+		return fail(attempt)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, &stalls
+}
+
+func TestStallDetectionThreshold(t *testing.T) {
+	unavailable := errors.New("api unavailable")
+	w, stalls := stallWorkflow(t, flow.StallDetection{Threshold: 3}, func(int) error { return unavailable })
+	for run := 1; run <= 4; run++ {
+		err := w.Reconcile(context.Background())
+		if !errors.Is(err, unavailable) || flow.IsFatal(err) || errors.Is(err, flow.ErrStalled) {
+			t.Fatalf("run %d: expected the retryable error of the task, got %v", run, err)
+		}
+		// OnStall is called once, when the task reaches the threshold
+		want := 0
+		if run >= 3 {
+			want = 1
+		}
+		if len(*stalls) != want {
+			t.Fatalf("run %d: expected %d calls of OnStall, got %v", run, want, *stalls)
+		}
+	}
+	if s := (*stalls)[0]; s.id != 1 || s.failures != 3 || !errors.Is(s.err, unavailable) {
+		t.Fatalf("expected the stall of task 1 after 3 failures, got %+v", s)
+	}
+}
+
+func TestStallDetectionEscalate(t *testing.T) {
+	unavailable := errors.New("api unavailable")
+	w, _ := stallWorkflow(t, flow.StallDetection{Threshold: 2, Escalate: true},
+		func(int) error { return unavailable })
+	if err := w.Reconcile(context.Background()); flow.IsFatal(err) {
+		t.Fatalf("expected a retryable error before the threshold, got %v", err)
+	}
+	err := w.Reconcile(context.Background())
+	if !flow.IsFatal(err) || !errors.Is(err, flow.ErrStalled) || !errors.Is(err, unavailable) {
+		t.Fatalf("expected a fatal error matching ErrStalled and the error of the task, got %v", err)
+	}
+}
+
+func TestStallDetectionSameError(t *testing.T) {
+	// the task fails with a different error in every other attempt
+	alternating := func(attempt int) error {
+		if attempt%2 == 0 {
+			return errors.New("timeout")
+		}
+		return errors.New("api unavailable")
+	}
+	for _, sameError := range []bool{false, true} {
+		w, stalls := stallWorkflow(t, flow.StallDetection{Threshold: 3, SameError: sameError}, alternating)
+		for run := 0; run < 5; run++ {
+			_ = w.Reconcile(context.Background())
+		}
+		if stalled := len(*stalls) > 0; stalled == sameError {
+			t.Fatalf("expected a stall only without comparing errors, got %v with SameError %t", *stalls, sameError)
+		}
+	}
+}
+
+func TestStallDetectionResetOnProgress(t *testing.T) {
+	for _, reset := range []bool{false, true} {
+		w, stalls := stallWorkflow(t, flow.StallDetection{Threshold: 3, ResetOnProgress: reset},
+			func(int) error { return errors.New("api unavailable") }, flow.WithContinueOnError())
+		// task 2 fails in the first run and makes progress in the second one, after task 1 failed twice
+		runs := 0
+		err := w.AddTask(flow.NewTask(2, "create V2", func(context.Context, *flow.Task) error {
+			runs++
+			if runs == 1 {
+				return errors.New("not ready")
+			}
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stalledAt := 0
+		for run := 1; run <= 5 && stalledAt == 0; run++ {
+			_ = w.Reconcile(context.Background())
+			if len(*stalls) > 0 {
+				stalledAt = run
+			}
+		}
+		if want := map[bool]int{false: 3, true: 5}[reset]; stalledAt != want {
+			t.Fatalf("expected the stall in run %d with ResetOnProgress %t, got run %d", want, reset, stalledAt)
+		}
+	}
+}
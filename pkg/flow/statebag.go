@@ -0,0 +1,117 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// StateBag is a key/value store shared by the tasks of a single reconcile, e.g. to accumulate facts about a
+// provisioned machine that later tasks read. It is safe for concurrent use.
+type StateBag struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// Set stores the value under the given key, replacing a previous value
+func (b *StateBag) Set(key string, value any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.values == nil {
+		b.values = make(map[string]any)
+	}
+	b.values[key] = value
+}
+
+// Get returns the value stored under the given key and whether it is present.
+// Values restored from a snapshot are decoded from JSON, see WithPersistentState.
+func (b *StateBag) Get(key string) (any, bool) {
+	value, ok := b.get(key)
+	if restored, isRestored := value.(restoredValue); isRestored {
+		var decoded any
+		if err := json.Unmarshal(restored, &decoded); err != nil {
+			return nil, false
+		}
+		return decoded, true
+	}
+	return value, ok
+}
+
+// get returns the value stored under the given key, which has not yet been decoded, if it was restored
+func (b *StateBag) get(key string) (any, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	value, ok := b.values[key]
+	return value, ok
+}
+
+// Delete removes the value stored under the given key
+func (b *StateBag) Delete(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.values, key)
+}
+
+// Keys returns the keys of all stored values in no particular order
+func (b *StateBag) Keys() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	keys := make([]string, 0, len(b.values))
+	for k := range b.values {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// StateValue returns the value stored under the given key, if it is present and of type T.
+// Values restored from a snapshot are decoded from JSON into T.
+func StateValue[T any](b *StateBag, key string) (T, bool) {
+	var result T
+	value, ok := b.get(key)
+	if !ok {
+		return result, false
+	}
+	if restored, isRestored := value.(restoredValue); isRestored {
+		if err := json.Unmarshal(restored, &result); err != nil {
+			return result, false
+		}
+		return result, true
+	}
+	result, ok = value.(T)
+	return result, ok
+}
+
+// marshal returns the values of the state bag encoded as JSON
+func (b *StateBag) marshal() (map[string]json.RawMessage, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	values := make(map[string]json.RawMessage, len(b.values))
+	for key, value := range b.values {
+		data, err := marshalOutput(value)
+		if err != nil {
+			return nil, fmt.Errorf("error snapshotting state %q: %w", key, err)
+		}
+		values[key] = data
+	}
+	return values, nil
+}
+
+// State returns the state bag of the reconcile, in which the task with the given context is reconciled.
+func State(ctx context.Context) (*StateBag, error) {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tc.w.State(), nil
+}
+
+// State returns the state bag of the current or last reconcile of the workflow. Every reconcile starts with an
+// empty state bag, so that reconciles do not share values, except for the first reconcile and the first one after
+// Restore, which start with the values the bag was seeded with or restored from the snapshot, see
+// WithPersistentState.
+func (w *Workflow) State() *StateBag {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.state
+}
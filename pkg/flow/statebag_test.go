@@ -0,0 +1,102 @@
+package flow_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/x-cellent/go-dags/pkg/flow"
+)
+
+// factTask returns a task that records whether the state bag of its reconcile has a serial number and sets one
+func factTask(seen *[]bool) *flow.Task {
+	return flow.NewTask(1, "read serial", func(ctx context.Context, _ *flow.Task) error {
+		state, err := flow.State(ctx)
+		if err != nil {
+			return err
+		}
+		_, ok := flow.StateValue[string](state, "serial")
+		*seen = append(*seen, ok)
+		state.Set("serial", "SN-42")
+		return nil
+	})
+}
+
+func TestStateBagScopedToRun(t *testing.T) {
+	var seen []bool
+	w := flow.NewWorkflow()
+	if err := w.AddTask(factTask(&seen)); err != nil {
+		t.Fatal(err)
+	}
+	w.State().Set("serial", "seeded")
+	for i := 0; i < 2; i++ {
+		if err := w.Reconcile(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first reconcile sees the seeded value, the second one starts empty
+	if len(seen) != 2 || !seen[0] || seen[1] {
+		t.Fatalf("expected the serial only in the first reconcile, got %v", seen)
+	}
+	if serial, ok := flow.StateValue[string](w.State(), "serial"); !ok || serial != "SN-42" {
+		t.Fatalf("expected the state bag of the last reconcile, got %q", serial)
+	}
+}
+
+func TestStateBagPersistence(t *testing.T) {
+	var seen []bool
+	w := flow.NewWorkflow(flow.WithPersistentState())
+	if err := w.AddTask(factTask(&seen)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := w.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restored flow.Snapshot
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	resumed := flow.NewWorkflow(flow.WithPersistentState())
+	if err := resumed.AddTask(factTask(&seen)); err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.Restore(restored); err != nil {
+		t.Fatal(err)
+	}
+	if serial, ok := flow.StateValue[string](resumed.State(), "serial"); !ok || serial != "SN-42" {
+		t.Fatalf("expected the restored serial, got %q", serial)
+	}
+	if err := resumed.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] || !seen[1] {
+		t.Fatalf("expected the resumed reconcile to see the serial, got %v", seen)
+	}
+}
+
+func TestStateBagNotPersistedByDefault(t *testing.T) {
+	var seen []bool
+	w := flow.NewWorkflow()
+	if err := w.AddTask(factTask(&seen)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := w.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.State != nil {
+		t.Fatalf("expected no state in the snapshot, got %v", snapshot.State)
+	}
+}
@@ -0,0 +1,175 @@
+package flow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is the recorded status of a task
+type Status int
+
+const (
+	// Pending indicates that the task was not executed yet
+	Pending Status = iota
+	// Running indicates that the task is being executed
+	Running
+	// Succeeded indicates that the task completed successfully
+	Succeeded
+	// Failed indicates that the task returned an error
+	Failed
+	// Skipped indicates that the task was intentionally skipped, e.g. by returning ErrSkipTask
+	Skipped
+	// WaitingForApproval indicates that the task is a gate task that was not approved yet
+	WaitingForApproval
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pending:
+		return "pending"
+	case Running:
+		return "running"
+	case Succeeded:
+		return "succeeded"
+	case Failed:
+		return "failed"
+	case Skipped:
+		return "skipped"
+	case WaitingForApproval:
+		return "waiting for approval"
+	}
+	return "unknown"
+}
+
+// parseStatus returns the status with the given name, see Status.String
+func parseStatus(name string) (Status, error) {
+	for s := Pending; s <= WaitingForApproval; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return Pending, fmt.Errorf("unknown status %q", name)
+}
+
+// taskState is the recorded state of a task across reconciliations
+type taskState struct {
+	status Status
+	// reason why the task was skipped
+	reason string
+	// number of invocations of the task's reconcile function
+	attempts int
+	// error of the last failed invocation
+	err error
+	// durations of the invocations measured by the timing middleware
+	durations DurationStats
+	// circuit breaker, if the task has one
+	breaker breaker
+	// output values recorded by the task, see SetOutput
+	outputs map[string]any
+	// keys of the outputs that are excluded from snapshots
+	sensitive map[string]bool
+	// fingerprint of the inputs of the last successful execution, see WithFingerprint
+	fingerprint string
+	// most recent failure, which is kept until the task succeeds
+	lastErr *ErrorRecord
+	// most recent failures, oldest first, bounded by the error history size of the workflow
+	history []ErrorRecord
+	// number of consecutive reconciles in which the task failed and the last error message, see WithStallDetection
+	failures   int
+	failureMsg string
+	// number of retryable failures since the task last succeeded, see TaskRetries
+	retries int
+	// set if the last execution exceeded the service level of the task, see SLA
+	slaBreached bool
+}
+
+// ErrorRecord is an error of a task together with the time and the attempt at which it occurred.
+// It marshals to JSON with the error message, so an unmarshaled record only retains the message of the error.
+type ErrorRecord struct {
+	// Err is the error
+	Err error
+	// Time is the time at which the error occurred
+	Time time.Time
+	// Attempt is the number of the failed invocation of the task's reconcile function
+	Attempt int
+}
+
+type errorRecordJSON struct {
+	Error   string    `json:"error"`
+	Time    time.Time `json:"time"`
+	Attempt int       `json:"attempt"`
+}
+
+func (r ErrorRecord) MarshalJSON() ([]byte, error) {
+	var msg string
+	if r.Err != nil {
+		msg = r.Err.Error()
+	}
+	return json.Marshal(errorRecordJSON{Error: msg, Time: r.Time, Attempt: r.Attempt})
+}
+
+func (r *ErrorRecord) UnmarshalJSON(data []byte) error {
+	var record errorRecordJSON
+	if err := json.Unmarshal(data, &record); err != nil {
+		return err
+	}
+	*r = ErrorRecord{Err: errors.New(record.Error), Time: record.Time, Attempt: record.Attempt}
+	return nil
+}
+
+// describe returns the status and, if present, the reason
+func (s *taskState) describe() string {
+	if s.reason == "" {
+		return s.status.String()
+	}
+	return fmt.Sprintf("%s: %s", s.status, s.reason)
+}
+
+func (s *taskState) reset() {
+	s.status = Pending
+	s.reason = ""
+	s.err = nil
+	s.outputs = nil
+	s.sensitive = nil
+	s.fingerprint = ""
+	s.lastErr = nil
+	s.history = nil
+	s.failures = 0
+	s.retries = 0
+	s.failureMsg = ""
+	s.slaBreached = false
+}
+
+func (s *taskState) succeeded() {
+	s.status = Succeeded
+	s.reason = ""
+	s.err = nil
+	s.lastErr = nil
+	s.retries = 0
+}
+
+func (s *taskState) skipped(reason string) {
+	s.status = Skipped
+	s.reason = reason
+	s.err = nil
+}
+
+func (s *taskState) failed(err error, at time.Time, historySize int) {
+	s.status = Failed
+	if errors.Is(err, ErrWaitingForApproval) {
+		s.status = WaitingForApproval
+	}
+	s.reason = ""
+	s.err = err
+	s.lastErr = &ErrorRecord{Err: err, Time: at, Attempt: s.attempts}
+	switch {
+	case historySize <= 0:
+	case len(s.history) < historySize:
+		s.history = append(s.history, *s.lastErr)
+	default:
+		copy(s.history, s.history[1:])
+		s.history[len(s.history)-1] = *s.lastErr
+	}
+}
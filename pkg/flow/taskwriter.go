@@ -0,0 +1,88 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// taskOutput is the destination of the output of the tasks of a workflow, see TaskWriter
+type taskOutput struct {
+	// serializes the writes of tasks running in parallel
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// lineWriter prefixes each line written by a task, see TaskWriter
+type lineWriter struct {
+	output *taskOutput
+	prefix []byte
+	mu     sync.Mutex
+	// incomplete last line
+	buf []byte
+}
+
+// TaskWriter returns a writer for the output of the task reconciled with the given context, e.g. for the output of
+// a command with cmd.Stdout = flow.TaskWriter(ctx). Each line is prefixed with the run id and the task, e.g.
+// "[run 3] task 1 (a): ", and complete lines are written at once, so that the output of tasks running in parallel
+// is not interleaved. An incomplete last line is written, when the task finishes. The destination is configured per
+// workflow, see WithTaskOutput. Outside of a reconcile, it returns os.Stdout.
+func TaskWriter(ctx context.Context) io.Writer {
+	tc, err := fromContext(ctx)
+	if err != nil {
+		return os.Stdout
+	}
+	return tc.writer
+}
+
+// newLineWriter returns the writer for the output of the given task in the current reconcile
+func (w *Workflow) newLineWriter(task *Task) *lineWriter {
+	return &lineWriter{
+		output: w.output,
+		prefix: []byte(fmt.Sprintf("[run %d] %s: ", w.runID, task)),
+	}
+}
+
+// Write writes the complete lines of p prefixed and buffers an incomplete last line
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	end := bytes.LastIndexByte(l.buf, '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	err := l.write(l.buf[:end+1])
+	l.buf = append(l.buf[:0], l.buf[end+1:]...)
+	return len(p), err
+}
+
+// flush writes the buffered incomplete line terminated by a newline
+func (l *lineWriter) flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) == 0 {
+		return nil
+	}
+	err := l.write(append(l.buf, '\n'))
+	l.buf = l.buf[:0]
+	return err
+}
+
+// write writes the given complete lines prefixed with a single write to the destination
+func (l *lineWriter) write(lines []byte) error {
+	var out bytes.Buffer
+	for len(lines) > 0 {
+		i := bytes.IndexByte(lines, '\n')
+		out.Write(l.prefix)
+		out.Write(lines[:i+1])
+		lines = lines[i+1:]
+	}
+	l.output.mu.Lock()
+	defer l.output.mu.Unlock()
+	_, err := l.output.out.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,61 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DurationStats are the durations of the invocations of a task's reconcile function measured by the
+// timing middleware
+type DurationStats struct {
+	// Count is the number of measured invocations
+	Count int
+	// Last is the duration of the last invocation
+	Last time.Duration
+	// Total is the cumulative duration of all invocations
+	Total time.Duration
+}
+
+// Average returns the average duration of the measured invocations
+func (s DurationStats) Average() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Timing returns a middleware that measures each invocation of a task's reconcile function with the workflow's
+// clock and records it in the duration statistics of the task, see Workflow.DurationStats and Report.
+func Timing() Middleware {
+	return func(next Fn) Fn {
+		return func(ctx context.Context, task *Task) error {
+			tc, err := fromContext(ctx)
+			if err != nil {
+				return next(ctx, task)
+			}
+			start := tc.w.clock.Now()
+			err = next(ctx, task)
+			d := tc.w.clock.Now().Sub(start)
+			tc.w.update(func() { tc.w.states[task.id].durations.add(d) })
+			return err
+		}
+	}
+}
+
+func (s *DurationStats) add(d time.Duration) {
+	s.Count++
+	s.Last = d
+	s.Total += d
+}
+
+// DurationStats returns the duration statistics of the task with the given id recorded by the timing middleware
+func (w *Workflow) DurationStats(taskID int64) (DurationStats, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	state, ok := w.states[taskID]
+	if !ok {
+		return DurationStats{}, fmt.Errorf("error getting duration statistics of task id %d: %w", taskID, ErrTaskNotFound)
+	}
+	return state.durations, nil
+}
@@ -0,0 +1,141 @@
+package flow
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultVisualizeSeparator is the default separator between two tasks of a visualization
+const DefaultVisualizeSeparator = " >> "
+
+// VisualizeOption configures the visualization of a workflow, see Visualize
+type VisualizeOption func(c *visualizeConfig)
+
+type visualizeConfig struct {
+	separator string
+	format    func(task *Task) string
+	maxWidth  int
+	multiLine bool
+}
+
+// Separator sets the separator between two tasks, e.g. " → ". Defaults to DefaultVisualizeSeparator.
+func Separator(separator string) VisualizeOption {
+	return func(c *visualizeConfig) {
+		c.separator = separator
+	}
+}
+
+// FormatTask sets the function that renders a single task, e.g. only its id. By default, a task is rendered by
+// Task.String followed by its markers, e.g. whether it was skipped.
+func FormatTask(format func(task *Task) string) VisualizeOption {
+	return func(c *visualizeConfig) {
+		c.format = format
+	}
+}
+
+// MaxWidth limits the width of the lines of the visualization to the given number of characters. Lines are broken
+// between two tasks, the separator is kept at the end of the line without trailing spaces. A single task that
+// exceeds the width is truncated. Unlimited if not positive.
+func MaxWidth(n int) VisualizeOption {
+	return func(c *visualizeConfig) {
+		c.maxWidth = n
+	}
+}
+
+// MultiLine writes each task on its own line terminated by a newline, e.g. for line based logging.
+// The separator is not used in multi-line mode.
+func MultiLine() VisualizeOption {
+	return func(c *visualizeConfig) {
+		c.multiLine = true
+	}
+}
+
+// newVisualizeConfig returns the configuration of the given options
+func newVisualizeConfig(opts []VisualizeOption) visualizeConfig {
+	config := visualizeConfig{
+		separator: DefaultVisualizeSeparator,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// visualizeWriter writes the items of a visualization, i.e. the rendered tasks, according to its configuration
+type visualizeWriter struct {
+	out    io.Writer
+	config visualizeConfig
+	// number of items written so far
+	items int
+	// width of the current line
+	width int
+}
+
+// write writes the given item preceded by the separator or a line break
+func (v *visualizeWriter) write(item string) error {
+	item = fitWidth(item, v.config.maxWidth)
+	var text string
+	switch {
+	case v.config.multiLine:
+		text = item + "\n"
+	case v.items == 0:
+		text = item
+	case v.config.maxWidth > 0 && v.width+utf8.RuneCountInString(v.config.separator+item) > v.config.maxWidth:
+		text = strings.TrimRightFunc(v.config.separator, unicode.IsSpace) + "\n" + item
+	default:
+		text = v.config.separator + item
+	}
+	if _, err := io.WriteString(v.out, text); err != nil {
+		return err
+	}
+	v.items++
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		v.width = utf8.RuneCountInString(text[i+1:])
+	} else {
+		v.width += utf8.RuneCountInString(text)
+	}
+	return nil
+}
+
+// fitWidth returns the given string shortened to the given number of characters, if it is positive
+func fitWidth(s string, n int) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	if n <= 3 {
+		return string(runes[:n])
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// formatTask renders the given task according to the configuration
+func (w *Workflow) formatTask(t *Task, config visualizeConfig) string {
+	if config.format != nil {
+		return config.format(t)
+	}
+	s := t.String()
+	if t.barrier {
+		s += " [barrier]"
+	}
+	if state := w.states[t.id]; state.status == Skipped {
+		s += " [" + state.describe() + "]"
+	}
+	return s
+}
+
+func (w *Workflow) visualize(out io.Writer, tasks []*Task, limit int, config visualizeConfig) error {
+	v := &visualizeWriter{out: out, config: config}
+	for i, t := range tasks {
+		if limit > 0 && i == limit {
+			return v.write(fmt.Sprintf("... and %d more", len(tasks)-limit))
+		}
+		if err := v.write(w.formatTask(t, config)); err != nil {
+			return err
+		}
+	}
+	return nil
+}